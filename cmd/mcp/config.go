@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sq_pix/internal/database"
+	"sq_pix/internal/esptag"
+)
+
+// registrarFlagsBanco registra as flags de conexão com o SQL Server no FlagSet informado
+func registrarFlagsBanco(fs *flag.FlagSet) *database.DBConfig {
+	config := &database.DBConfig{}
+	fs.StringVar(&config.Server, "server", "", "SQL Server address")
+	fs.IntVar(&config.Port, "port", 1433, "SQL Server port")
+	fs.StringVar(&config.User, "user", "", "SQL Server user")
+	fs.StringVar(&config.Password, "password", "", "SQL Server password")
+	fs.StringVar(&config.Database, "database", "", "SQL Server database name")
+	return config
+}
+
+// completarConfigBanco verifica variáveis de ambiente caso os argumentos não sejam fornecidos
+func completarConfigBanco(config *database.DBConfig) {
+	if config.Server == "" {
+		config.Server = os.Getenv("DB_SERVER")
+	}
+	if config.User == "" {
+		config.User = os.Getenv("DB_USER")
+	}
+	if config.Password == "" {
+		config.Password = os.Getenv("DB_PASSWORD")
+	}
+	if config.Database == "" {
+		config.Database = os.Getenv("DB_NAME")
+	}
+}
+
+// abrirCatalogo abre o Catalog a partir do snapshot, se informado, ou do SQL Server.
+// Retorna também a função que libera a conexão com o banco.
+func abrirCatalogo(config *database.DBConfig, arquivoSnapshot string) (esptag.Catalog, func()) {
+	if arquivoSnapshot != "" {
+		// Modo offline: as consultas usam o snapshot em memória
+		snapshot, err := esptag.CarregarSnapshot(arquivoSnapshot)
+		if err != nil {
+			log.Fatalf("Erro ao carregar snapshot: %v", err)
+		}
+		log.Printf("Usando snapshot %s (origem %s, gerado em %s)", arquivoSnapshot, snapshot.Origem, snapshot.GeradoEm.Format("2006-01-02 15:04"))
+		return esptag.NewMemoryCatalog(snapshot.DadosCatalogo), func() {}
+	}
+
+	completarConfigBanco(config)
+
+	// Conecta ao banco de dados
+	db, err := database.NewConnection(*config)
+	if err != nil {
+		log.Fatalf("Erro ao conectar ao banco de dados: %v", err)
+	}
+	return esptag.NewSQLServerCatalog(db), func() { db.Close() }
+}
+
+// carregarPesos retorna os pesos de pontuação do arquivo informado ou, sem arquivo, os pesos padrão
+func carregarPesos(arquivo string) esptag.PesosPontuacao {
+	if arquivo == "" {
+		return esptag.PesosPadrao()
+	}
+
+	pesos, err := esptag.CarregarPesos(arquivo)
+	if err != nil {
+		log.Fatalf("Erro ao carregar pesos de pontuação: %v", err)
+	}
+	log.Printf("Usando pesos de pontuação de %s: %+v", arquivo, pesos)
+	return pesos
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sq_pix/internal/esptag"
+)
+
+// avaliar implementa o comando eval, que mede a acurácia da consulta de dados da mensagem sobre um
+// dataset rotulado, ou gera esse dataset a partir dos vínculos existentes com -semear
+func avaliar(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	dbConfig := registrarFlagsBanco(fs)
+	var dataset, arquivoSnapshot, arquivoPesos, anterior, saida, semear string
+	var niveis int
+	fs.StringVar(&dataset, "dataset", "", "Arquivo JSON com os casos rotulados")
+	fs.StringVar(&arquivoSnapshot, "snapshot", "", "Arquivo de snapshot JSON usado no lugar do banco de dados")
+	fs.StringVar(&arquivoPesos, "pesos", "", "Arquivo JSON com os pesos de pontuação")
+	fs.StringVar(&anterior, "anterior", "", "Relatório JSON de uma execução anterior, para detectar regressões")
+	fs.StringVar(&saida, "out", "", "Arquivo JSON onde o relatório desta execução será gravado")
+	fs.StringVar(&semear, "semear", "", "Gera o dataset no arquivo informado a partir dos vínculos de spi_especializacao_msg_tag, sem avaliar")
+	fs.IntVar(&niveis, "niveis", 3, "Níveis do caminho incluídos nos trechos XML gerados por -semear (0 = caminho completo)")
+	fs.Parse(args)
+
+	if dataset == "" && semear == "" {
+		log.Fatalf("Informe o dataset com -dataset ou o arquivo a ser gerado com -semear")
+	}
+
+	catalogo, fecharCatalogo := abrirCatalogo(dbConfig, arquivoSnapshot)
+	defer fecharCatalogo()
+
+	if semear != "" {
+		casos, err := esptag.GerarDatasetDosVinculos(catalogo, niveis)
+		if err != nil {
+			log.Fatalf("Erro ao gerar dataset: %v", err)
+		}
+		if err := esptag.SalvarDatasetAvaliacao(casos, semear); err != nil {
+			log.Fatalf("Erro ao salvar dataset: %v", err)
+		}
+		log.Printf("Dataset gravado em %s: %d casos", semear, len(casos))
+		return
+	}
+
+	casos, err := esptag.CarregarDatasetAvaliacao(dataset)
+	if err != nil {
+		log.Fatalf("Erro ao carregar dataset: %v", err)
+	}
+
+	pesos := carregarPesos(arquivoPesos)
+	relatorio := esptag.Avaliar(catalogo, esptag.NovoScorer(pesos), casos)
+	relatorio.Pesos = &pesos
+	escreverRelatorioAvaliacao(os.Stdout, relatorio)
+
+	regressoes := 0
+	if anterior != "" {
+		relatorioAnterior, err := esptag.CarregarRelatorioAvaliacao(anterior)
+		if err != nil {
+			log.Fatalf("Erro ao carregar relatório anterior: %v", err)
+		}
+		piores, melhores := esptag.CompararAvaliacoes(relatorioAnterior, relatorio)
+		escreverComparacaoAvaliacao(os.Stdout, anterior, relatorioAnterior, relatorio, piores, melhores)
+		regressoes = len(piores)
+	}
+
+	if saida != "" {
+		if err := esptag.SalvarRelatorioAvaliacao(relatorio, saida); err != nil {
+			log.Fatalf("Erro ao salvar relatório: %v", err)
+		}
+		log.Printf("Relatório gravado em %s", saida)
+	}
+
+	// Regressões encerram com erro, para uso em pipelines
+	if regressoes > 0 {
+		fecharCatalogo()
+		os.Exit(1)
+	}
+}
+
+// escreverRelatorioAvaliacao imprime o resumo da avaliação e os casos que merecem atenção
+func escreverRelatorioAvaliacao(w io.Writer, r *esptag.RelatorioAvaliacao) {
+	fmt.Fprintf(w, "Casos avaliados: %d (%d com erro)\n", r.Total, r.Erros)
+	fmt.Fprintf(w, "Top-1: %d/%d (%.1f%%)\n", r.Top1, r.Total, r.Acuracia(r.Top1)*100)
+	fmt.Fprintf(w, "Top-3: %d/%d (%.1f%%)\n", r.Top3, r.Total, r.Acuracia(r.Top3)*100)
+	fmt.Fprintf(w, "Ambíguos: %d\n", r.Ambiguos)
+
+	var foraTop1, ambiguos, erros []esptag.ResultadoCaso
+	for _, resultado := range r.Resultados {
+		switch {
+		case resultado.Erro != "":
+			erros = append(erros, resultado)
+		case resultado.Posicao != 1:
+			foraTop1 = append(foraTop1, resultado)
+		}
+		if resultado.Erro == "" && resultado.Ambiguo {
+			ambiguos = append(ambiguos, resultado)
+		}
+	}
+
+	if len(foraTop1) > 0 {
+		fmt.Fprintf(w, "\nCasos fora do top-1 (%d):\n", len(foraTop1))
+		for _, resultado := range foraTop1 {
+			posicao := "ausente do ranking"
+			if resultado.Posicao > 0 {
+				posicao = fmt.Sprintf("posição %d", resultado.Posicao)
+			}
+			fmt.Fprintf(w, "- %s: esperado %d, obtido %d (%s de %d candidatos)\n",
+				resultado.Nome, resultado.Esperado, resultado.Obtido, posicao, resultado.Candidatos)
+		}
+	}
+
+	if len(ambiguos) > 0 {
+		fmt.Fprintf(w, "\nCasos ambíguos (%d):\n", len(ambiguos))
+		for _, resultado := range ambiguos {
+			fmt.Fprintf(w, "- %s: esperado na posição %d de %d candidatos\n", resultado.Nome, resultado.Posicao, resultado.Candidatos)
+		}
+	}
+
+	if len(erros) > 0 {
+		fmt.Fprintf(w, "\nErros (%d):\n", len(erros))
+		for _, resultado := range erros {
+			fmt.Fprintf(w, "- %s: %s\n", resultado.Nome, resultado.Erro)
+		}
+	}
+}
+
+// escreverComparacaoAvaliacao imprime a variação de acurácia e os casos que pioraram ou melhoraram
+func escreverComparacaoAvaliacao(w io.Writer, arquivo string, anterior, atual *esptag.RelatorioAvaliacao, regressoes, melhorias []esptag.ComparacaoCaso) {
+	fmt.Fprintf(w, "\nComparação com %s (gerado em %s):\n", arquivo, anterior.GeradoEm.Format("2006-01-02 15:04"))
+	fmt.Fprintf(w, "Top-1: %.1f%% -> %.1f%%\n", anterior.Acuracia(anterior.Top1)*100, atual.Acuracia(atual.Top1)*100)
+	fmt.Fprintf(w, "Top-3: %.1f%% -> %.1f%%\n", anterior.Acuracia(anterior.Top3)*100, atual.Acuracia(atual.Top3)*100)
+
+	fmt.Fprintf(w, "\nRegressões (%d):\n", len(regressoes))
+	for _, c := range regressoes {
+		fmt.Fprintf(w, "- %s: posição %d -> %d\n", c.Nome, c.Anterior, c.Atual)
+	}
+
+	fmt.Fprintf(w, "\nMelhorias (%d):\n", len(melhorias))
+	for _, c := range melhorias {
+		fmt.Fprintf(w, "- %s: posição %d -> %d\n", c.Nome, c.Anterior, c.Atual)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sq_pix/internal/database"
+	"sq_pix/internal/esptag"
+)
+
+// exportarSnapshot implementa o comando export-snapshot, que grava as tabelas spi_* em um arquivo JSON
+func exportarSnapshot(args []string) {
+	fs := flag.NewFlagSet("export-snapshot", flag.ExitOnError)
+	dbConfig := registrarFlagsBanco(fs)
+	var arquivo string
+	fs.StringVar(&arquivo, "out", "snapshot.json", "Arquivo JSON de saída")
+	fs.Parse(args)
+	completarConfigBanco(dbConfig)
+
+	db, err := database.NewConnection(*dbConfig)
+	if err != nil {
+		log.Fatalf("Erro ao conectar ao banco de dados: %v", err)
+	}
+	defer db.Close()
+
+	origem := fmt.Sprintf("%s/%s", dbConfig.Server, dbConfig.Database)
+	snapshot, err := esptag.ExportarSnapshot(esptag.NewSQLServerCatalog(db), origem)
+	if err != nil {
+		log.Fatalf("Erro ao exportar snapshot: %v", err)
+	}
+
+	if err := esptag.SalvarSnapshot(snapshot, arquivo); err != nil {
+		log.Fatalf("Erro ao salvar snapshot: %v", err)
+	}
+
+	log.Printf("Snapshot gravado em %s: %d tags, %d especializações, %d vínculos, %d situações",
+		arquivo, len(snapshot.MensagemTags), len(snapshot.Especializacoes), len(snapshot.Vinculos), len(snapshot.SitMsgEmiDes))
+}
@@ -2,87 +2,172 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
-	"sq_pix/internal/database"
 	"sq_pix/internal/esptag"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	mcp_golang "github.com/metoro-io/mcp-golang"
+	"github.com/metoro-io/mcp-golang/transport"
+	mcphttp "github.com/metoro-io/mcp-golang/transport/http"
 	"github.com/metoro-io/mcp-golang/transport/stdio"
 )
 
+// novoTransporte cria o transporte MCP conforme o modo escolhido via flag.
+// No modo http, retorna também a função que inicia o servidor HTTP, que exige as sessões informadas.
+func novoTransporte(modo, listen, endpoint string, sessoes *sessoesHTTP) (transport.Transport, func() error, error) {
+	switch modo {
+	case "stdio":
+		return stdio.NewStdioServerTransport(), nil, nil
+	case "http":
+		// O HTTPTransport do mcp-golang v0.8.0 não repassa as mensagens ao servidor
+		// (o handler fica em um campo que sombreia o do transporte base), por isso
+		// usamos o GinTransport montado em um router próprio
+		transporte := mcphttp.NewGinTransport()
+		gin.SetMode(gin.ReleaseMode)
+		router := gin.New()
+		router.Use(gin.Recovery())
+		// Nenhum proxy é confiável: X-Forwarded-For não altera o endereço de origem
+		if err := router.SetTrustedProxies(nil); err != nil {
+			return nil, nil, err
+		}
+		router.POST(endpoint, sessoes.middleware(), transporte.Handler())
+		router.DELETE(endpoint, sessoes.handlerEncerramento())
+		return transporte, func() error { return router.Run(listen) }, nil
+	default:
+		return nil, nil, fmt.Errorf("transporte '%s' não suportado (use 'stdio' ou 'http')", modo)
+	}
+}
+
 func main() {
+	// Subcomandos
+	if len(os.Args) > 1 && os.Args[1] == "export-snapshot" {
+		exportarSnapshot(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		avaliar(os.Args[2:])
+		return
+	}
+
 	// Configuração do banco via flags
-	var dbServer, dbUser, dbPassword, dbName string
-	var dbPort int
-
-	flag.StringVar(&dbServer, "server", "", "SQL Server address")
-	flag.IntVar(&dbPort, "port", 1433, "SQL Server port")
-	flag.StringVar(&dbUser, "user", "", "SQL Server user")
-	flag.StringVar(&dbPassword, "password", "", "SQL Server password")
-	flag.StringVar(&dbName, "database", "", "SQL Server database name")
+	dbConfig := registrarFlagsBanco(flag.CommandLine)
+	var modoTransporte, listenAddr, endpoint, arquivoSnapshot, arquivoPesos string
+	var validadeSessao time.Duration
+
+	flag.StringVar(&modoTransporte, "transport", "stdio", "Transporte MCP: stdio ou http")
+	flag.StringVar(&listenAddr, "listen", ":8080", "Endereço de escuta do transporte http")
+	flag.StringVar(&endpoint, "endpoint", "/mcp", "Caminho HTTP do endpoint MCP no transporte http")
+	flag.DurationVar(&validadeSessao, "validade-sessao", 8*time.Hour, "Tempo sem uso após o qual uma sessão do transporte http e o seu changeset são descartados")
+	flag.StringVar(&arquivoSnapshot, "snapshot", "", "Arquivo de snapshot JSON usado no lugar do banco de dados")
+	flag.StringVar(&arquivoPesos, "pesos", "", "Arquivo JSON com os pesos de pontuação da consulta de dados da mensagem")
 	flag.Parse()
 
-	// Verifica variáveis de ambiente caso os argumentos não sejam fornecidos
-	if dbServer == "" {
-		dbServer = os.Getenv("DB_SERVER")
-	}
-	if dbUser == "" {
-		dbUser = os.Getenv("DB_USER")
-	}
-	if dbPassword == "" {
-		dbPassword = os.Getenv("DB_PASSWORD")
-	}
-	if dbName == "" {
-		dbName = os.Getenv("DB_NAME")
-	}
+	catalogo, fecharCatalogo := abrirCatalogo(dbConfig, arquivoSnapshot)
+	defer fecharCatalogo()
 
-	// Configuração do banco de dados
-	dbConfig := database.DBConfig{
-		Server:   dbServer,
-		Port:     dbPort,
-		User:     dbUser,
-		Password: dbPassword,
-		Database: dbName,
-	}
+	// Pesos de pontuação da consulta de dados da mensagem
+	pesos := carregarPesos(arquivoPesos)
+
+	// Changesets das ferramentas de geração de script, um por sessão no transporte http
+	changesets := esptag.NovosChangesets(sessaoCliente)
+	sessoes := novasSessoesHTTP(validadeSessao, changesets.Remover)
 
-	// Conecta ao banco de dados
-	db, err := database.NewConnection(dbConfig)
+	// Cria o servidor MCP com o transporte escolhido
+	transporte, iniciarHTTP, err := novoTransporte(modoTransporte, listenAddr, endpoint, sessoes)
 	if err != nil {
-		log.Fatalf("Erro ao conectar ao banco de dados: %v", err)
+		log.Fatalf("Erro ao configurar transporte: %v", err)
 	}
-	defer db.Close()
-
-	// Cria o servidor MCP com transporte stdio
-	server := mcp_golang.NewServer(stdio.NewStdioServerTransport())
+	server := mcp_golang.NewServer(transporte)
 
 	// Registra os MCPs disponíveis
-	if err := esptag.RegisterConsultaEspecializacao(server, db); err != nil {
+	if err := esptag.RegisterConsultaEspecializacao(server, catalogo); err != nil {
 		log.Fatalf("Erro ao registrar MCP de consulta: %v", err)
 	}
 
-	if err := esptag.RegisterGeraScriptNovaEspecializacao(server, db); err != nil {
+	if err := esptag.RegisterGeraScriptNovaEspecializacao(server, catalogo, changesets); err != nil {
 		log.Fatalf("Erro ao registrar MCP de geração de script: %v", err)
 	}
 
-	if err := esptag.RegisterGeraScriptVinculacao(server, db); err != nil {
+	if err := esptag.RegisterGeraScriptVinculacao(server, catalogo, changesets); err != nil {
 		log.Fatalf("Erro ao registrar MCP de geração de script de vinculação: %v", err)
 	}
 
-	if err := esptag.RegisterConsultaDadosMensagem(server, db); err != nil {
+	if err := esptag.RegisterConsultaDadosMensagem(server, catalogo, esptag.NovoScorer(pesos)); err != nil {
 		log.Fatalf("Erro ao registrar MCP de consulta de dados da mensagem: %v", err)
 	}
 
-	if err := esptag.RegisterGeraScriptSitMsgEmiDes(server, db); err != nil {
+	if err := esptag.RegisterGeraScriptSitMsgEmiDes(server, catalogo, changesets); err != nil {
 		log.Fatalf("Erro ao registrar MCP de geração de script spi_sit_msg_emi_des: %v", err)
 	}
 
+	if err := esptag.RegisterConsultaVinculosEspecializacao(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de consulta de vínculos da especialização: %v", err)
+	}
+
+	if err := esptag.RegisterCoberturaMensagem(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de cobertura da mensagem: %v", err)
+	}
+
+	if err := esptag.RegisterListaMensagens(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de listagem de mensagens: %v", err)
+	}
+
+	if err := esptag.RegisterNavegaMensagem(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de navegação na mensagem: %v", err)
+	}
+
+	if err := esptag.RegisterGeraScriptDesvinculacao(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de geração de script de desvinculação: %v", err)
+	}
+
+	if err := esptag.RegisterGeraScriptExclusaoEspecializacao(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de geração de script de exclusão de especialização: %v", err)
+	}
+
+	if err := esptag.RegisterGeraScriptMigracaoVersao(server, catalogo, changesets); err != nil {
+		log.Fatalf("Erro ao registrar MCP de geração de script de migração de versão: %v", err)
+	}
+
+	if err := esptag.RegisterImportaXSD(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de importação de XSD: %v", err)
+	}
+
+	if err := esptag.RegisterValidaMensagemXSD(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de validação de mensagem com o XSD: %v", err)
+	}
+
+	if err := esptag.RegisterGeraExemploMensagem(server, catalogo); err != nil {
+		log.Fatalf("Erro ao registrar MCP de geração de exemplo de mensagem: %v", err)
+	}
+
+	if err := esptag.RegisterAnotaMensagem(server, catalogo, esptag.NovoScorer(pesos)); err != nil {
+		log.Fatalf("Erro ao registrar MCP de anotação de mensagem: %v", err)
+	}
+
+	if err := esptag.RegisterSimulaExtracao(server, catalogo, esptag.NovoScorer(pesos), changesets); err != nil {
+		log.Fatalf("Erro ao registrar MCP de simulação de extração: %v", err)
+	}
+
+	if err := esptag.RegisterChangeset(server, catalogo, changesets); err != nil {
+		log.Fatalf("Erro ao registrar MCPs de changeset: %v", err)
+	}
+
 	// Inicia o servidor
 	log.Println("Iniciando servidor MCP para Especialização de Tags...")
 	if err := server.Serve(); err != nil {
 		log.Fatalf("Erro ao iniciar servidor: %v", err)
 	}
 
+	if iniciarHTTP != nil {
+		log.Printf("Servidor MCP escutando em http://%s%s", listenAddr, endpoint)
+		if err := iniciarHTTP(); err != nil {
+			log.Fatalf("Erro no servidor HTTP: %v", err)
+		}
+	}
+
 	// Mantém o servidor em execução
 	select {}
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cabecalhoSessao é o cabeçalho HTTP do MCP que identifica a sessão do cliente
+const cabecalhoSessao = "Mcp-Session-Id"
+
+// sessoesHTTP emite e valida os identificadores de sessão do transporte http.
+// A sessão é criada na requisição initialize, cuja resposta traz o identificador em Mcp-Session-Id,
+// e as demais requisições precisam repeti-lo. Sessões sem uso por mais que a validade são descartadas.
+type sessoesHTTP struct {
+	mu         sync.Mutex
+	validade   time.Duration
+	ultimoUso  map[string]time.Time
+	aoEncerrar func(id string)
+}
+
+// novasSessoesHTTP cria o controle de sessões; aoEncerrar é chamada quando uma sessão expira ou é encerrada
+func novasSessoesHTTP(validade time.Duration, aoEncerrar func(id string)) *sessoesHTTP {
+	return &sessoesHTTP{validade: validade, ultimoUso: make(map[string]time.Time), aoEncerrar: aoEncerrar}
+}
+
+// criar emite um novo identificador de sessão aleatório
+func (s *sessoesHTTP) criar() (string, error) {
+	bytesID := make([]byte, 16)
+	if _, err := rand.Read(bytesID); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(bytesID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ultimoUso[id] = time.Now()
+	return id, nil
+}
+
+// renovar registra o uso da sessão, retornando false se ela não existe ou expirou
+func (s *sessoesHTTP) renovar(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ultimoUso[id]; !ok {
+		return false
+	}
+	s.ultimoUso[id] = time.Now()
+	return true
+}
+
+// encerrar descarta a sessão, retornando false se ela não existe
+func (s *sessoesHTTP) encerrar(id string) bool {
+	s.mu.Lock()
+	_, ok := s.ultimoUso[id]
+	delete(s.ultimoUso, id)
+	s.mu.Unlock()
+
+	if ok && s.aoEncerrar != nil {
+		s.aoEncerrar(id)
+	}
+	return ok
+}
+
+// expirar descarta as sessões sem uso por mais que a validade
+func (s *sessoesHTTP) expirar() {
+	s.mu.Lock()
+	var expiradas []string
+	for id, uso := range s.ultimoUso {
+		if time.Since(uso) > s.validade {
+			expiradas = append(expiradas, id)
+			delete(s.ultimoUso, id)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.aoEncerrar != nil {
+		for _, id := range expiradas {
+			s.aoEncerrar(id)
+		}
+	}
+}
+
+// middleware exige uma sessão válida nas requisições MCP, emitindo-a na requisição initialize
+func (s *sessoesHTTP) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.expirar()
+
+		if id := c.GetHeader(cabecalhoSessao); id != "" {
+			if !s.renovar(id) {
+				c.String(http.StatusNotFound, "Sessão inexistente ou expirada. Reinicie a conexão MCP (initialize).")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// Sem sessão, apenas a requisição initialize é aceita
+		corpo, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(corpo))
+
+		var requisicao struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(corpo, &requisicao) != nil || requisicao.Method != "initialize" {
+			c.String(http.StatusBadRequest, "Cabeçalho %s ausente. Inicie a conexão MCP com initialize e repita o identificador de sessão retornado.", cabecalhoSessao)
+			c.Abort()
+			return
+		}
+
+		id, err := s.criar()
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Erro ao criar sessão: %v", err)
+			c.Abort()
+			return
+		}
+		c.Request.Header.Set(cabecalhoSessao, id)
+		c.Header(cabecalhoSessao, id)
+		c.Next()
+	}
+}
+
+// handlerEncerramento encerra a sessão informada em Mcp-Session-Id (DELETE no endpoint MCP)
+func (s *sessoesHTTP) handlerEncerramento() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.encerrar(c.GetHeader(cabecalhoSessao)) {
+			c.String(http.StatusNotFound, "Sessão inexistente ou expirada.")
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// sessaoCliente retorna a sessão da requisição do transporte http, já validada pelo middleware.
+// No transporte stdio não há requisição HTTP e a sessão é única.
+func sessaoCliente(ctx context.Context) string {
+	c, ok := ctx.Value("ginContext").(*gin.Context)
+	if !ok {
+		return ""
+	}
+	return c.GetHeader(cabecalhoSessao)
+}
@@ -0,0 +1,256 @@
+package esptag
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// Formatos de saída da anotação de mensagem
+const (
+	FormatoAnotacaoXML    = "xml"
+	FormatoAnotacaoTabela = "tabela"
+)
+
+// AnotaMensagemArgs define os argumentos de entrada para o MCP
+type AnotaMensagemArgs struct {
+	XMLMensagem   string `json:"xml_mensagem" jsonschema:"required,description=Mensagem PIX completa em XML (Document, opcionalmente dentro de um envelope com AppHdr)"`
+	IDEveMensagem string `json:"id_eve_msg" jsonschema:"description=ID do evento da mensagem (ex: pacs.002.001.10). Se omitido, é identificado pelo namespace do Document ou pelo MsgDefIdr do AppHdr"`
+	Formato       string `json:"formato" jsonschema:"description=Formato da anotação: xml (comentários na própria mensagem, padrão) ou tabela"`
+}
+
+// SituacaoElemento indica como um elemento do XML foi resolvido em spi_mensagem_tag
+type SituacaoElemento string
+
+const (
+	ElementoCadastrado    SituacaoElemento = "cadastrado"     // Um registro compatível se destaca no ranking
+	ElementoIncerto       SituacaoElemento = "incerto"        // Mais de um registro compatível sem correspondência clara
+	ElementoNaoCadastrado SituacaoElemento = "nao_cadastrado" // Nenhum registro com caminho compatível
+)
+
+// ElementoAnotado é um elemento do XML com o registro de spi_mensagem_tag e as especializações correspondentes
+type ElementoAnotado struct {
+	Ocorrencia      util.TagOccurrence
+	Situacao        SituacaoElemento
+	Registro        MensagemTagInfo     // Melhor registro compatível; vazio quando não cadastrado
+	Alternativas    []MensagemTagInfo   // Demais registros compatíveis, quando a correspondência é incerta
+	TagConhecida    bool                // A tag existe na mensagem, ainda que em outro caminho
+	Especializacoes []EspecializacaoTag // Especializações vinculadas ao registro
+}
+
+// MensagemAnotada é o resultado da resolução de todos os elementos de uma mensagem
+type MensagemAnotada struct {
+	IDEveMensagem string
+	XML           string
+	Elementos     []ElementoAnotado
+	Ignorados     int // Elementos fora do Document (envelope e AppHdr), que não são resolvidos
+}
+
+// Contar retorna a quantidade de elementos na situação informada
+func (m *MensagemAnotada) Contar(situacao SituacaoElemento) int {
+	qtd := 0
+	for _, e := range m.Elementos {
+		if e.Situacao == situacao {
+			qtd++
+		}
+	}
+	return qtd
+}
+
+// QtdEspecializados retorna a quantidade de elementos vinculados a pelo menos uma especialização
+func (m *MensagemAnotada) QtdEspecializados() int {
+	qtd := 0
+	for _, e := range m.Elementos {
+		if len(e.Especializacoes) > 0 {
+			qtd++
+		}
+	}
+	return qtd
+}
+
+// AnotarMensagem resolve cada elemento do XML no registro de spi_mensagem_tag com o ranking usado na consulta
+// de dados da mensagem. Apenas registros cujo caminho reconstruído é compatível com o caminho do elemento são
+// aceitos; sem nenhum, o elemento é marcado como não cadastrado. Havendo um Document no XML, os elementos fora
+// dele (envelope e AppHdr) são ignorados.
+func AnotarMensagem(cat Catalog, scorer Scorer, xmlMensagem string, idEveMensagem string) (*MensagemAnotada, error) {
+	ocorrencias, err := util.FindAllOccurrences(xmlMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+	descricoes := make(map[int]string)
+	for _, esp := range esps {
+		descricoes[esp.ID] = esp.Descricao
+	}
+	especializacoes := make(map[int][]EspecializacaoTag)
+	for _, v := range vinculos {
+		descricao, ok := descricoes[v.IDEspecializacao]
+		if !ok {
+			descricao = "sem registro em spi_especializacao_tag"
+		}
+		especializacoes[v.NumSeqMsgTag] = append(especializacoes[v.NumSeqMsgTag], EspecializacaoTag{ID: v.IDEspecializacao, Descricao: descricao})
+	}
+
+	possuiDocument := slices.ContainsFunc(ocorrencias, func(o util.TagOccurrence) bool {
+		return o.Path[len(o.Path)-1] == ElementoRaizPadrao
+	})
+
+	anotada := &MensagemAnotada{IDEveMensagem: idEveMensagem, XML: xmlMensagem}
+
+	// Elementos com o mesmo caminho e a mesma vizinhança têm o mesmo ranking
+	rankings := make(map[string][]MensagemTagInfo)
+	for _, ocorrencia := range ocorrencias {
+		if possuiDocument && !slices.Contains(ocorrencia.Path, ElementoRaizPadrao) {
+			anotada.Ignorados++
+			continue
+		}
+
+		nomeTag := ocorrencia.Path[len(ocorrencia.Path)-1]
+		chave := strings.Join([]string{
+			strings.Join(ocorrencia.Path, "/"),
+			strings.Join(ocorrencia.Children, ","),
+			strings.Join(ocorrencia.PrecedingSiblings, ","),
+			strings.Join(ocorrencia.FollowingSiblings, ","),
+		}, "|")
+		ranking, ok := rankings[chave]
+		if !ok {
+			ranking, err = RankearTagsNaBase(cat, scorer, ocorrencia, nomeTag, idEveMensagem)
+			if err != nil {
+				return nil, err
+			}
+			rankings[chave] = ranking
+		}
+
+		elemento := ElementoAnotado{Ocorrencia: ocorrencia, Situacao: ElementoNaoCadastrado, TagConhecida: len(ranking) > 0}
+		var compativeis []MensagemTagInfo
+		for _, info := range ranking {
+			if caminhoCompativel(ocorrencia.Path, arvore.Caminho(info.NumSeqMsgTag)) {
+				compativeis = append(compativeis, info)
+			}
+		}
+		if len(compativeis) > 0 {
+			elemento.Registro = compativeis[0]
+			elemento.Especializacoes = especializacoes[compativeis[0].NumSeqMsgTag]
+			elemento.Situacao = ElementoCadastrado
+			if !CorrespondenciaClara(compativeis) {
+				elemento.Situacao = ElementoIncerto
+				elemento.Alternativas = compativeis[1:]
+			}
+		}
+		anotada.Elementos = append(anotada.Elementos, elemento)
+	}
+
+	return anotada, nil
+}
+
+// caminhoCompativel indica se um dos caminhos termina com o outro, o que admite o XML dentro de um envelope
+// e tags cadastradas sem o pai
+func caminhoCompativel(caminhoXML []string, caminhoDB []string) bool {
+	if len(caminhoDB) == 0 {
+		return false
+	}
+	n := min(len(caminhoXML), len(caminhoDB))
+	return slices.Equal(caminhoXML[len(caminhoXML)-n:], caminhoDB[len(caminhoDB)-n:])
+}
+
+// comentarios retorna os comentários que descrevem o elemento na anotação em XML; elementos cadastrados
+// sem especializações não são comentados
+func (e ElementoAnotado) comentarios(idEveMensagem string) []string {
+	var comentarios []string
+	switch e.Situacao {
+	case ElementoNaoCadastrado:
+		if e.TagConhecida {
+			comentarios = append(comentarios, fmt.Sprintf("ATENÇÃO: caminho não cadastrado em spi_mensagem_tag para %s; a tag %s existe em outra posição da mensagem",
+				idEveMensagem, e.Ocorrencia.Path[len(e.Ocorrencia.Path)-1]))
+		} else {
+			comentarios = append(comentarios, fmt.Sprintf("ATENÇÃO: tag não cadastrada em spi_mensagem_tag para %s", idEveMensagem))
+		}
+		return comentarios
+	case ElementoIncerto:
+		alternativas := make([]string, 0, len(e.Alternativas))
+		for _, info := range e.Alternativas {
+			alternativas = append(alternativas, fmt.Sprintf("%d", info.NumSeqMsgTag))
+		}
+		comentarios = append(comentarios, fmt.Sprintf("Correspondência incerta: num_seq_msg_tag %d, alternativas %s",
+			e.Registro.NumSeqMsgTag, strings.Join(alternativas, ", ")))
+	}
+	for _, esp := range e.Especializacoes {
+		comentarios = append(comentarios, fmt.Sprintf("Especialização %d: %s (num_seq_msg_tag %d)", esp.ID, esp.Descricao, e.Registro.NumSeqMsgTag))
+	}
+	return comentarios
+}
+
+// XMLAnotado retorna o XML original com os comentários de cada elemento inseridos antes da sua abertura,
+// preservando a formatação da mensagem
+func (m *MensagemAnotada) XMLAnotado() string {
+	var xml strings.Builder
+	anterior := 0
+	for _, e := range m.Elementos {
+		comentarios := e.comentarios(m.IDEveMensagem)
+		if len(comentarios) == 0 {
+			continue
+		}
+
+		inicio := int(e.Ocorrencia.Offset)
+		xml.WriteString(m.XML[anterior:inicio])
+		anterior = inicio
+
+		// Elemento no início da linha: cada comentário ocupa uma linha com o mesmo recuo
+		inicioLinha := strings.LastIndex(m.XML[:inicio], "\n") + 1
+		recuo := m.XML[inicioLinha:inicio]
+		separador := ""
+		if strings.TrimSpace(recuo) == "" {
+			separador = "\n" + recuo
+		}
+		for _, comentario := range comentarios {
+			xml.WriteString(fmt.Sprintf("<!-- %s -->%s", comentarioXML(comentario), separador))
+		}
+	}
+	xml.WriteString(m.XML[anterior:])
+	return xml.String()
+}
+
+// Tabela retorna um elemento por linha, em formato markdown, com o caminho, o valor, o registro e as especializações
+func (m *MensagemAnotada) Tabela() string {
+	var tabela strings.Builder
+	tabela.WriteString("| Caminho | Valor | num_seq_msg_tag | Especializações |\n")
+	tabela.WriteString("|---|---|---|---|\n")
+	for _, e := range m.Elementos {
+		registro := "não cadastrado"
+		if e.Situacao != ElementoNaoCadastrado {
+			registro = fmt.Sprintf("%d", e.Registro.NumSeqMsgTag)
+		}
+		if e.Situacao == ElementoIncerto {
+			registro += " (incerto)"
+		}
+
+		esps := make([]string, 0, len(e.Especializacoes))
+		for _, esp := range e.Especializacoes {
+			esps = append(esps, fmt.Sprintf("%d - %s", esp.ID, esp.Descricao))
+		}
+
+		tabela.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n", strings.Join(e.Ocorrencia.Path, " > "),
+			celulaTabela(e.Ocorrencia.Text), registro, celulaTabela(strings.Join(esps, "; "))))
+	}
+	return tabela.String()
+}
+
+// celulaTabela evita que o conteúdo quebre a linha ou as colunas da tabela markdown
+func celulaTabela(texto string) string {
+	texto = strings.Join(strings.Fields(texto), " ")
+	return strings.ReplaceAll(texto, "|", "\\|")
+}
@@ -0,0 +1,114 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+)
+
+const xmlAnotacaoTeste = `<Envelope>
+  <AppHdr><MsgDefIdr>pacs.002.001.10</MsgDefIdr></AppHdr>
+  <Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10">
+    <FIToFIPmtStsRpt>
+      <GrpHdr><MsgId>M1</MsgId><SttlmInf>X</SttlmInf></GrpHdr>
+      <TxInfAndSts>
+        <TxSts>RJCT</TxSts>
+        <OrgnlTxRef>
+          <CdtrAcct><Id><Othr><Id>12|34</Id></Othr></Id></CdtrAcct>
+        </OrgnlTxRef>
+      </TxInfAndSts>
+      <Rsn><Cd>AB03</Cd></Rsn>
+    </FIToFIPmtStsRpt>
+  </Document>
+</Envelope>`
+
+func TestAnotarMensagem(t *testing.T) {
+	anotada, err := AnotarMensagem(novoCatalogoTeste(), NovoScorer(PesosPadrao()), xmlAnotacaoTeste, "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("AnotarMensagem() error = %v", err)
+	}
+
+	// Envelope, AppHdr e MsgDefIdr ficam fora do Document
+	if anotada.Ignorados != 3 || len(anotada.Elementos) != 14 {
+		t.Fatalf("AnotarMensagem() = %d elementos, %d ignorados, want 14 e 3", len(anotada.Elementos), anotada.Ignorados)
+	}
+
+	registros := make(map[string]ElementoAnotado)
+	for _, e := range anotada.Elementos {
+		registros[strings.Join(e.Ocorrencia.Path, "/")] = e
+	}
+
+	txSts := registros["Envelope/Document/FIToFIPmtStsRpt/TxInfAndSts/TxSts"]
+	if txSts.Situacao != ElementoCadastrado || txSts.Registro.NumSeqMsgTag != 111 ||
+		len(txSts.Especializacoes) != 1 || txSts.Especializacoes[0].ID != 3 {
+		t.Errorf("TxSts = %+v, want registro 111 com a especialização 3", txSts)
+	}
+
+	// Os dois Id sob Othr são distinguidos pelo caminho completo
+	id := registros["Envelope/Document/FIToFIPmtStsRpt/TxInfAndSts/OrgnlTxRef/CdtrAcct/Id/Othr/Id"]
+	if id.Situacao != ElementoCadastrado || id.Registro.NumSeqMsgTag != 123 {
+		t.Errorf("Id da CdtrAcct = %+v, want registro 123", id)
+	}
+
+	if e := registros["Envelope/Document/FIToFIPmtStsRpt/GrpHdr/SttlmInf"]; e.Situacao != ElementoNaoCadastrado || e.TagConhecida {
+		t.Errorf("SttlmInf = %+v, want tag não cadastrada", e)
+	}
+	if e := registros["Envelope/Document/FIToFIPmtStsRpt/Rsn/Cd"]; e.Situacao != ElementoNaoCadastrado || !e.TagConhecida {
+		t.Errorf("Cd fora de StsRsnInf = %+v, want caminho não cadastrado de tag conhecida", e)
+	}
+	if anotada.Contar(ElementoNaoCadastrado) != 3 || anotada.QtdEspecializados() != 1 {
+		t.Errorf("não cadastrados = %d, especializados = %d, want 3 e 1", anotada.Contar(ElementoNaoCadastrado), anotada.QtdEspecializados())
+	}
+}
+
+func TestAnotarMensagemSaidas(t *testing.T) {
+	anotada, err := AnotarMensagem(novoCatalogoTeste(), NovoScorer(PesosPadrao()), xmlAnotacaoTeste, "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("AnotarMensagem() error = %v", err)
+	}
+
+	xml := anotada.XMLAnotado()
+	for _, trecho := range []string{
+		"        <!-- Especialização 3: Situação da Transação (num_seq_msg_tag 111) -->\n        <TxSts>RJCT</TxSts>\n",
+		"<MsgId>M1</MsgId><!-- ATENÇÃO: tag não cadastrada em spi_mensagem_tag para pacs.002.001.10 --><SttlmInf>X</SttlmInf>",
+		"      <!-- ATENÇÃO: caminho não cadastrado em spi_mensagem_tag para pacs.002.001.10; a tag Rsn existe em outra posição da mensagem -->\n      <Rsn>",
+	} {
+		if !strings.Contains(xml, trecho) {
+			t.Errorf("XMLAnotado() não contém %q:\n%s", trecho, xml)
+		}
+	}
+	if strings.Count(xml, "<!--") != 4 {
+		t.Errorf("XMLAnotado() deveria ter 4 comentários:\n%s", xml)
+	}
+
+	tabela := anotada.Tabela()
+	for _, trecho := range []string{
+		"| Envelope > Document > FIToFIPmtStsRpt > TxInfAndSts > TxSts | RJCT | 111 | 3 - Situação da Transação |\n",
+		"| 12\\|34 | 123 |  |\n",
+		"| Envelope > Document > FIToFIPmtStsRpt > GrpHdr > SttlmInf | X | não cadastrado |  |\n",
+	} {
+		if !strings.Contains(tabela, trecho) {
+			t.Errorf("Tabela() não contém %q:\n%s", trecho, tabela)
+		}
+	}
+}
+
+func TestCaminhoCompativel(t *testing.T) {
+	casos := []struct {
+		xml, db string
+		want    bool
+	}{
+		{"Envelope/Document/GrpHdr/MsgId", "Document/GrpHdr/MsgId", true},
+		{"Document/GrpHdr/MsgId", "GrpHdr/MsgId", true},
+		{"Document/GrpHdr/MsgId", "Document/OrgnlGrpInfAndSts/MsgId", false},
+		{"Document/GrpHdr/MsgId", "", false},
+	}
+	for _, c := range casos {
+		var db []string
+		if c.db != "" {
+			db = strings.Split(c.db, "/")
+		}
+		if got := caminhoCompativel(strings.Split(c.xml, "/"), db); got != c.want {
+			t.Errorf("caminhoCompativel(%s, %s) = %v, want %v", c.xml, c.db, got, c.want)
+		}
+	}
+}
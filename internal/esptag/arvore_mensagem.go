@@ -0,0 +1,213 @@
+package esptag
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ArvoreMensagem representa a estrutura completa de spi_mensagem_tag de uma mensagem,
+// carregada de uma só vez para que caminhos de qualquer profundidade sejam resolvidos em memória
+type ArvoreMensagem struct {
+	IDEveMensagem string
+	tags          []MensagemTagInfo // ordenadas por num_seq_tag, num_seq_msg_tag
+	indice        map[int]int       // num_seq_msg_tag -> posição em tags
+	pais          []int             // posição da tag pai, ou -1 para raízes
+	filhos        [][]int
+}
+
+// NovaArvoreMensagem monta a árvore a partir dos registros de spi_mensagem_tag de uma mensagem.
+//
+// spi_mensagem_tag guarda apenas o nome da tag pai, então o registro pai é escolhido entre as
+// tags com esse nome: primeiro a de mesmo num_seq_tag (critério usado historicamente na base),
+// depois a mais próxima que a precede na ordem de num_seq_tag.
+func NovaArvoreMensagem(idEveMensagem string, registros []MensagemTagInfo) *ArvoreMensagem {
+	tags := make([]MensagemTagInfo, 0, len(registros))
+	for _, r := range registros {
+		if r.IDEveMensagem == idEveMensagem {
+			tags = append(tags, r)
+		}
+	}
+	ordenarMensagemTags(tags)
+
+	arvore := &ArvoreMensagem{
+		IDEveMensagem: idEveMensagem,
+		tags:          tags,
+		indice:        make(map[int]int, len(tags)),
+		pais:          make([]int, len(tags)),
+		filhos:        make([][]int, len(tags)),
+	}
+
+	porNome := make(map[string][]int)
+	for i, t := range tags {
+		arvore.indice[t.NumSeqMsgTag] = i
+		porNome[t.IDTag] = append(porNome[t.IDTag], i)
+	}
+
+	for i, t := range tags {
+		arvore.pais[i] = -1
+		if t.IDTagPai == "" {
+			continue
+		}
+
+		candidatos := porNome[t.IDTagPai]
+		pai := -1
+		for _, c := range candidatos {
+			if c != i && tags[c].NumSeqTag == t.NumSeqTag {
+				pai = c
+				break
+			}
+		}
+		if pai == -1 {
+			for _, c := range candidatos {
+				if c < i {
+					pai = c
+				}
+			}
+		}
+
+		arvore.pais[i] = pai
+		if pai != -1 {
+			arvore.filhos[pai] = append(arvore.filhos[pai], i)
+		}
+	}
+
+	return arvore
+}
+
+// Tags retorna todos os registros da mensagem em ordem de num_seq_tag
+func (a *ArvoreMensagem) Tags() []MensagemTagInfo {
+	return append([]MensagemTagInfo(nil), a.tags...)
+}
+
+// BuscarTag retorna os registros da mensagem com o id_tag informado
+func (a *ArvoreMensagem) BuscarTag(idTag string) []MensagemTagInfo {
+	var resultados []MensagemTagInfo
+	for _, t := range a.tags {
+		if t.IDTag == idTag {
+			resultados = append(resultados, t)
+		}
+	}
+	return resultados
+}
+
+// Tag retorna o registro com o num_seq_msg_tag informado
+func (a *ArvoreMensagem) Tag(numSeqMsgTag int) (MensagemTagInfo, bool) {
+	i, ok := a.indice[numSeqMsgTag]
+	if !ok {
+		return MensagemTagInfo{}, false
+	}
+	return a.tags[i], true
+}
+
+// Pai retorna o registro pai de uma tag, se houver
+func (a *ArvoreMensagem) Pai(numSeqMsgTag int) (MensagemTagInfo, bool) {
+	i, ok := a.indice[numSeqMsgTag]
+	if !ok || a.pais[i] == -1 {
+		return MensagemTagInfo{}, false
+	}
+	return a.tags[a.pais[i]], true
+}
+
+// Filhos retorna os registros filhos de uma tag em ordem de num_seq_tag
+func (a *ArvoreMensagem) Filhos(numSeqMsgTag int) []MensagemTagInfo {
+	i, ok := a.indice[numSeqMsgTag]
+	if !ok {
+		return nil
+	}
+	filhos := make([]MensagemTagInfo, 0, len(a.filhos[i]))
+	for _, f := range a.filhos[i] {
+		filhos = append(filhos, a.tags[f])
+	}
+	return filhos
+}
+
+// Raizes retorna os registros sem pai resolvido na mensagem
+func (a *ArvoreMensagem) Raizes() []MensagemTagInfo {
+	var raizes []MensagemTagInfo
+	for i, t := range a.tags {
+		if a.pais[i] == -1 {
+			raizes = append(raizes, t)
+		}
+	}
+	return raizes
+}
+
+// Caminho retorna o caminho completo da raiz até a tag, inclusive.
+// Quando o pai não está cadastrado na mensagem, o caminho começa pelo nome do pai informado na tag.
+func (a *ArvoreMensagem) Caminho(numSeqMsgTag int) []string {
+	i, ok := a.indice[numSeqMsgTag]
+	if !ok {
+		return nil
+	}
+
+	var caminho []string
+	visitados := make(map[int]bool)
+	for i != -1 && !visitados[i] {
+		visitados[i] = true
+		caminho = append(caminho, a.tags[i].IDTag)
+		if a.pais[i] == -1 && a.tags[i].IDTagPai != "" {
+			caminho = append(caminho, a.tags[i].IDTagPai)
+		}
+		i = a.pais[i]
+	}
+
+	for l, r := 0, len(caminho)-1; l < r; l, r = l+1, r-1 {
+		caminho[l], caminho[r] = caminho[r], caminho[l]
+	}
+	return caminho
+}
+
+// cacheArvores guarda as árvores já carregadas por mensagem.
+// Com validade positiva, as árvores são recarregadas após esse intervalo, para refletir cadastros feitos na base.
+type cacheArvores struct {
+	mu       sync.Mutex
+	validade time.Duration
+	arvores  map[string]arvoreCarregada
+}
+
+// arvoreCarregada é uma árvore em cache com o instante em que foi carregada
+type arvoreCarregada struct {
+	arvore      *ArvoreMensagem
+	carregadaEm time.Time
+}
+
+// obter retorna a árvore da mensagem, carregando os registros na primeira chamada ou após a validade.
+// Mensagens sem registros não são guardadas, para que sejam recarregadas após um cadastro.
+func (c *cacheArvores) obter(idEveMensagem string, carregar func() ([]MensagemTagInfo, error)) (*ArvoreMensagem, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.arvores[idEveMensagem]; ok && (c.validade <= 0 || time.Since(item.carregadaEm) < c.validade) {
+		return item.arvore, nil
+	}
+
+	registros, err := carregar()
+	if err != nil {
+		return nil, err
+	}
+
+	arvore := NovaArvoreMensagem(idEveMensagem, registros)
+	if len(arvore.tags) > 0 {
+		if c.arvores == nil {
+			c.arvores = make(map[string]arvoreCarregada)
+		}
+		c.arvores[idEveMensagem] = arvoreCarregada{arvore: arvore, carregadaEm: time.Now()}
+	} else {
+		delete(c.arvores, idEveMensagem)
+	}
+	return arvore, nil
+}
+
+// ordenarMensagemTags ordena os registros como o ORDER BY das consultas do SQLServerCatalog
+func ordenarMensagemTags(tags []MensagemTagInfo) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].IDEveMensagem != tags[j].IDEveMensagem {
+			return tags[i].IDEveMensagem < tags[j].IDEveMensagem
+		}
+		if tags[i].NumSeqTag != tags[j].NumSeqTag {
+			return tags[i].NumSeqTag < tags[j].NumSeqTag
+		}
+		return tags[i].NumSeqMsgTag < tags[j].NumSeqMsgTag
+	})
+}
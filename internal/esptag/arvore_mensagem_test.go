@@ -0,0 +1,103 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArvoreMensagemCaminho(t *testing.T) {
+	arvore := NovaArvoreMensagem("pacs.002.001.10", tagsPacs002())
+
+	tests := []struct {
+		numSeqMsgTag int
+		want         string
+	}{
+		{101, "Document"},
+		{111, "Document > FIToFIPmtStsRpt > TxInfAndSts > TxSts"},
+		{114, "Document > FIToFIPmtStsRpt > TxInfAndSts > StsRsnInf > Rsn > Cd"},
+		{119, "Document > FIToFIPmtStsRpt > TxInfAndSts > OrgnlTxRef > DbtrAcct > Id > Othr > Id"},
+		{123, "Document > FIToFIPmtStsRpt > TxInfAndSts > OrgnlTxRef > CdtrAcct > Id > Othr > Id"},
+	}
+
+	for _, tt := range tests {
+		got := strings.Join(arvore.Caminho(tt.numSeqMsgTag), " > ")
+		if got != tt.want {
+			t.Errorf("Caminho(%d) = %q, want %q", tt.numSeqMsgTag, got, tt.want)
+		}
+	}
+
+	if caminho := arvore.Caminho(999); caminho != nil {
+		t.Errorf("Caminho(999) = %v, want nil", caminho)
+	}
+}
+
+func TestArvoreMensagemNavegacao(t *testing.T) {
+	arvore := NovaArvoreMensagem("pacs.002.001.10", tagsPacs002())
+
+	raizes := arvore.Raizes()
+	if len(raizes) != 1 || raizes[0].IDTag != "Document" {
+		t.Errorf("Raizes() = %v, want [Document]", raizes)
+	}
+
+	var nomes []string
+	for _, f := range arvore.Filhos(109) {
+		nomes = append(nomes, f.IDTag)
+	}
+	if got := strings.Join(nomes, ","); got != "OrgnlEndToEndId,TxSts,StsRsnInf,OrgnlTxRef" {
+		t.Errorf("Filhos(TxInfAndSts) = %s", got)
+	}
+
+	pai, ok := arvore.Pai(122)
+	if !ok || pai.NumSeqMsgTag != 121 {
+		t.Errorf("Pai(122) = %+v, want num_seq_msg_tag 121", pai)
+	}
+
+	if ids := arvore.BuscarTag("Id"); len(ids) != 4 {
+		t.Errorf("BuscarTag(Id) returned %d records, want 4", len(ids))
+	}
+}
+
+func TestObterArvoreMensagemCache(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	primeira, err := cat.ObterArvoreMensagem("pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("ObterArvoreMensagem() error = %v", err)
+	}
+	segunda, _ := cat.ObterArvoreMensagem("pacs.002.001.10")
+	if primeira != segunda {
+		t.Errorf("ObterArvoreMensagem() não reutilizou a árvore em cache")
+	}
+
+	caminho, err := ReconstruirCaminho(cat, MensagemTagInfo{IDEveMensagem: "pacs.002.001.10", IDTag: "Id", NumSeqMsgTag: 119})
+	if err != nil || len(caminho) != 8 {
+		t.Errorf("ReconstruirCaminho() = %v, %v, want 8 levels", caminho, err)
+	}
+}
+
+func TestCacheArvoresValidade(t *testing.T) {
+	cargas := 0
+	carregar := func() ([]MensagemTagInfo, error) {
+		cargas++
+		return tagsPacs002(), nil
+	}
+
+	// Sem validade, a árvore é carregada uma única vez
+	semValidade := &cacheArvores{}
+	semValidade.obter("pacs.002.001.10", carregar)
+	semValidade.obter("pacs.002.001.10", carregar)
+	if cargas != 1 {
+		t.Errorf("cache sem validade carregou %d vezes, want 1", cargas)
+	}
+
+	// Após a validade, a árvore é relida da base
+	cargas = 0
+	comValidade := &cacheArvores{validade: time.Millisecond}
+	comValidade.obter("pacs.002.001.10", carregar)
+	time.Sleep(2 * time.Millisecond)
+	comValidade.obter("pacs.002.001.10", carregar)
+	if cargas != 2 {
+		t.Errorf("cache com validade expirada carregou %d vezes, want 2", cargas)
+	}
+}
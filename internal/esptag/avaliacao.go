@@ -0,0 +1,296 @@
+package esptag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// CasoAvaliacao é um caso rotulado do dataset de avaliação da consulta de dados da mensagem
+type CasoAvaliacao struct {
+	Nome                 string `json:"nome"`
+	CaminhoXML           string `json:"caminho_xml"`
+	NomeTag              string `json:"nome_tag"`
+	IDEveMensagem        string `json:"id_eve_msg,omitempty"` // Se omitido, é identificado no XML como na ferramenta
+	Ocorrencia           int    `json:"ocorrencia,omitempty"` // Ocorrência da tag no XML; padrão: a primeira
+	NumSeqMsgTagEsperado int    `json:"num_seq_msg_tag_esperado"`
+}
+
+// ResultadoCaso registra o desempenho do ranking em um caso do dataset
+type ResultadoCaso struct {
+	Nome       string `json:"nome"`
+	Esperado   int    `json:"num_seq_msg_tag_esperado"`
+	Obtido     int    `json:"num_seq_msg_tag_obtido,omitempty"` // Primeiro registro do ranking
+	Posicao    int    `json:"posicao"`                          // Posição do registro esperado no ranking (1 = primeiro); 0 se ausente
+	Candidatos int    `json:"candidatos"`
+	Ambiguo    bool   `json:"ambiguo"` // Sem correspondência clara, como sinalizado pela ferramenta
+	Erro       string `json:"erro,omitempty"`
+}
+
+// RelatorioAvaliacao consolida a avaliação de um dataset
+type RelatorioAvaliacao struct {
+	GeradoEm   time.Time       `json:"gerado_em"`
+	Pesos      *PesosPontuacao `json:"pesos,omitempty"`
+	Total      int             `json:"total"`
+	Top1       int             `json:"top1"`
+	Top3       int             `json:"top3"`
+	Ambiguos   int             `json:"ambiguos"`
+	Erros      int             `json:"erros"`
+	Resultados []ResultadoCaso `json:"resultados"`
+}
+
+// ComparacaoCaso registra a mudança de posição de um caso entre duas avaliações
+type ComparacaoCaso struct {
+	Nome     string
+	Anterior int
+	Atual    int
+}
+
+// CarregarDatasetAvaliacao lê os casos rotulados de um arquivo JSON, nomeando os casos sem nome pela posição
+func CarregarDatasetAvaliacao(caminho string) ([]CasoAvaliacao, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler dataset: %v", err)
+	}
+
+	var casos []CasoAvaliacao
+	if err := json.Unmarshal(conteudo, &casos); err != nil {
+		return nil, fmt.Errorf("erro ao interpretar dataset: %v", err)
+	}
+
+	for i := range casos {
+		if casos[i].Nome == "" {
+			casos[i].Nome = fmt.Sprintf("caso %d", i+1)
+		}
+	}
+
+	return casos, nil
+}
+
+// SalvarDatasetAvaliacao grava os casos rotulados em um arquivo JSON.
+// Os trechos XML são gravados sem escape de < e >, para facilitar a edição manual do dataset.
+func SalvarDatasetAvaliacao(casos []CasoAvaliacao, caminho string) error {
+	var conteudo bytes.Buffer
+	encoder := json.NewEncoder(&conteudo)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(casos); err != nil {
+		return fmt.Errorf("erro ao serializar dataset: %v", err)
+	}
+
+	if err := os.WriteFile(caminho, conteudo.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("erro ao gravar dataset: %v", err)
+	}
+
+	return nil
+}
+
+// AvaliarCaso executa sobre um caso as mesmas etapas de sq_pix_esptag_consulta_dados_mensagem:
+// identificação da mensagem, localização da ocorrência da tag e ranking dos registros
+func AvaliarCaso(cat Catalog, scorer Scorer, caso CasoAvaliacao) ResultadoCaso {
+	resultado := ResultadoCaso{Nome: caso.Nome, Esperado: caso.NumSeqMsgTagEsperado}
+
+	idEveMensagem, _, _ := IdentificarMensagem(caso.CaminhoXML, caso.IDEveMensagem)
+	if idEveMensagem == "" {
+		resultado.Erro = "id_eve_msg não informado e não identificado no XML"
+		return resultado
+	}
+
+	ocorrencias, err := util.ResolveTagOccurrences(caso.CaminhoXML, caso.NomeTag)
+	if err != nil {
+		resultado.Erro = err.Error()
+		return resultado
+	}
+	indice := 0
+	if caso.Ocorrencia > 0 {
+		if caso.Ocorrencia > len(ocorrencias) {
+			resultado.Erro = fmt.Sprintf("ocorrência %d inválida: a tag aparece %d vez(es) no XML", caso.Ocorrencia, len(ocorrencias))
+			return resultado
+		}
+		indice = caso.Ocorrencia - 1
+	}
+
+	ranking, err := RankearTagsNaBase(cat, scorer, ocorrencias[indice], caso.NomeTag, idEveMensagem)
+	if err != nil {
+		resultado.Erro = err.Error()
+		return resultado
+	}
+
+	resultado.Candidatos = len(ranking)
+	resultado.Ambiguo = len(ranking) > 0 && !CorrespondenciaClara(ranking)
+	if len(ranking) > 0 {
+		resultado.Obtido = ranking[0].NumSeqMsgTag
+	}
+	for i, r := range ranking {
+		if r.NumSeqMsgTag == caso.NumSeqMsgTagEsperado {
+			resultado.Posicao = i + 1
+			break
+		}
+	}
+
+	return resultado
+}
+
+// Avaliar executa todos os casos do dataset e consolida top-1, top-3, casos ambíguos e erros
+func Avaliar(cat Catalog, scorer Scorer, casos []CasoAvaliacao) *RelatorioAvaliacao {
+	relatorio := &RelatorioAvaliacao{GeradoEm: time.Now(), Total: len(casos)}
+
+	for _, caso := range casos {
+		resultado := AvaliarCaso(cat, scorer, caso)
+		relatorio.Resultados = append(relatorio.Resultados, resultado)
+
+		switch {
+		case resultado.Erro != "":
+			relatorio.Erros++
+			continue
+		case resultado.Posicao == 1:
+			relatorio.Top1++
+			relatorio.Top3++
+		case resultado.Posicao > 1 && resultado.Posicao <= 3:
+			relatorio.Top3++
+		}
+		if resultado.Ambiguo {
+			relatorio.Ambiguos++
+		}
+	}
+
+	return relatorio
+}
+
+// Acuracia retorna a proporção de acertos em relação ao total de casos
+func (r *RelatorioAvaliacao) Acuracia(acertos int) float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(acertos) / float64(r.Total)
+}
+
+// SalvarRelatorioAvaliacao grava o relatório em um arquivo JSON, para comparação em execuções futuras
+func SalvarRelatorioAvaliacao(relatorio *RelatorioAvaliacao, caminho string) error {
+	conteudo, err := json.MarshalIndent(relatorio, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar relatório: %v", err)
+	}
+
+	if err := os.WriteFile(caminho, conteudo, 0o644); err != nil {
+		return fmt.Errorf("erro ao gravar relatório: %v", err)
+	}
+
+	return nil
+}
+
+// CarregarRelatorioAvaliacao lê o relatório de uma execução anterior
+func CarregarRelatorioAvaliacao(caminho string) (*RelatorioAvaliacao, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler relatório: %v", err)
+	}
+
+	var relatorio RelatorioAvaliacao
+	if err := json.Unmarshal(conteudo, &relatorio); err != nil {
+		return nil, fmt.Errorf("erro ao interpretar relatório: %v", err)
+	}
+
+	return &relatorio, nil
+}
+
+// CompararAvaliacoes compara, pelo nome do caso, a posição do registro esperado em duas avaliações.
+// Casos que pioraram (ou passaram a falhar) são regressões; os que melhoraram, melhorias.
+func CompararAvaliacoes(anterior, atual *RelatorioAvaliacao) (regressoes, melhorias []ComparacaoCaso) {
+	posicoesAnteriores := make(map[string]int)
+	for _, r := range anterior.Resultados {
+		posicoesAnteriores[r.Nome] = r.Posicao
+	}
+
+	// Posição 0 (registro esperado ausente ou erro) é pior que qualquer posição no ranking
+	classificar := func(posicao int) int {
+		if posicao == 0 {
+			return int(^uint(0) >> 1)
+		}
+		return posicao
+	}
+
+	for _, r := range atual.Resultados {
+		posicaoAnterior, ok := posicoesAnteriores[r.Nome]
+		if !ok {
+			continue
+		}
+		comparacao := ComparacaoCaso{Nome: r.Nome, Anterior: posicaoAnterior, Atual: r.Posicao}
+		switch {
+		case classificar(r.Posicao) > classificar(posicaoAnterior):
+			regressoes = append(regressoes, comparacao)
+		case classificar(r.Posicao) < classificar(posicaoAnterior):
+			melhorias = append(melhorias, comparacao)
+		}
+	}
+
+	return regressoes, melhorias
+}
+
+// GerarDatasetDosVinculos cria casos rotulados a partir dos vínculos de spi_especializacao_msg_tag.
+// Cada tag vinculada gera um caso com um trecho XML formado pelos últimos níveis do seu caminho
+// (todos, se niveis <= 0), tendo a própria tag como registro esperado.
+func GerarDatasetDosVinculos(cat Catalog, niveis int) ([]CasoAvaliacao, error) {
+	vinculos, err := cat.ListarVinculos()
+	if err != nil {
+		return nil, err
+	}
+
+	var casos []CasoAvaliacao
+	vistos := make(map[string]bool)
+	for _, v := range vinculos {
+		chave := fmt.Sprintf("%s/%d", v.IDEveMensagem, v.NumSeqMsgTag)
+		if vistos[chave] {
+			continue
+		}
+		vistos[chave] = true
+
+		arvore, err := cat.ObterArvoreMensagem(v.IDEveMensagem)
+		if err != nil {
+			return nil, err
+		}
+		caminho := arvore.Caminho(v.NumSeqMsgTag)
+		if caminho == nil {
+			continue
+		}
+		if niveis > 0 && len(caminho) > niveis {
+			caminho = caminho[len(caminho)-niveis:]
+		}
+
+		// A tag vinculada é a última do caminho; nomes repetidos (ex: Id > Othr > Id) exigem a ocorrência
+		ocorrencia := 0
+		for _, tag := range caminho {
+			if tag == v.IDTag {
+				ocorrencia++
+			}
+		}
+
+		casos = append(casos, CasoAvaliacao{
+			Nome:                 fmt.Sprintf("%s %s", chave, strings.Join(caminho, " > ")),
+			CaminhoXML:           trechoXMLCaminho(caminho),
+			NomeTag:              v.IDTag,
+			IDEveMensagem:        v.IDEveMensagem,
+			Ocorrencia:           ocorrencia,
+			NumSeqMsgTagEsperado: v.NumSeqMsgTag,
+		})
+	}
+
+	return casos, nil
+}
+
+// trechoXMLCaminho monta um trecho XML com as tags do caminho aninhadas
+func trechoXMLCaminho(caminho []string) string {
+	var trecho strings.Builder
+	for _, tag := range caminho {
+		trecho.WriteString("<" + tag + ">")
+	}
+	for i := len(caminho) - 1; i >= 0; i-- {
+		trecho.WriteString("</" + caminho[i] + ">")
+	}
+	return trecho.String()
+}
@@ -0,0 +1,86 @@
+package esptag
+
+import (
+	"testing"
+)
+
+func TestGerarDatasetDosVinculos(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	casos, err := GerarDatasetDosVinculos(cat, 2)
+	if err != nil {
+		t.Fatalf("GerarDatasetDosVinculos() error = %v", err)
+	}
+	if len(casos) != 2 {
+		t.Fatalf("GerarDatasetDosVinculos() returned %d casos, want 2", len(casos))
+	}
+	if casos[0].CaminhoXML != "<TxInfAndSts><TxSts></TxSts></TxInfAndSts>" || casos[0].NumSeqMsgTagEsperado != 111 {
+		t.Errorf("GerarDatasetDosVinculos()[0] = %+v, want trecho TxInfAndSts > TxSts esperando 111", casos[0])
+	}
+
+	// O próprio dataset gerado deve ser acertado pelo scorer padrão
+	relatorio := Avaliar(cat, NovoScorer(PesosPadrao()), casos)
+	if relatorio.Top1 != 2 || relatorio.Erros != 0 {
+		t.Errorf("Avaliar() = top-1 %d, erros %d, want top-1 2 e nenhum erro", relatorio.Top1, relatorio.Erros)
+	}
+}
+
+func TestGerarDatasetDosVinculosTagRepetida(t *testing.T) {
+	cat := NewMemoryCatalog(DadosCatalogo{
+		MensagemTags: tagsPacs002(),
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 9, IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "Id", NumSeqTag: 19, NumSeqMsgTag: 119},
+		},
+	})
+
+	casos, err := GerarDatasetDosVinculos(cat, 4)
+	if err != nil {
+		t.Fatalf("GerarDatasetDosVinculos() error = %v", err)
+	}
+	if len(casos) != 1 || casos[0].CaminhoXML != "<DbtrAcct><Id><Othr><Id></Id></Othr></Id></DbtrAcct>" || casos[0].Ocorrencia != 2 {
+		t.Fatalf("GerarDatasetDosVinculos() = %+v, want DbtrAcct > Id > Othr > Id na ocorrência 2", casos)
+	}
+
+	// O caso avalia o Id interno, e não o primeiro Id do trecho
+	if r := AvaliarCaso(cat, NovoScorer(PesosPadrao()), casos[0]); r.Posicao != 1 || r.Obtido != 119 {
+		t.Errorf("AvaliarCaso() = %+v, want 119 na posição 1", r)
+	}
+}
+
+func TestAvaliarECompararAvaliacoes(t *testing.T) {
+	cat := novoCatalogoTeste()
+	casos := []CasoAvaliacao{
+		{Nome: "situacao", CaminhoXML: "<TxInfAndSts><TxSts>ACSC</TxSts></TxInfAndSts>", NomeTag: "TxSts", IDEveMensagem: "pacs.002.001.10", NumSeqMsgTagEsperado: 111},
+		{Nome: "conta credor", CaminhoXML: "<Othr><Id>123</Id></Othr>", NomeTag: "Id", IDEveMensagem: "pacs.002.001.10", NumSeqMsgTagEsperado: 123},
+		{Nome: "sem mensagem", CaminhoXML: "<TxSts/>", NomeTag: "TxSts", NumSeqMsgTagEsperado: 111},
+	}
+
+	anterior := Avaliar(cat, NovoScorer(PesosPadrao()), casos)
+	if anterior.Top1 != 1 || anterior.Top3 != 2 || anterior.Ambiguos != 1 || anterior.Erros != 1 {
+		t.Fatalf("Avaliar() = top-1 %d, top-3 %d, ambíguos %d, erros %d, want 1, 2, 1 e 1",
+			anterior.Top1, anterior.Top3, anterior.Ambiguos, anterior.Erros)
+	}
+	if r := anterior.Resultados[1]; r.Posicao != 2 || r.Obtido != 119 {
+		t.Errorf("Avaliar() conta credor = %+v, want posição 2 atrás de 119", r)
+	}
+
+	// Sem o peso da tag pai, o caso da situação continua no topo e nada piora
+	pesos := PesosPadrao()
+	pesos.TagPai = 0
+	atual := Avaliar(cat, NovoScorer(pesos), casos)
+	regressoes, melhorias := CompararAvaliacoes(anterior, atual)
+	if len(regressoes) != 0 || len(melhorias) != 0 {
+		t.Errorf("CompararAvaliacoes() = %v, %v, want nenhuma variação", regressoes, melhorias)
+	}
+
+	// Um caso que sai do ranking é regressão; o inverso, melhoria
+	atual.Resultados[0].Posicao = 0
+	regressoes, melhorias = CompararAvaliacoes(anterior, atual)
+	if len(regressoes) != 1 || regressoes[0].Nome != "situacao" || len(melhorias) != 0 {
+		t.Errorf("CompararAvaliacoes() regressões = %v, want [situacao]", regressoes)
+	}
+	regressoes, melhorias = CompararAvaliacoes(atual, anterior)
+	if len(melhorias) != 1 || len(regressoes) != 0 {
+		t.Errorf("CompararAvaliacoes() melhorias = %v, want [situacao]", melhorias)
+	}
+}
@@ -0,0 +1,291 @@
+package esptag
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+const (
+	// LimitePadraoBusca é a quantidade de especializações por página quando nenhum limite é informado
+	LimitePadraoBusca = 20
+	// RelevanciaMinimaBusca é a relevância mínima (0 a 100) para uma especialização ser retornada na busca
+	RelevanciaMinimaBusca = 40
+	// RelevanciaMinimaSimilar é a relevância mínima para uma especialização ser apontada como similar
+	// a uma nova descrição
+	RelevanciaMinimaSimilar = 60
+)
+
+// abreviacoesISO expande as abreviações dos nomes de tags ISO 20022 para os termos, em inglês e
+// português, usados nas descrições das especializações. Permite buscar por "TxSts" ou "OrgnlEndToEndId".
+var abreviacoesISO = map[string][]string{
+	"acct":  {"account", "conta"},
+	"addtl": {"additional", "adicional"},
+	"adr":   {"address", "endereco"},
+	"agt":   {"agent", "agente"},
+	"amt":   {"amount", "valor"},
+	"bal":   {"balance", "saldo"},
+	"ccy":   {"currency", "moeda"},
+	"cd":    {"code", "codigo"},
+	"cdt":   {"credit", "credito"},
+	"cdtr":  {"creditor", "credor", "recebedor"},
+	"chrgs": {"charges", "tarifa"},
+	"cre":   {"creation", "criacao"},
+	"ctct":  {"contact", "contato"},
+	"dbt":   {"debit", "debito"},
+	"dbtr":  {"debtor", "devedor", "pagador"},
+	"dt":    {"date", "data"},
+	"grp":   {"group", "grupo"},
+	"hdr":   {"header", "cabecalho"},
+	"id":    {"identification", "identificacao", "identificador"},
+	"inf":   {"information", "informacao"},
+	"instr": {"instruction", "instrucao"},
+	"msg":   {"message", "mensagem"},
+	"nb":    {"number", "numero"},
+	"nm":    {"name", "nome"},
+	"orgnl": {"original"},
+	"othr":  {"other", "outro"},
+	"pmt":   {"payment", "pagamento"},
+	"prtry": {"proprietary", "proprietario"},
+	"pty":   {"party", "parte"},
+	"purp":  {"purpose", "finalidade"},
+	"ref":   {"reference", "referencia"},
+	"rmt":   {"remittance", "remessa"},
+	"rpt":   {"report", "relatorio"},
+	"rsn":   {"reason", "motivo"},
+	"rtr":   {"return", "devolucao"},
+	"sttlm": {"settlement", "liquidacao"},
+	"sts":   {"status", "situacao"},
+	"tm":    {"time", "hora"},
+	"tp":    {"type", "tipo"},
+	"tx":    {"transaction", "transacao"},
+}
+
+// gruposSinonimos reúne palavras usadas com o mesmo sentido nas descrições das especializações.
+// A primeira palavra de cada grupo é a forma canônica.
+var gruposSinonimos = [][]string{
+	{"situacao", "status", "estado"},
+	{"motivo", "razao", "causa"},
+	{"identificador", "identificacao"},
+	{"pagador", "devedor"},
+	{"recebedor", "credor", "beneficiario"},
+	{"transacao", "operacao"},
+	{"valor", "montante", "quantia"},
+	{"devolucao", "estorno"},
+	{"participante", "instituicao", "psp"},
+	{"numero", "num", "nr"},
+	{"descricao", "dsc"},
+}
+
+// sinonimos mapeia cada palavra de gruposSinonimos para a sua forma canônica
+var sinonimos = func() map[string]string {
+	m := make(map[string]string)
+	for _, grupo := range gruposSinonimos {
+		for _, palavra := range grupo {
+			m[palavra] = grupo[0]
+		}
+	}
+	return m
+}()
+
+// palavrasIrrelevantes são descartadas na comparação de descrições
+var palavrasIrrelevantes = map[string]bool{
+	"a": true, "o": true, "as": true, "os": true, "e": true,
+	"de": true, "da": true, "do": true, "das": true, "dos": true,
+	"em": true, "na": true, "no": true, "nas": true, "nos": true,
+	"para": true, "por": true, "com": true,
+	"the": true, "of": true, "and": true,
+}
+
+// OpcoesBuscaEspecializacao controla a paginação e o corte de relevância da busca de especializações
+type OpcoesBuscaEspecializacao struct {
+	Limite           int // Especializações por página (LimitePadraoBusca se <= 0)
+	Pagina           int // Página desejada, a partir de 1
+	RelevanciaMinima int // Relevância mínima (RelevanciaMinimaBusca se <= 0)
+}
+
+// EspecializacaoRelevante é uma especialização encontrada na busca com a sua relevância (0 a 100)
+type EspecializacaoRelevante struct {
+	EspecializacaoTag
+	Relevancia int `json:"relevancia"`
+}
+
+// ResultadoBuscaEspecializacao é uma página do resultado da busca de especializações
+type ResultadoBuscaEspecializacao struct {
+	Total           int                       // Total de especializações encontradas, em todas as páginas
+	Pagina          int                       // Página retornada
+	TotalPaginas    int                       // Quantidade de páginas
+	Inicio          int                       // Posição, no ranking completo, da primeira especialização da página (a partir de 0)
+	Especializacoes []EspecializacaoRelevante // Especializações da página, da mais relevante para a menos relevante
+}
+
+// BuscarEspecializacoes busca as especializações pela descrição, ignorando acentos, maiúsculas e a
+// ordem das palavras, e as ordena por relevância. Retorna a página pedida em opcoes.
+func BuscarEspecializacoes(cat Catalog, termo string, opcoes OpcoesBuscaEspecializacao) (*ResultadoBuscaEspecializacao, error) {
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+
+	ranking := RankearEspecializacoes(esps, termo, opcoes.RelevanciaMinima)
+
+	limite := opcoes.Limite
+	if limite <= 0 {
+		limite = LimitePadraoBusca
+	}
+	pagina := opcoes.Pagina
+	if pagina <= 0 {
+		pagina = 1
+	}
+
+	resultado := &ResultadoBuscaEspecializacao{
+		Total:        len(ranking),
+		Pagina:       pagina,
+		TotalPaginas: (len(ranking) + limite - 1) / limite,
+		Inicio:       (pagina - 1) * limite,
+	}
+	if resultado.Inicio < len(ranking) {
+		fim := min(resultado.Inicio+limite, len(ranking))
+		resultado.Especializacoes = ranking[resultado.Inicio:fim]
+	}
+
+	return resultado, nil
+}
+
+// RankearEspecializacoes calcula a relevância de cada especialização para o termo e retorna as que
+// atingem a relevância mínima, da mais relevante para a menos relevante (empates pelo ID)
+func RankearEspecializacoes(esps []EspecializacaoTag, termo string, relevanciaMinima int) []EspecializacaoRelevante {
+	if relevanciaMinima <= 0 {
+		relevanciaMinima = RelevanciaMinimaBusca
+	}
+
+	var ranking []EspecializacaoRelevante
+	for _, esp := range esps {
+		relevancia := RelevanciaDescricao(termo, esp.Descricao)
+		if relevancia >= relevanciaMinima {
+			ranking = append(ranking, EspecializacaoRelevante{EspecializacaoTag: esp, Relevancia: relevancia})
+		}
+	}
+
+	sort.SliceStable(ranking, func(i, j int) bool {
+		if ranking[i].Relevancia != ranking[j].Relevancia {
+			return ranking[i].Relevancia > ranking[j].Relevancia
+		}
+		return ranking[i].ID < ranking[j].ID
+	})
+
+	return ranking
+}
+
+// RelevanciaDescricao mede, de 0 a 100, o quanto a descrição corresponde ao termo de busca.
+// Cada palavra do termo é comparada à palavra mais parecida da descrição (igualdade, sinônimo, prefixo,
+// trecho ou distância de edição, considerando as abreviações ISO 20022). A cobertura do termo vale até 80
+// pontos e a parte da descrição coberta pelo termo, até 20, favorecendo descrições mais específicas.
+func RelevanciaDescricao(termo, descricao string) int {
+	palavrasTermo := palavrasRelevantes(termo)
+	palavrasDescricao := palavrasRelevantes(descricao)
+	if len(palavrasTermo) == 0 || len(palavrasDescricao) == 0 {
+		return 0
+	}
+
+	cobertura := 0.0
+	cobertas := make([]bool, len(palavrasDescricao))
+	for _, palavra := range palavrasTermo {
+		melhor, indice := 0.0, -1
+		for i, candidata := range palavrasDescricao {
+			if s := similaridadePalavras(palavra, candidata); s > melhor {
+				melhor, indice = s, i
+			}
+		}
+		cobertura += melhor
+		if indice >= 0 {
+			cobertas[indice] = true
+		}
+	}
+	cobertura /= float64(len(palavrasTermo))
+
+	qtdCobertas := 0
+	for _, coberta := range cobertas {
+		if coberta {
+			qtdCobertas++
+		}
+	}
+	precisao := float64(qtdCobertas) / float64(len(palavrasDescricao))
+
+	return int(math.Round(80*cobertura + 20*precisao))
+}
+
+// palavrasRelevantes retorna as palavras normalizadas do texto, sem as palavras irrelevantes.
+// Se o texto só tiver palavras irrelevantes, elas são mantidas.
+func palavrasRelevantes(texto string) []string {
+	palavras := util.Tokenize(texto)
+
+	var relevantes []string
+	for _, palavra := range palavras {
+		if !palavrasIrrelevantes[palavra] {
+			relevantes = append(relevantes, palavra)
+		}
+	}
+	if len(relevantes) == 0 {
+		return palavras
+	}
+	return relevantes
+}
+
+// similaridadePalavras compara duas palavras normalizadas, e as suas expansões de abreviações ISO 20022,
+// retornando 1 para palavras iguais ou sinônimas e valores menores para prefixos, trechos e palavras com erros de digitação
+func similaridadePalavras(a, b string) float64 {
+	melhor := 0.0
+	for _, x := range expandirAbreviacao(a) {
+		for _, y := range expandirAbreviacao(b) {
+			melhor = math.Max(melhor, similaridadeTermos(x, y))
+		}
+	}
+	return melhor
+}
+
+// expandirAbreviacao retorna a palavra seguida dos termos que a abreviação ISO 20022 representa
+func expandirAbreviacao(palavra string) []string {
+	return append([]string{palavra}, abreviacoesISO[palavra]...)
+}
+
+// palavraCanonica retorna a forma canônica da palavra, se ela tiver sinônimos
+func palavraCanonica(palavra string) string {
+	if canonica, ok := sinonimos[palavra]; ok {
+		return canonica
+	}
+	return palavra
+}
+
+// similaridadeTermos compara duas palavras sem considerar abreviações
+func similaridadeTermos(a, b string) float64 {
+	if a == b || palavraCanonica(a) == palavraCanonica(b) {
+		return 1
+	}
+
+	menor, maior := a, b
+	if len([]rune(menor)) > len([]rune(maior)) {
+		menor, maior = maior, menor
+	}
+	tamanhoMenor, tamanhoMaior := len([]rune(menor)), len([]rune(maior))
+
+	// Palavra incompleta ("situ" para "situacao") ou trecho de palavra, como no LIKE '%termo%'
+	if tamanhoMenor >= 3 && strings.HasPrefix(maior, menor) {
+		return 0.85
+	}
+	if tamanhoMenor >= 4 && strings.Contains(maior, menor) {
+		return 0.7
+	}
+
+	// Erros de digitação: até uma edição a cada quatro letras
+	if tamanhoMaior >= 4 {
+		similaridade := 1 - float64(util.EditDistance(a, b))/float64(tamanhoMaior)
+		if similaridade >= 0.75 {
+			return 0.9 * similaridade
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,93 @@
+package esptag
+
+import (
+	"testing"
+)
+
+func novoCatalogoBusca() *MemoryCatalog {
+	return NewMemoryCatalog(DadosCatalogo{
+		Especializacoes: []EspecializacaoTag{
+			{ID: 1, Descricao: "Situação do grupo"},
+			{ID: 2, Descricao: "Situação da Transação"},
+			{ID: 3, Descricao: "Motivo da devolução"},
+			{ID: 4, Descricao: "Conta do pagador"},
+			{ID: 5, Descricao: "Conta do recebedor"},
+			{ID: 6, Descricao: "Identificador da transação original"},
+		},
+	})
+}
+
+func TestBuscarEspecializacoes(t *testing.T) {
+	cat := novoCatalogoBusca()
+
+	tests := []struct {
+		name    string
+		termo   string
+		wantIDs []int
+	}{
+		{"Sem acentos e fora de ordem", "transacao situacao", []int{2, 1, 6}},
+		{"Erro de digitação", "sitaucao transacao", []int{2, 6}},
+		{"Palavra incompleta", "devol", []int{3}},
+		{"Abreviação ISO 20022", "TxSts", []int{2, 1, 6}},
+		{"Abreviações de conta", "DbtrAcct", []int{4, 5}},
+		{"Sem resultados", "liquidação", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			busca, err := BuscarEspecializacoes(cat, tt.termo, OpcoesBuscaEspecializacao{})
+			if err != nil {
+				t.Fatalf("BuscarEspecializacoes() error = %v", err)
+			}
+			var ids []int
+			for _, esp := range busca.Especializacoes {
+				ids = append(ids, esp.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("BuscarEspecializacoes(%q) = %v, want IDs %v", tt.termo, busca.Especializacoes, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("BuscarEspecializacoes(%q) = %v, want IDs %v", tt.termo, busca.Especializacoes, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestBuscarEspecializacoesPaginacao(t *testing.T) {
+	cat := novoCatalogoBusca()
+
+	busca, err := BuscarEspecializacoes(cat, "situação transação", OpcoesBuscaEspecializacao{Limite: 2, Pagina: 2})
+	if err != nil {
+		t.Fatalf("BuscarEspecializacoes() error = %v", err)
+	}
+	if busca.Total != 3 || busca.TotalPaginas != 2 || busca.Inicio != 2 {
+		t.Errorf("BuscarEspecializacoes() = total %d, páginas %d, início %d, want 3, 2 e 2", busca.Total, busca.TotalPaginas, busca.Inicio)
+	}
+	if len(busca.Especializacoes) != 1 || busca.Especializacoes[0].ID != 6 {
+		t.Errorf("BuscarEspecializacoes() página 2 = %v, want [6]", busca.Especializacoes)
+	}
+
+	busca, _ = BuscarEspecializacoes(cat, "situação transação", OpcoesBuscaEspecializacao{Limite: 2, Pagina: 3})
+	if len(busca.Especializacoes) != 0 {
+		t.Errorf("BuscarEspecializacoes() página 3 = %v, want vazia", busca.Especializacoes)
+	}
+}
+
+func TestRelevanciaDescricaoSimilar(t *testing.T) {
+	// A descrição idêntica, a menos de acentos, tem relevância máxima
+	if r := RelevanciaDescricao("SITUACAO DA TRANSACAO", "Situação da Transação"); r != 100 {
+		t.Errorf("RelevanciaDescricao() = %d, want 100", r)
+	}
+	// Descrições que compartilham apenas uma palavra não são apontadas como similares
+	if r := RelevanciaDescricao("Situação do pagamento", "Situação do grupo"); r >= RelevanciaMinimaSimilar {
+		t.Errorf("RelevanciaDescricao() = %d, want < %d", r, RelevanciaMinimaSimilar)
+	}
+}
+
+func TestRelevanciaDescricaoSinonimos(t *testing.T) {
+	if r := RelevanciaDescricao("Status da operação", "Situação da Transação"); r != 100 {
+		t.Errorf("RelevanciaDescricao() = %d, want 100", r)
+	}
+}
@@ -0,0 +1,50 @@
+package esptag
+
+// Catalog abstrai o acesso às tabelas spi_* utilizadas pelas ferramentas MCP.
+// Possui uma implementação sobre SQL Server (SQLServerCatalog) e outra em
+// memória (MemoryCatalog), permitindo testar as ferramentas sem banco de dados.
+type Catalog interface {
+	// spi_mensagem_tag
+
+	// ListarMensagemTags retorna todos os registros de spi_mensagem_tag
+	ListarMensagemTags() ([]MensagemTagInfo, error)
+	// ListarMensagens retorna as mensagens cadastradas com a quantidade de tags, ordenadas por id_eve_msg
+	ListarMensagens() ([]MensagemResumo, error)
+	// ObterArvoreMensagem retorna a estrutura completa de uma mensagem, carregada uma única vez e mantida em cache
+	ObterArvoreMensagem(idEveMensagem string) (*ArvoreMensagem, error)
+	// ObterProximoNumSeqMsgTag retorna o próximo num_seq_msg_tag disponível, que é único em toda a tabela
+	ObterProximoNumSeqMsgTag() (int, error)
+
+	// spi_especializacao_tag
+
+	// ListarEspecializacoes retorna todas as especializações
+	ListarEspecializacoes() ([]EspecializacaoTag, error)
+	// ConsultaEspecializacaoPorID retorna uma especialização pelo ID, ou nil se não existir
+	ConsultaEspecializacaoPorID(id int) (*EspecializacaoTag, error)
+	// ObterProximoID retorna o próximo ID disponível para especialização
+	ObterProximoID() (int, error)
+
+	// spi_especializacao_msg_tag
+
+	// ListarVinculos retorna todos os vínculos entre especializações e tags de mensagens
+	ListarVinculos() ([]EspecializacaoMsgTag, error)
+	// ListarVinculosEspecializacao retorna os vínculos de uma especialização com tags de mensagens
+	ListarVinculosEspecializacao(idEspTag int) ([]EspecializacaoMsgTag, error)
+	// ListarVinculosMensagem retorna os vínculos das tags de uma mensagem com especializações
+	ListarVinculosMensagem(idEveMensagem string) ([]EspecializacaoMsgTag, error)
+
+	// spi_sit_msg_emi_des
+
+	// ListarSitMsgEmiDes retorna todos os registros de spi_sit_msg_emi_des
+	ListarSitMsgEmiDes() ([]SitMsgEmiDes, error)
+	// VerificarSitMsgEmiDesExistente verifica se um registro existe pela chave composta
+	VerificarSitMsgEmiDesExistente(idSitMsgEmiDes string, idTipEmiDes int, idSitMsg int) (bool, error)
+}
+
+// DadosCatalogo agrupa o conteúdo das tabelas spi_* mantido por um MemoryCatalog
+type DadosCatalogo struct {
+	MensagemTags    []MensagemTagInfo      `json:"spi_mensagem_tag"`
+	Especializacoes []EspecializacaoTag    `json:"spi_especializacao_tag"`
+	Vinculos        []EspecializacaoMsgTag `json:"spi_especializacao_msg_tag"`
+	SitMsgEmiDes    []SitMsgEmiDes         `json:"spi_sit_msg_emi_des"`
+}
@@ -0,0 +1,166 @@
+package esptag
+
+import "sort"
+
+// MemoryCatalog implementa Catalog sobre dados mantidos em memória
+type MemoryCatalog struct {
+	dados   DadosCatalogo
+	arvores cacheArvores
+}
+
+// NewMemoryCatalog cria um Catalog a partir do conteúdo das tabelas spi_*
+func NewMemoryCatalog(dados DadosCatalogo) *MemoryCatalog {
+	return &MemoryCatalog{dados: dados}
+}
+
+// ListarMensagemTags retorna todos os registros de spi_mensagem_tag
+func (c *MemoryCatalog) ListarMensagemTags() ([]MensagemTagInfo, error) {
+	tags := append([]MensagemTagInfo(nil), c.dados.MensagemTags...)
+	ordenarMensagemTags(tags)
+	return tags, nil
+}
+
+// ListarMensagens agrupa os registros de spi_mensagem_tag por mensagem
+func (c *MemoryCatalog) ListarMensagens() ([]MensagemResumo, error) {
+	indice := make(map[[2]string]int)
+	var mensagens []MensagemResumo
+	for _, t := range c.dados.MensagemTags {
+		chave := [2]string{t.IDEveMensagem, t.IDTipMensagem}
+		i, ok := indice[chave]
+		if !ok {
+			i = len(mensagens)
+			indice[chave] = i
+			mensagens = append(mensagens, MensagemResumo{IDEveMensagem: t.IDEveMensagem, IDTipMensagem: t.IDTipMensagem})
+		}
+		mensagens[i].QtdTags++
+	}
+
+	sort.Slice(mensagens, func(i, j int) bool {
+		if mensagens[i].IDEveMensagem != mensagens[j].IDEveMensagem {
+			return mensagens[i].IDEveMensagem < mensagens[j].IDEveMensagem
+		}
+		return mensagens[i].IDTipMensagem < mensagens[j].IDTipMensagem
+	})
+	return mensagens, nil
+}
+
+// ObterArvoreMensagem monta a árvore da mensagem a partir dos registros em memória
+func (c *MemoryCatalog) ObterArvoreMensagem(idEveMensagem string) (*ArvoreMensagem, error) {
+	return c.arvores.obter(idEveMensagem, func() ([]MensagemTagInfo, error) {
+		return c.dados.MensagemTags, nil
+	})
+}
+
+// ObterProximoNumSeqMsgTag retorna o próximo num_seq_msg_tag disponível
+func (c *MemoryCatalog) ObterProximoNumSeqMsgTag() (int, error) {
+	maior := 0
+	for _, t := range c.dados.MensagemTags {
+		if t.NumSeqMsgTag > maior {
+			maior = t.NumSeqMsgTag
+		}
+	}
+	return maior + 1, nil
+}
+
+// ListarEspecializacoes retorna todas as especializações ordenadas pelo ID
+func (c *MemoryCatalog) ListarEspecializacoes() ([]EspecializacaoTag, error) {
+	esps := append([]EspecializacaoTag(nil), c.dados.Especializacoes...)
+	sort.SliceStable(esps, func(i, j int) bool { return esps[i].ID < esps[j].ID })
+	return esps, nil
+}
+
+// ConsultaEspecializacaoPorID retorna uma especialização específica pelo seu ID
+func (c *MemoryCatalog) ConsultaEspecializacaoPorID(id int) (*EspecializacaoTag, error) {
+	for _, esp := range c.dados.Especializacoes {
+		if esp.ID == id {
+			encontrada := esp
+			return &encontrada, nil
+		}
+	}
+	return nil, nil
+}
+
+// ObterProximoID retorna o próximo ID disponível para especialização
+func (c *MemoryCatalog) ObterProximoID() (int, error) {
+	maiorID := 0
+	for _, esp := range c.dados.Especializacoes {
+		if esp.ID > maiorID {
+			maiorID = esp.ID
+		}
+	}
+	return maiorID + 1, nil
+}
+
+// ListarVinculosEspecializacao retorna os vínculos de uma especialização
+func (c *MemoryCatalog) ListarVinculosEspecializacao(idEspTag int) ([]EspecializacaoMsgTag, error) {
+	var vinculos []EspecializacaoMsgTag
+	for _, v := range c.dados.Vinculos {
+		if v.IDEspecializacao == idEspTag {
+			vinculos = append(vinculos, v)
+		}
+	}
+
+	ordenarVinculos(vinculos)
+	return vinculos, nil
+}
+
+// ListarVinculosMensagem retorna os vínculos das tags de uma mensagem
+func (c *MemoryCatalog) ListarVinculosMensagem(idEveMensagem string) ([]EspecializacaoMsgTag, error) {
+	var vinculos []EspecializacaoMsgTag
+	for _, v := range c.dados.Vinculos {
+		if v.IDEveMensagem == idEveMensagem {
+			vinculos = append(vinculos, v)
+		}
+	}
+
+	ordenarVinculos(vinculos)
+	return vinculos, nil
+}
+
+// ListarVinculos retorna todos os registros de spi_especializacao_msg_tag
+func (c *MemoryCatalog) ListarVinculos() ([]EspecializacaoMsgTag, error) {
+	vinculos := append([]EspecializacaoMsgTag(nil), c.dados.Vinculos...)
+	ordenarVinculos(vinculos)
+	return vinculos, nil
+}
+
+// ListarSitMsgEmiDes retorna todos os registros de spi_sit_msg_emi_des
+func (c *MemoryCatalog) ListarSitMsgEmiDes() ([]SitMsgEmiDes, error) {
+	registros := append([]SitMsgEmiDes(nil), c.dados.SitMsgEmiDes...)
+	sort.SliceStable(registros, func(i, j int) bool {
+		if registros[i].IDSitMsgEmiDes != registros[j].IDSitMsgEmiDes {
+			return registros[i].IDSitMsgEmiDes < registros[j].IDSitMsgEmiDes
+		}
+		if registros[i].IDTipEmiDes != registros[j].IDTipEmiDes {
+			return registros[i].IDTipEmiDes < registros[j].IDTipEmiDes
+		}
+		return registros[i].IDSitMsg < registros[j].IDSitMsg
+	})
+	return registros, nil
+}
+
+// VerificarSitMsgEmiDesExistente verifica se um registro existe pela chave composta
+func (c *MemoryCatalog) VerificarSitMsgEmiDesExistente(idSitMsgEmiDes string, idTipEmiDes int, idSitMsg int) (bool, error) {
+	for _, s := range c.dados.SitMsgEmiDes {
+		if s.IDSitMsgEmiDes == idSitMsgEmiDes && s.IDTipEmiDes == idTipEmiDes && s.IDSitMsg == idSitMsg {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ordenarVinculos ordena os vínculos como o ORDER BY das consultas do SQLServerCatalog
+func ordenarVinculos(vinculos []EspecializacaoMsgTag) {
+	sort.SliceStable(vinculos, func(i, j int) bool {
+		if vinculos[i].IDEveMensagem != vinculos[j].IDEveMensagem {
+			return vinculos[i].IDEveMensagem < vinculos[j].IDEveMensagem
+		}
+		if vinculos[i].NumSeqTag != vinculos[j].NumSeqTag {
+			return vinculos[i].NumSeqTag < vinculos[j].NumSeqTag
+		}
+		if vinculos[i].NumSeqMsgTag != vinculos[j].NumSeqMsgTag {
+			return vinculos[i].NumSeqMsgTag < vinculos[j].NumSeqMsgTag
+		}
+		return vinculos[i].IDEspecializacao < vinculos[j].IDEspecializacao
+	})
+}
@@ -0,0 +1,145 @@
+package esptag
+
+import (
+	"testing"
+)
+
+// tagsPacs002 retorna a estrutura de spi_mensagem_tag de um pacs.002.001.10 reduzido,
+// com num_seq_msg_tag = 100 + num_seq_tag
+func tagsPacs002() []MensagemTagInfo {
+	estrutura := [][2]string{
+		{"Document", ""},
+		{"FIToFIPmtStsRpt", "Document"},
+		{"GrpHdr", "FIToFIPmtStsRpt"},
+		{"MsgId", "GrpHdr"},
+		{"CreDtTm", "GrpHdr"},
+		{"OrgnlGrpInfAndSts", "FIToFIPmtStsRpt"},
+		{"OrgnlMsgId", "OrgnlGrpInfAndSts"},
+		{"OrgnlMsgNmId", "OrgnlGrpInfAndSts"},
+		{"TxInfAndSts", "FIToFIPmtStsRpt"},
+		{"OrgnlEndToEndId", "TxInfAndSts"},
+		{"TxSts", "TxInfAndSts"},
+		{"StsRsnInf", "TxInfAndSts"},
+		{"Rsn", "StsRsnInf"},
+		{"Cd", "Rsn"},
+		{"OrgnlTxRef", "TxInfAndSts"},
+		{"DbtrAcct", "OrgnlTxRef"},
+		{"Id", "DbtrAcct"},
+		{"Othr", "Id"},
+		{"Id", "Othr"},
+		{"CdtrAcct", "OrgnlTxRef"},
+		{"Id", "CdtrAcct"},
+		{"Othr", "Id"},
+		{"Id", "Othr"},
+	}
+
+	tags := make([]MensagemTagInfo, len(estrutura))
+	for i, e := range estrutura {
+		tags[i] = MensagemTagInfo{IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: e[0], IDTagPai: e[1], NumSeqTag: i + 1, NumSeqMsgTag: 101 + i}
+	}
+	return tags
+}
+
+func novoCatalogoTeste() *MemoryCatalog {
+	return NewMemoryCatalog(DadosCatalogo{
+		MensagemTags: append(tagsPacs002(),
+			MensagemTagInfo{IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxSts", IDTagPai: "TxInf", NumSeqTag: 4, NumSeqMsgTag: 204},
+		),
+		Especializacoes: []EspecializacaoTag{
+			{ID: 3, Descricao: "Situação da Transação"},
+			{ID: 7, Descricao: "Motivo da devolução"},
+			{ID: 5, Descricao: "situação do grupo"},
+		},
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxSts", NumSeqTag: 4, NumSeqMsgTag: 204},
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "TxSts", NumSeqTag: 11, NumSeqMsgTag: 111},
+		},
+		SitMsgEmiDes: []SitMsgEmiDes{
+			{IDSitMsgEmiDes: "RJCT", IDTipEmiDes: 1, IDSitMsg: 4, DscSitMsgEmiDes: "Rejeitada"},
+		},
+	})
+}
+
+func TestMemoryCatalogEspecializacoes(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	proximoID, _ := cat.ObterProximoID()
+	if proximoID != 8 {
+		t.Errorf("ObterProximoID() = %d, want 8", proximoID)
+	}
+
+	esp, _ := cat.ConsultaEspecializacaoPorID(4)
+	if esp != nil {
+		t.Errorf("ConsultaEspecializacaoPorID(4) = %v, want nil", esp)
+	}
+}
+
+func TestMemoryCatalogVinculosESituacoes(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	vinculos, err := cat.ListarVinculosEspecializacao(3)
+	if err != nil {
+		t.Fatalf("ListarVinculosEspecializacao() error = %v", err)
+	}
+	if len(vinculos) != 2 || vinculos[0].IDEveMensagem != "pacs.002.001.10" {
+		t.Errorf("ListarVinculosEspecializacao() = %v, want pacs.002.001.10 first", vinculos)
+	}
+
+	existe, _ := cat.VerificarSitMsgEmiDesExistente("RJCT", 1, 4)
+	if !existe {
+		t.Errorf("VerificarSitMsgEmiDesExistente(RJCT, 1, 4) = false, want true")
+	}
+	existe, _ = cat.VerificarSitMsgEmiDesExistente("RJCT", 2, 4)
+	if existe {
+		t.Errorf("VerificarSitMsgEmiDesExistente(RJCT, 2, 4) = true, want false")
+	}
+}
+
+func TestBuscarTagNaBase(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	resultados, err := BuscarTagNaBase(cat, []string{"FIToFIPmtStsRpt", "TxInfAndSts", "TxSts"}, "TxSts", "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("BuscarTagNaBase() error = %v", err)
+	}
+	if len(resultados) != 1 {
+		t.Fatalf("BuscarTagNaBase() returned %d results, want 1", len(resultados))
+	}
+	if resultados[0].NumSeqMsgTag != 111 || resultados[0].Score != 10 {
+		t.Errorf("BuscarTagNaBase() = %+v, want num_seq_msg_tag 111 with score 10", resultados[0])
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	original := novoCatalogoTeste()
+
+	snapshot, err := ExportarSnapshot(original, "teste")
+	if err != nil {
+		t.Fatalf("ExportarSnapshot() error = %v", err)
+	}
+
+	arquivo := t.TempDir() + "/snapshot.json"
+	if err := SalvarSnapshot(snapshot, arquivo); err != nil {
+		t.Fatalf("SalvarSnapshot() error = %v", err)
+	}
+
+	carregado, err := CarregarSnapshot(arquivo)
+	if err != nil {
+		t.Fatalf("CarregarSnapshot() error = %v", err)
+	}
+
+	restaurado := NewMemoryCatalog(carregado.DadosCatalogo)
+	for _, cat := range []Catalog{original, restaurado} {
+		vinculos, _ := cat.ListarVinculos()
+		tags, _ := cat.ListarMensagemTags()
+		if len(vinculos) != 2 || len(tags) != 24 {
+			t.Errorf("catálogo restaurado com %d vínculos e %d tags, want 2 e 24", len(vinculos), len(tags))
+		}
+	}
+
+	idOriginal, _ := original.ObterProximoID()
+	idRestaurado, _ := restaurado.ObterProximoID()
+	if GeraScriptNovaEspecializacao("Nova", idOriginal) != GeraScriptNovaEspecializacao("Nova", idRestaurado) {
+		t.Errorf("scripts diferentes entre catálogo original e snapshot")
+	}
+}
@@ -0,0 +1,298 @@
+package esptag
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// validadeCacheArvores é o intervalo após o qual a estrutura de uma mensagem é relida de spi_mensagem_tag
+const validadeCacheArvores = 5 * time.Minute
+
+// SQLServerCatalog implementa Catalog sobre a base SQL Server do PIX
+type SQLServerCatalog struct {
+	db      *sql.DB
+	arvores cacheArvores
+}
+
+// NewSQLServerCatalog cria um Catalog que consulta diretamente a base de dados
+func NewSQLServerCatalog(db *sql.DB) *SQLServerCatalog {
+	return &SQLServerCatalog{db: db, arvores: cacheArvores{validade: validadeCacheArvores}}
+}
+
+// ListarMensagemTags retorna todos os registros de spi_mensagem_tag
+func (c *SQLServerCatalog) ListarMensagemTags() ([]MensagemTagInfo, error) {
+	query := `
+		SELECT id_eve_msg, id_tip_msg, id_tag, ISNULL(id_tag_pai, ''), num_seq_tag, num_seq_msg_tag
+		FROM spi_mensagem_tag
+		ORDER BY id_eve_msg, num_seq_tag, num_seq_msg_tag
+	`
+	return c.consultarMensagemTags(query)
+}
+
+// consultarMensagemTags executa uma consulta sobre spi_mensagem_tag e lê as linhas retornadas
+func (c *SQLServerCatalog) consultarMensagemTags(query string, args ...interface{}) ([]MensagemTagInfo, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro na consulta à base de dados: %v", err)
+	}
+	defer rows.Close()
+
+	var resultados []MensagemTagInfo
+	for rows.Next() {
+		var info MensagemTagInfo
+		if err := rows.Scan(&info.IDEveMensagem, &info.IDTipMensagem, &info.IDTag,
+			&info.IDTagPai, &info.NumSeqTag, &info.NumSeqMsgTag); err != nil {
+			return nil, fmt.Errorf("erro ao ler resultado: %v", err)
+		}
+		resultados = append(resultados, info)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	}
+
+	return resultados, nil
+}
+
+// ListarMensagens retorna as mensagens de spi_mensagem_tag com a quantidade de tags de cada uma
+func (c *SQLServerCatalog) ListarMensagens() ([]MensagemResumo, error) {
+	query := `
+		SELECT id_eve_msg, id_tip_msg, COUNT(*)
+		FROM spi_mensagem_tag
+		GROUP BY id_eve_msg, id_tip_msg
+		ORDER BY id_eve_msg, id_tip_msg
+	`
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar mensagens: %v", err)
+	}
+	defer rows.Close()
+
+	var mensagens []MensagemResumo
+	for rows.Next() {
+		var m MensagemResumo
+		if err := rows.Scan(&m.IDEveMensagem, &m.IDTipMensagem, &m.QtdTags); err != nil {
+			return nil, fmt.Errorf("erro ao ler mensagem: %v", err)
+		}
+		mensagens = append(mensagens, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	}
+
+	return mensagens, nil
+}
+
+// ObterArvoreMensagem carrega todos os registros da mensagem em uma única consulta e guarda a árvore em cache
+// por validadeCacheArvores
+func (c *SQLServerCatalog) ObterArvoreMensagem(idEveMensagem string) (*ArvoreMensagem, error) {
+	return c.arvores.obter(idEveMensagem, func() ([]MensagemTagInfo, error) {
+		query := `
+			SELECT id_eve_msg, id_tip_msg, id_tag, ISNULL(id_tag_pai, ''), num_seq_tag, num_seq_msg_tag
+			FROM spi_mensagem_tag
+			WHERE id_eve_msg = ?
+			ORDER BY num_seq_tag, num_seq_msg_tag
+		`
+		return c.consultarMensagemTags(query, idEveMensagem)
+	})
+}
+
+// ObterProximoNumSeqMsgTag consulta o próximo num_seq_msg_tag disponível em spi_mensagem_tag
+func (c *SQLServerCatalog) ObterProximoNumSeqMsgTag() (int, error) {
+	query := `
+		SELECT ISNULL(MAX(num_seq_msg_tag), 0) + 1
+		FROM spi_mensagem_tag
+	`
+
+	var proximo int
+	err := c.db.QueryRow(query).Scan(&proximo)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter próximo num_seq_msg_tag: %v", err)
+	}
+
+	return proximo, nil
+}
+
+// ConsultaEspecializacaoPorID retorna uma especialização específica pelo seu ID
+func (c *SQLServerCatalog) ConsultaEspecializacaoPorID(id int) (*EspecializacaoTag, error) {
+	query := `
+		SELECT id_esp_tag, dsc_esp_tag 
+		FROM spi_especializacao_tag 
+		WHERE id_esp_tag = ?
+	`
+
+	var esp EspecializacaoTag
+	err := c.db.QueryRow(query, id).Scan(&esp.ID, &esp.Descricao)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Retorna nil se não encontrar
+		}
+		return nil, fmt.Errorf("erro ao consultar especialização por ID: %v", err)
+	}
+
+	return &esp, nil
+}
+
+// ListarEspecializacoes retorna todas as especializações cadastradas
+func (c *SQLServerCatalog) ListarEspecializacoes() ([]EspecializacaoTag, error) {
+	query := `
+		SELECT id_esp_tag, dsc_esp_tag
+		FROM spi_especializacao_tag
+		ORDER BY id_esp_tag
+	`
+	return c.consultarEspecializacoes(query)
+}
+
+// consultarEspecializacoes executa uma consulta sobre spi_especializacao_tag e lê as linhas retornadas
+func (c *SQLServerCatalog) consultarEspecializacoes(query string, args ...interface{}) ([]EspecializacaoTag, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar especializações: %v", err)
+	}
+	defer rows.Close()
+
+	var especializacoes []EspecializacaoTag
+
+	for rows.Next() {
+		var esp EspecializacaoTag
+		if err := rows.Scan(&esp.ID, &esp.Descricao); err != nil {
+			return nil, fmt.Errorf("erro ao ler linha de resultado: %v", err)
+		}
+		especializacoes = append(especializacoes, esp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	}
+
+	return especializacoes, nil
+}
+
+// ObterProximoID consulta o próximo ID disponível para especialização
+func (c *SQLServerCatalog) ObterProximoID() (int, error) {
+	query := `
+		SELECT ISNULL(MAX(id_esp_tag), 0) + 1 
+		FROM spi_especializacao_tag
+	`
+
+	var proximoID int
+	err := c.db.QueryRow(query).Scan(&proximoID)
+	if err != nil {
+		return 0, fmt.Errorf("erro ao obter próximo ID: %v", err)
+	}
+
+	return proximoID, nil
+}
+
+// ListarVinculosEspecializacao retorna os registros de spi_especializacao_msg_tag de uma especialização
+func (c *SQLServerCatalog) ListarVinculosEspecializacao(idEspTag int) ([]EspecializacaoMsgTag, error) {
+	query := `
+		SELECT id_esp_tag, id_eve_msg, id_tip_msg, id_tag, num_seq_tag, num_seq_msg_tag
+		FROM spi_especializacao_msg_tag
+		WHERE id_esp_tag = ?
+		ORDER BY id_eve_msg, num_seq_tag, num_seq_msg_tag
+	`
+
+	return c.consultarVinculos(query, idEspTag)
+}
+
+// ListarVinculosMensagem retorna os registros de spi_especializacao_msg_tag de uma mensagem
+func (c *SQLServerCatalog) ListarVinculosMensagem(idEveMensagem string) ([]EspecializacaoMsgTag, error) {
+	query := `
+		SELECT id_esp_tag, id_eve_msg, id_tip_msg, id_tag, num_seq_tag, num_seq_msg_tag
+		FROM spi_especializacao_msg_tag
+		WHERE id_eve_msg = ?
+		ORDER BY num_seq_tag, num_seq_msg_tag, id_esp_tag
+	`
+
+	return c.consultarVinculos(query, idEveMensagem)
+}
+
+// ListarVinculos retorna todos os registros de spi_especializacao_msg_tag
+func (c *SQLServerCatalog) ListarVinculos() ([]EspecializacaoMsgTag, error) {
+	query := `
+		SELECT id_esp_tag, id_eve_msg, id_tip_msg, id_tag, num_seq_tag, num_seq_msg_tag
+		FROM spi_especializacao_msg_tag
+		ORDER BY id_eve_msg, num_seq_tag, num_seq_msg_tag, id_esp_tag
+	`
+	return c.consultarVinculos(query)
+}
+
+// consultarVinculos executa uma consulta sobre spi_especializacao_msg_tag e lê as linhas retornadas
+func (c *SQLServerCatalog) consultarVinculos(query string, args ...interface{}) ([]EspecializacaoMsgTag, error) {
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar vínculos: %v", err)
+	}
+	defer rows.Close()
+
+	var vinculos []EspecializacaoMsgTag
+	for rows.Next() {
+		var v EspecializacaoMsgTag
+		if err := rows.Scan(&v.IDEspecializacao, &v.IDEveMensagem, &v.IDTipMensagem, &v.IDTag,
+			&v.NumSeqTag, &v.NumSeqMsgTag); err != nil {
+			return nil, fmt.Errorf("erro ao ler vínculo: %v", err)
+		}
+		vinculos = append(vinculos, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	}
+
+	return vinculos, nil
+}
+
+// ListarSitMsgEmiDes retorna todos os registros de spi_sit_msg_emi_des
+func (c *SQLServerCatalog) ListarSitMsgEmiDes() ([]SitMsgEmiDes, error) {
+	query := `
+		SELECT id_sit_msg_emi_des, id_tip_emi_des, id_sit_msg, dsc_sit_msg_emi_des, ISNULL(cod_usu_ult_mnt, 0)
+		FROM spi_sit_msg_emi_des
+		ORDER BY id_sit_msg_emi_des, id_tip_emi_des, id_sit_msg
+	`
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar spi_sit_msg_emi_des: %v", err)
+	}
+	defer rows.Close()
+
+	var registros []SitMsgEmiDes
+	for rows.Next() {
+		var r SitMsgEmiDes
+		if err := rows.Scan(&r.IDSitMsgEmiDes, &r.IDTipEmiDes, &r.IDSitMsg, &r.DscSitMsgEmiDes, &r.CodUsuUltMnt); err != nil {
+			return nil, fmt.Errorf("erro ao ler registro de spi_sit_msg_emi_des: %v", err)
+		}
+		registros = append(registros, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	}
+
+	return registros, nil
+}
+
+// VerificarSitMsgEmiDesExistente checks if a record exists in spi_sit_msg_emi_des based on the composite key
+func (c *SQLServerCatalog) VerificarSitMsgEmiDesExistente(idSitMsgEmiDes string, idTipEmiDes int, idSitMsg int) (bool, error) {
+	query := `
+		SELECT 1 
+		FROM spi_sit_msg_emi_des 
+		WHERE id_sit_msg_emi_des = ? 
+		  AND id_tip_emi_des = ? 
+		  AND id_sit_msg = ?
+	`
+	var existe int
+	err := c.db.QueryRow(query, idSitMsgEmiDes, idTipEmiDes, idSitMsg).Scan(&existe)
+
+	if err == sql.ErrNoRows {
+		return false, nil // Not found
+	}
+	if err != nil {
+		return false, fmt.Errorf("erro ao verificar existência de spi_sit_msg_emi_des: %v", err)
+	}
+	return true, nil // Found
+}
@@ -0,0 +1,390 @@
+package esptag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TipoItemChangeset identifica o tipo de registro acumulado em um changeset
+type TipoItemChangeset string
+
+const (
+	ItemEspecializacao TipoItemChangeset = "especializacao"
+	ItemSitMsgEmiDes   TipoItemChangeset = "sit_msg_emi_des"
+	ItemVinculacao     TipoItemChangeset = "vinculacao"
+)
+
+// ordemItemChangeset define a ordem de dependência no script exportado:
+// especializações são criadas antes das vinculações que as utilizam
+var ordemItemChangeset = map[TipoItemChangeset]int{
+	ItemEspecializacao: 0,
+	ItemSitMsgEmiDes:   1,
+	ItemVinculacao:     2,
+}
+
+// ItemChangeset representa um script gerado e acumulado no changeset
+type ItemChangeset struct {
+	ID             int                         `json:"id"`
+	Tipo           TipoItemChangeset           `json:"tipo"`
+	Especializacao *EspecializacaoTag          `json:"especializacao,omitempty"`
+	SitMsgEmiDes   *GeraScriptSitMsgEmiDesArgs `json:"sit_msg_emi_des,omitempty"`
+	Vinculacao     *GeraScriptVinculacaoArgs   `json:"vinculacao,omitempty"`
+}
+
+// Descricao resume o item em uma linha
+func (i ItemChangeset) Descricao() string {
+	switch i.Tipo {
+	case ItemEspecializacao:
+		return fmt.Sprintf("Nova especialização %d - %s", i.Especializacao.ID, i.Especializacao.Descricao)
+	case ItemSitMsgEmiDes:
+		return fmt.Sprintf("spi_sit_msg_emi_des '%s' (id_tip_emi_des %d, id_sit_msg %d) - %s",
+			i.SitMsgEmiDes.IDSitMsgEmiDes, i.SitMsgEmiDes.IDTipEmiDes, i.SitMsgEmiDes.IDSitMsg, i.SitMsgEmiDes.DscSitMsgEmiDes)
+	case ItemVinculacao:
+		return fmt.Sprintf("Vinculação da especialização %d a %s/%s (num_seq_tag %d, num_seq_msg_tag %d)",
+			i.Vinculacao.IDEspecializacao, i.Vinculacao.IDEveMensagem, i.Vinculacao.IDTag, i.Vinculacao.NumSeqTag, i.Vinculacao.NumSeqMsgTag)
+	}
+	return string(i.Tipo)
+}
+
+// Script gera o script SQL do item
+func (i ItemChangeset) Script() string {
+	switch i.Tipo {
+	case ItemEspecializacao:
+		return GeraScriptNovaEspecializacao(i.Especializacao.Descricao, i.Especializacao.ID)
+	case ItemSitMsgEmiDes:
+		return GeraScriptSitMsgEmiDes(*i.SitMsgEmiDes)
+	case ItemVinculacao:
+		return GeraScriptVinculacao(*i.Vinculacao)
+	}
+	return ""
+}
+
+// ScriptRollback gera o script SQL que desfaz o item
+func (i ItemChangeset) ScriptRollback() string {
+	switch i.Tipo {
+	case ItemEspecializacao:
+		return GeraScriptRollbackNovaEspecializacao(i.Especializacao.Descricao, i.Especializacao.ID)
+	case ItemSitMsgEmiDes:
+		return GeraScriptRollbackSitMsgEmiDes(*i.SitMsgEmiDes)
+	case ItemVinculacao:
+		return GeraScriptRollbackVinculacao(*i.Vinculacao)
+	}
+	return ""
+}
+
+// chave identifica o registro que o item insere, para detectar duplicidades
+func (i ItemChangeset) chave() string {
+	switch i.Tipo {
+	case ItemEspecializacao:
+		return fmt.Sprintf("esp:%d", i.Especializacao.ID)
+	case ItemSitMsgEmiDes:
+		return fmt.Sprintf("sit:%s:%d:%d", i.SitMsgEmiDes.IDSitMsgEmiDes, i.SitMsgEmiDes.IDTipEmiDes, i.SitMsgEmiDes.IDSitMsg)
+	case ItemVinculacao:
+		return fmt.Sprintf("vin:%d:%s:%s:%d:%d", i.Vinculacao.IDEspecializacao, i.Vinculacao.IDEveMensagem,
+			i.Vinculacao.IDTag, i.Vinculacao.NumSeqTag, i.Vinculacao.NumSeqMsgTag)
+	}
+	return ""
+}
+
+// Changeset acumula os scripts gerados no servidor para exportação em um único script de implantação
+type Changeset struct {
+	mu        sync.Mutex
+	proximoID int
+	itens     []ItemChangeset
+}
+
+// NovoChangeset cria um changeset vazio
+func NovoChangeset() *Changeset {
+	return &Changeset{proximoID: 1}
+}
+
+// Changesets mantém um changeset por sessão, para que os clientes de uma instância compartilhada
+// (transporte http) não vejam nem exportem os scripts acumulados uns pelos outros
+type Changesets struct {
+	mu         sync.Mutex
+	sessao     func(ctx context.Context) string
+	changesets map[string]*Changeset
+}
+
+// NovosChangesets cria o conjunto de changesets; sessao identifica o cliente a partir do contexto da
+// requisição. Sem função de sessão, ou quando ela retorna vazio, todas as requisições usam o mesmo changeset.
+func NovosChangesets(sessao func(ctx context.Context) string) *Changesets {
+	return &Changesets{sessao: sessao, changesets: make(map[string]*Changeset)}
+}
+
+// Remover descarta o changeset de uma sessão encerrada ou expirada
+func (c *Changesets) Remover(sessao string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.changesets, sessao)
+}
+
+// Obter retorna o changeset da sessão da requisição, criando-o no primeiro uso
+func (c *Changesets) Obter(ctx context.Context) *Changeset {
+	chave := ""
+	if c.sessao != nil {
+		chave = c.sessao(ctx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cs, ok := c.changesets[chave]
+	if !ok {
+		cs = NovoChangeset()
+		c.changesets[chave] = cs
+	}
+	return cs
+}
+
+// adicionar inclui o item, recusando registros idênticos já presentes no changeset
+func (c *Changeset) adicionar(item ItemChangeset) (ItemChangeset, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existente := range c.itens {
+		if existente.chave() == item.chave() && existente.Script() == item.Script() {
+			return existente, fmt.Errorf("o changeset já contém este registro (item %d)", existente.ID)
+		}
+	}
+
+	item.ID = c.proximoID
+	c.proximoID++
+	c.itens = append(c.itens, item)
+	return item, nil
+}
+
+// AdicionarEspecializacao inclui uma nova especialização no changeset
+func (c *Changeset) AdicionarEspecializacao(descricao string, id int) (ItemChangeset, error) {
+	return c.adicionar(ItemChangeset{Tipo: ItemEspecializacao, Especializacao: &EspecializacaoTag{ID: id, Descricao: descricao}})
+}
+
+// AdicionarSitMsgEmiDes inclui um registro de spi_sit_msg_emi_des no changeset
+func (c *Changeset) AdicionarSitMsgEmiDes(args GeraScriptSitMsgEmiDesArgs) (ItemChangeset, error) {
+	args.AdicionarChangeset = false
+	return c.adicionar(ItemChangeset{Tipo: ItemSitMsgEmiDes, SitMsgEmiDes: &args})
+}
+
+// AdicionarVinculacao inclui uma vinculação no changeset
+func (c *Changeset) AdicionarVinculacao(args GeraScriptVinculacaoArgs) (ItemChangeset, error) {
+	args.AdicionarChangeset = false
+	return c.adicionar(ItemChangeset{Tipo: ItemVinculacao, Vinculacao: &args})
+}
+
+// Itens retorna os itens na ordem em que foram adicionados
+func (c *Changeset) Itens() []ItemChangeset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ItemChangeset(nil), c.itens...)
+}
+
+// Remover exclui um item pelo ID, retornando false se ele não existir
+func (c *Changeset) Remover(id int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, item := range c.itens {
+		if item.ID == id {
+			c.itens = append(c.itens[:i], c.itens[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Limpar remove todos os itens e retorna quantos foram removidos
+func (c *Changeset) Limpar() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removidos := len(c.itens)
+	c.itens = nil
+	return removidos
+}
+
+// EspecializacaoPendente retorna a especialização com o ID informado, se estiver no changeset
+func (c *Changeset) EspecializacaoPendente(id int) *EspecializacaoTag {
+	for _, item := range c.Itens() {
+		if item.Tipo == ItemEspecializacao && item.Especializacao.ID == id {
+			esp := *item.Especializacao
+			return &esp
+		}
+	}
+	return nil
+}
+
+// EspecializacoesPendentes retorna as especializações a serem criadas pelo changeset
+func (c *Changeset) EspecializacoesPendentes() []EspecializacaoTag {
+	var esps []EspecializacaoTag
+	for _, item := range c.Itens() {
+		if item.Tipo == ItemEspecializacao {
+			esps = append(esps, *item.Especializacao)
+		}
+	}
+	return esps
+}
+
+// VinculacoesPendentes retorna as vinculações do changeset às tags da mensagem informada
+func (c *Changeset) VinculacoesPendentes(idEveMensagem string) []EspecializacaoMsgTag {
+	var vinculos []EspecializacaoMsgTag
+	for _, item := range c.Itens() {
+		if item.Tipo == ItemVinculacao && item.Vinculacao.IDEveMensagem == idEveMensagem {
+			vinculos = append(vinculos, EspecializacaoMsgTag{
+				IDEspecializacao: item.Vinculacao.IDEspecializacao,
+				IDEveMensagem:    item.Vinculacao.IDEveMensagem,
+				IDTag:            item.Vinculacao.IDTag,
+				NumSeqTag:        item.Vinculacao.NumSeqTag,
+				NumSeqMsgTag:     item.Vinculacao.NumSeqMsgTag,
+			})
+		}
+	}
+	return vinculos
+}
+
+// SitMsgEmiDesPendente verifica se o registro de spi_sit_msg_emi_des já está no changeset
+func (c *Changeset) SitMsgEmiDesPendente(idSitMsgEmiDes string, idTipEmiDes int, idSitMsg int) bool {
+	for _, item := range c.Itens() {
+		if item.Tipo == ItemSitMsgEmiDes && item.SitMsgEmiDes.IDSitMsgEmiDes == idSitMsgEmiDes &&
+			item.SitMsgEmiDes.IDTipEmiDes == idTipEmiDes && item.SitMsgEmiDes.IDSitMsg == idSitMsg {
+			return true
+		}
+	}
+	return false
+}
+
+// ProximoIDEspecializacao retorna o próximo ID livre considerando a base e as especializações pendentes
+func (c *Changeset) ProximoIDEspecializacao(proximoIDBase int) int {
+	proximoID := proximoIDBase
+	for _, item := range c.Itens() {
+		if item.Tipo == ItemEspecializacao && item.Especializacao.ID >= proximoID {
+			proximoID = item.Especializacao.ID + 1
+		}
+	}
+	return proximoID
+}
+
+// Validar verifica duplicidades e colisões de ID dentro do changeset e contra o catálogo.
+// Retorna os conflitos, que impedem a exportação, e os avisos, que apenas a acompanham.
+func (c *Changeset) Validar(cat Catalog) (conflitos []string, avisos []string, err error) {
+	itens := c.Itens()
+
+	porChave := make(map[string][]ItemChangeset)
+	descricoes := make(map[string][]ItemChangeset)
+	for _, item := range itens {
+		porChave[item.chave()] = append(porChave[item.chave()], item)
+		if item.Tipo == ItemEspecializacao {
+			dsc := strings.ToLower(strings.TrimSpace(item.Especializacao.Descricao))
+			descricoes[dsc] = append(descricoes[dsc], item)
+		}
+	}
+
+	for _, item := range itens {
+		repetidos := porChave[item.chave()]
+		if len(repetidos) > 1 && repetidos[0].ID == item.ID {
+			ids := make([]string, len(repetidos))
+			for i, r := range repetidos {
+				ids[i] = fmt.Sprintf("%d", r.ID)
+			}
+			if item.Tipo == ItemEspecializacao {
+				conflitos = append(conflitos, fmt.Sprintf("Colisão de ID: os itens %s criam a especialização %d", strings.Join(ids, ", "), item.Especializacao.ID))
+			} else {
+				conflitos = append(conflitos, fmt.Sprintf("Registro duplicado nos itens %s: %s", strings.Join(ids, ", "), item.Descricao()))
+			}
+		}
+
+		switch item.Tipo {
+		case ItemEspecializacao:
+			existente, err := cat.ConsultaEspecializacaoPorID(item.Especializacao.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if existente != nil {
+				conflitos = append(conflitos, fmt.Sprintf("Colisão de ID: item %d cria a especialização %d, que já existe na base como '%s'",
+					item.ID, item.Especializacao.ID, existente.Descricao))
+			}
+
+			dsc := strings.ToLower(strings.TrimSpace(item.Especializacao.Descricao))
+			if mesmos := descricoes[dsc]; len(mesmos) > 1 && mesmos[0].ID == item.ID {
+				avisos = append(avisos, fmt.Sprintf("Os itens de especialização com IDs %s têm a mesma descrição '%s'",
+					idsEspecializacoes(mesmos), item.Especializacao.Descricao))
+			}
+
+		case ItemVinculacao:
+			if c.EspecializacaoPendente(item.Vinculacao.IDEspecializacao) == nil {
+				existente, err := cat.ConsultaEspecializacaoPorID(item.Vinculacao.IDEspecializacao)
+				if err != nil {
+					return nil, nil, err
+				}
+				if existente == nil {
+					avisos = append(avisos, fmt.Sprintf("Item %d vincula a especialização %d, que não existe na base nem no changeset",
+						item.ID, item.Vinculacao.IDEspecializacao))
+				}
+			}
+		}
+	}
+
+	return conflitos, avisos, nil
+}
+
+// idsEspecializacoes lista os IDs de especialização dos itens
+func idsEspecializacoes(itens []ItemChangeset) string {
+	ids := make([]string, len(itens))
+	for i, item := range itens {
+		ids[i] = fmt.Sprintf("%d", item.Especializacao.ID)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// ItensOrdenados retorna os itens na ordem de dependência usada na exportação
+func (c *Changeset) ItensOrdenados() []ItemChangeset {
+	itens := c.Itens()
+	sort.SliceStable(itens, func(i, j int) bool {
+		return ordemItemChangeset[itens[i].Tipo] < ordemItemChangeset[itens[j].Tipo]
+	})
+	return itens
+}
+
+// GeraScriptChangeset gera um único script de implantação com todos os itens, dentro de uma transação
+func GeraScriptChangeset(itens []ItemChangeset) string {
+	script := strings.Builder{}
+
+	script.WriteString("-- Script de implantação gerado a partir do changeset\n")
+	script.WriteString(fmt.Sprintf("-- Itens: %d\n\n", len(itens)))
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	for i, item := range itens {
+		script.WriteString(fmt.Sprintf("-- [%d/%d] Item %d: %s\n", i+1, len(itens), item.ID, item.Descricao()))
+		script.WriteString(strings.TrimRight(item.Script(), "\n"))
+		script.WriteString("\n\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// GeraScriptRollbackChangeset gera o rollback de todos os itens, em ordem inversa à de implantação,
+// para que as vinculações sejam removidas antes das especializações que utilizam
+func GeraScriptRollbackChangeset(itens []ItemChangeset) string {
+	script := strings.Builder{}
+
+	script.WriteString("-- Script de rollback gerado a partir do changeset\n")
+	script.WriteString(fmt.Sprintf("-- Itens: %d\n\n", len(itens)))
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	for i := len(itens) - 1; i >= 0; i-- {
+		item := itens[i]
+		script.WriteString(fmt.Sprintf("-- [%d/%d] Item %d: %s\n", len(itens)-i, len(itens), item.ID, item.Descricao()))
+		script.WriteString(strings.TrimRight(item.ScriptRollback(), "\n"))
+		script.WriteString("\n\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
@@ -0,0 +1,100 @@
+package esptag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChangesetOrdemEConflitos(t *testing.T) {
+	cat := novoCatalogoTeste()
+	cs := NovoChangeset()
+
+	vinculacao := GeraScriptVinculacaoArgs{IDEspecializacao: 8, IDEveMensagem: "pacs.002.001.10", IDTag: "Cd", IDTagPai: "Rsn", NumSeqTag: 14, NumSeqMsgTag: 114}
+	if _, err := cs.AdicionarVinculacao(vinculacao); err != nil {
+		t.Fatalf("AdicionarVinculacao() error = %v", err)
+	}
+	if _, err := cs.AdicionarEspecializacao("Código do motivo", 8); err != nil {
+		t.Fatalf("AdicionarEspecializacao() error = %v", err)
+	}
+	if _, err := cs.AdicionarVinculacao(vinculacao); err == nil {
+		t.Errorf("AdicionarVinculacao() duplicada não retornou erro")
+	}
+
+	conflitos, avisos, err := cs.Validar(cat)
+	if err != nil || len(conflitos) != 0 || len(avisos) != 0 {
+		t.Fatalf("Validar() = %v, %v, %v, want no problems", conflitos, avisos, err)
+	}
+
+	script := GeraScriptChangeset(cs.ItensOrdenados())
+	if strings.Index(script, "INSERT INTO spi_especializacao_tag") > strings.Index(script, "INSERT INTO spi_especializacao_msg_tag") {
+		t.Errorf("GeraScriptChangeset() não cria a especialização antes da vinculação:\n%s", script)
+	}
+	if !strings.Contains(script, "BEGIN TRANSACTION") || !strings.HasSuffix(script, "COMMIT TRANSACTION\n") {
+		t.Errorf("GeraScriptChangeset() não está envolvido em transação:\n%s", script)
+	}
+
+	if proximo := cs.ProximoIDEspecializacao(8); proximo != 9 {
+		t.Errorf("ProximoIDEspecializacao(8) = %d, want 9", proximo)
+	}
+
+	// Mesmo ID com outra descrição e ID já existente na base são colisões
+	cs.AdicionarEspecializacao("Outra descrição", 8)
+	cs.AdicionarEspecializacao("Situação repetida", 3)
+	conflitos, _, _ = cs.Validar(cat)
+	if len(conflitos) != 2 {
+		t.Errorf("Validar() conflitos = %v, want 2", conflitos)
+	}
+
+	if !cs.Remover(1) || cs.Remover(1) {
+		t.Errorf("Remover(1) deveria remover o item apenas uma vez")
+	}
+	if removidos := cs.Limpar(); removidos != 3 {
+		t.Errorf("Limpar() = %d, want 3", removidos)
+	}
+}
+
+func TestGeraScriptRollbackChangeset(t *testing.T) {
+	cs := NovoChangeset()
+	cs.AdicionarEspecializacao("Código do motivo", 8)
+	cs.AdicionarVinculacao(GeraScriptVinculacaoArgs{IDEspecializacao: 8, IDEveMensagem: "pacs.002.001.10", IDTag: "Cd", IDTagPai: "Rsn", NumSeqTag: 14, NumSeqMsgTag: 114})
+
+	rollback := GeraScriptRollbackChangeset(cs.ItensOrdenados())
+	if strings.Index(rollback, "DELETE em") > strings.Index(rollback, "DELETE FROM spi_especializacao_tag") {
+		t.Errorf("GeraScriptRollbackChangeset() não remove a vinculação antes da especialização:\n%s", rollback)
+	}
+	if !strings.Contains(rollback, "WHERE id_esp_tag = 8\n    AND dsc_esp_tag = 'Código do motivo'") {
+		t.Errorf("GeraScriptRollbackChangeset() não restringe a remoção ao registro inserido:\n%s", rollback)
+	}
+}
+
+type chaveSessaoTeste struct{}
+
+func TestChangesetsPorSessao(t *testing.T) {
+	changesets := NovosChangesets(func(ctx context.Context) string {
+		sessao, _ := ctx.Value(chaveSessaoTeste{}).(string)
+		return sessao
+	})
+	ctxA := context.WithValue(context.Background(), chaveSessaoTeste{}, "a")
+	ctxB := context.WithValue(context.Background(), chaveSessaoTeste{}, "b")
+
+	changesets.Obter(ctxA).AdicionarEspecializacao("Código do motivo", 8)
+	if changesets.Obter(ctxA) != changesets.Obter(ctxA) || len(changesets.Obter(ctxA).Itens()) != 1 {
+		t.Errorf("Obter() deveria retornar o mesmo changeset para a sessão a")
+	}
+	if len(changesets.Obter(ctxB).Itens()) != 0 || len(changesets.Obter(context.Background()).Itens()) != 0 {
+		t.Errorf("Obter() compartilhou os itens da sessão a com outras sessões")
+	}
+
+	// Uma sessão encerrada recomeça com um changeset vazio
+	changesets.Remover("a")
+	if len(changesets.Obter(ctxA).Itens()) != 0 {
+		t.Errorf("Remover() deveria descartar o changeset da sessão a")
+	}
+
+	// Sem função de sessão, todas as requisições usam o mesmo changeset
+	unico := NovosChangesets(nil)
+	if unico.Obter(ctxA) != unico.Obter(ctxB) {
+		t.Errorf("NovosChangesets(nil).Obter() deveria retornar um único changeset")
+	}
+}
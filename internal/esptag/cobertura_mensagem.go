@@ -0,0 +1,102 @@
+package esptag
+
+import (
+	"strings"
+)
+
+// CoberturaMensagemArgs define os argumentos de entrada para o MCP
+type CoberturaMensagemArgs struct {
+	IDEveMensagem string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pacs.008.001.10)"`
+}
+
+// CoberturaTag representa uma tag da mensagem com as especializações vinculadas a ela
+type CoberturaTag struct {
+	Tag             MensagemTagInfo
+	Profundidade    int
+	Especializacoes []EspecializacaoTag
+}
+
+// CoberturaMensagem reúne a árvore de uma mensagem com as especializações vinculadas a cada tag
+type CoberturaMensagem struct {
+	IDEveMensagem  string
+	IDTipMensagem  string
+	Tags           []CoberturaTag         // Em pré-ordem, para exibição indentada
+	VinculosSemTag []EspecializacaoMsgTag // Vínculos sem registro correspondente em spi_mensagem_tag
+	TotalVinculos  int
+}
+
+// TagsSemEspecializacao retorna as tags da mensagem sem nenhuma especialização vinculada
+func (c *CoberturaMensagem) TagsSemEspecializacao() []MensagemTagInfo {
+	var tags []MensagemTagInfo
+	for _, t := range c.Tags {
+		if len(t.Especializacoes) == 0 {
+			tags = append(tags, t.Tag)
+		}
+	}
+	return tags
+}
+
+// EspecializacoesDistintas retorna a quantidade de especializações diferentes vinculadas à mensagem
+func (c *CoberturaMensagem) EspecializacoesDistintas() int {
+	ids := make(map[int]bool)
+	for _, t := range c.Tags {
+		for _, esp := range t.Especializacoes {
+			ids[esp.ID] = true
+		}
+	}
+	return len(ids)
+}
+
+// GerarCoberturaMensagem percorre a árvore de spi_mensagem_tag de uma mensagem associando a cada tag
+// as especializações de spi_especializacao_msg_tag. Retorna nil se a mensagem não possuir tags.
+func GerarCoberturaMensagem(cat Catalog, idEveMensagem string) (*CoberturaMensagem, error) {
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	if len(arvore.Tags()) == 0 {
+		return nil, nil
+	}
+
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	especializacoes, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+	descricoes := make(map[int]string, len(especializacoes))
+	for _, esp := range especializacoes {
+		descricoes[esp.ID] = esp.Descricao
+	}
+
+	cobertura := &CoberturaMensagem{
+		IDEveMensagem: idEveMensagem,
+		IDTipMensagem: arvore.Tags()[0].IDTipMensagem,
+		TotalVinculos: len(vinculos),
+	}
+
+	porTag := make(map[int][]EspecializacaoTag)
+	for _, v := range vinculos {
+		tag, ok := arvore.Tag(v.NumSeqMsgTag)
+		if !ok || tag.IDTag != v.IDTag {
+			cobertura.VinculosSemTag = append(cobertura.VinculosSemTag, v)
+			continue
+		}
+		porTag[v.NumSeqMsgTag] = append(porTag[v.NumSeqMsgTag], EspecializacaoTag{ID: v.IDEspecializacao, Descricao: descricoes[v.IDEspecializacao]})
+	}
+
+	var percorrer func(tags []MensagemTagInfo, profundidade int)
+	percorrer = func(tags []MensagemTagInfo, profundidade int) {
+		for _, t := range tags {
+			t.Caminho = strings.Join(arvore.Caminho(t.NumSeqMsgTag), " > ")
+			cobertura.Tags = append(cobertura.Tags, CoberturaTag{Tag: t, Profundidade: profundidade, Especializacoes: porTag[t.NumSeqMsgTag]})
+			percorrer(arvore.Filhos(t.NumSeqMsgTag), profundidade+1)
+		}
+	}
+	percorrer(arvore.Raizes(), 0)
+
+	return cobertura, nil
+}
@@ -0,0 +1,40 @@
+package esptag
+
+import "testing"
+
+func TestGerarCoberturaMensagem(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	cobertura, err := GerarCoberturaMensagem(cat, "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if cobertura == nil || len(cobertura.Tags) != 23 {
+		t.Fatalf("cobertura inesperada: %+v", cobertura)
+	}
+
+	// Pré-ordem: TxSts (111) vem logo após OrgnlEndToEndId (110), em profundidade 3
+	var txSts CoberturaTag
+	for i, c := range cobertura.Tags {
+		if c.Tag.NumSeqMsgTag == 111 {
+			txSts = c
+			if cobertura.Tags[i-1].Tag.NumSeqMsgTag != 110 {
+				t.Errorf("ordem inesperada antes de TxSts: %+v", cobertura.Tags[i-1].Tag)
+			}
+		}
+	}
+	if txSts.Profundidade != 3 || len(txSts.Especializacoes) != 1 || txSts.Especializacoes[0].Descricao != "Situação da Transação" {
+		t.Errorf("cobertura de TxSts inesperada: %+v", txSts)
+	}
+
+	if n := len(cobertura.TagsSemEspecializacao()); n != 22 {
+		t.Errorf("tags sem especialização = %d, esperado 22", n)
+	}
+	if cobertura.TotalVinculos != 1 || cobertura.EspecializacoesDistintas() != 1 {
+		t.Errorf("resumo inesperado: vínculos %d, especializações %d", cobertura.TotalVinculos, cobertura.EspecializacoesDistintas())
+	}
+
+	if cobertura, _ := GerarCoberturaMensagem(cat, "pacs.999.001.01"); cobertura != nil {
+		t.Errorf("mensagem inexistente deveria retornar nil")
+	}
+}
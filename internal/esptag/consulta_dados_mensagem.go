@@ -1,119 +1,127 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
 )
 
-// BuscarTagNaBase busca informações completas sobre uma tag na base de dados
-func BuscarTagNaBase(db *sql.DB, caminhoPlano []string, tagAlvo string, idEveMensagem string) ([]MensagemTagInfo, error) {
-	var tagPaiPlano string
-	tagAlvoIdxPlano := -1
-	for i, tag := range caminhoPlano {
-		if tag == tagAlvo {
-			tagAlvoIdxPlano = i
-			break
-		}
+// IdentificarMensagem determina o id_eve_msg da consulta. Sem valor informado, usa o identificado no XML
+// (namespace do Document ou MsgDefIdr do AppHdr), retornando a origem; havendo valor informado, ele prevalece
+// e é gerado um aviso se contradizer o XML.
+func IdentificarMensagem(caminhoXML string, idInformado string) (idEveMensagem string, origem string, avisos []string) {
+	if !util.IsXMLInput(caminhoXML) {
+		return idInformado, "", nil
 	}
-	if tagAlvoIdxPlano > 0 {
-		tagPaiPlano = caminhoPlano[tagAlvoIdxPlano-1]
+
+	hints, err := util.DetectMessageID(caminhoXML)
+	if err != nil {
+		// Erros de análise do XML são reportados na localização da tag
+		return idInformado, "", nil
 	}
-	log.Printf("DEBUG: Flat Path Analysis - Derived Parent (for logging only): '%s'", tagPaiPlano)
 
-	query := `
-		SELECT mt.id_eve_msg, mt.id_tip_msg, mt.id_tag, ISNULL(mt.id_tag_pai, '') as id_tag_pai,
-		       mt.num_seq_tag, mt.num_seq_msg_tag
-		FROM spi_mensagem_tag mt
-		WHERE mt.id_tag = ? AND mt.id_eve_msg = ?
-	`
-	args := []interface{}{tagAlvo, idEveMensagem}
+	origemDetectada := "MsgDefIdr do AppHdr"
+	if hints.Namespace != "" {
+		origemDetectada = "namespace do Document"
+	}
+	if hints.Conflicting() {
+		avisos = append(avisos, fmt.Sprintf("O namespace do Document (%s) e o MsgDefIdr do AppHdr (%s) divergem; foi considerado o namespace.", hints.Namespace, hints.MsgDefIdr))
+	}
 
-	log.Printf("DEBUG: Executing SQL query: %s", query)
-	log.Printf("DEBUG: With arguments: %v", args)
+	detectado := hints.ID()
+	if idInformado == "" {
+		if detectado == "" {
+			return "", "", avisos
+		}
+		return detectado, origemDetectada, avisos
+	}
 
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		log.Printf("DEBUG: SQL query error: %v", err)
-		return nil, fmt.Errorf("erro na consulta à base de dados: %v", err)
+	if detectado != "" && !strings.EqualFold(detectado, idInformado) {
+		avisos = append(avisos, fmt.Sprintf("O id_eve_msg informado (%s) difere do identificado pelo %s (%s). A busca utiliza o valor informado.", idInformado, origemDetectada, detectado))
 	}
-	defer rows.Close()
-
-	var resultados []MensagemTagInfo
-	for rows.Next() {
-		var info MensagemTagInfo
-		if err := rows.Scan(&info.IDEveMensagem, &info.IDTipMensagem, &info.IDTag,
-			&info.IDTagPai, &info.NumSeqTag, &info.NumSeqMsgTag); err != nil {
-			return nil, fmt.Errorf("erro ao ler resultado: %v", err)
-		}
-		info.Score = 10
-		resultados = append(resultados, info)
+	return idInformado, "", avisos
+}
+
+// BuscarTagNaBase busca informações completas sobre uma tag na base de dados
+func BuscarTagNaBase(cat Catalog, caminhoPlano []string, tagAlvo string, idEveMensagem string) ([]MensagemTagInfo, error) {
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("erro durante iteração dos resultados: %v", err)
+	resultados := arvore.BuscarTag(tagAlvo)
+	for i := range resultados {
+		resultados[i].Score = 10
 	}
 
 	return resultados, nil
 }
 
-// ReconstruirCaminho tenta reconstruir o caminho completo de uma tag na hierarquia
-func ReconstruirCaminho(db *sql.DB, info MensagemTagInfo) ([]string, error) {
-	caminho := []string{info.IDTag}
-	if info.IDTagPai == "" {
-		return caminho, nil
+// RankearTagsNaBase busca os registros da tag alvo na mensagem e os ordena pela pontuação do Scorer
+// em relação à ocorrência da tag no XML (maior pontuação primeiro). Empates são ordenados por num_seq_tag.
+func RankearTagsNaBase(cat Catalog, scorer Scorer, ocorrencia util.TagOccurrence, nomeTag string, idEveMensagem string) ([]MensagemTagInfo, error) {
+	// Query Database (without parent filter initially)
+	resultados, err := BuscarTagNaBase(cat, ocorrencia.Path, nomeTag, idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
 	}
-	caminho = append([]string{info.IDTagPai}, caminho...)
-	tagAtual := info.IDTagPai
-	numSeqTagAtual := info.NumSeqTag
-	idEveMsgAtual := info.IDEveMensagem
-
-	for i := 0; i < 5; i++ {
-		var tagPai string
-		var nextNumSeqTag int
-
-		query := `
-            SELECT ISNULL(id_tag_pai, ''), num_seq_tag
-            FROM spi_mensagem_tag
-            WHERE id_tag = ?
-              AND id_eve_msg = ?
-              AND num_seq_tag = ?
-        `
-		err := db.QueryRow(query, tagAtual, idEveMsgAtual, numSeqTagAtual).Scan(&tagPai, &nextNumSeqTag)
-
-		if err == sql.ErrNoRows || tagPai == "" {
-			break
+
+	// Score results using the rules of the scorer
+	for i := range resultados {
+		caminhoDB, reconErr := ReconstruirCaminho(cat, resultados[i])
+		if reconErr != nil {
+			resultados[i].Caminho = fmt.Sprintf("[%s] (Erro ao reconstruir caminho: %v)", resultados[i].IDTag, reconErr)
+		} else {
+			resultados[i].Caminho = strings.Join(caminhoDB, " > ")
 		}
-		if err != nil {
-			log.Printf("WARN: Error reconstructing path for tag %s: %v", tagAtual, err)
-			return caminho, err
+
+		resultados[i].Regras = scorer.Pontuar(arvore, resultados[i], ocorrencia)
+		resultados[i].Score = SomarPontos(resultados[i].Regras)
+	}
+
+	// Sort results by final score, breaking ties by num_seq_tag
+	sort.SliceStable(resultados, func(i, j int) bool {
+		if resultados[i].Score != resultados[j].Score {
+			return resultados[i].Score > resultados[j].Score
 		}
-		caminho = append([]string{tagPai}, caminho...)
-		tagAtual = tagPai
-		numSeqTagAtual = nextNumSeqTag
+		return resultados[i].NumSeqTag < resultados[j].NumSeqTag
+	})
+
+	return resultados, nil
+}
+
+// CorrespondenciaClara indica se o primeiro registro do ranking se destaca dos demais com folga suficiente
+// para ser apresentado como a melhor correspondência, sem verificação manual
+func CorrespondenciaClara(resultados []MensagemTagInfo) bool {
+	return len(resultados) == 1 || (len(resultados) > 1 && resultados[0].Score > resultados[1].Score+10) // Increased threshold for "exact"
+}
+
+// ReconstruirCaminho reconstrói o caminho completo de uma tag na hierarquia da mensagem,
+// usando a árvore da mensagem mantida em cache pelo Catalog
+func ReconstruirCaminho(cat Catalog, info MensagemTagInfo) ([]string, error) {
+	arvore, err := cat.ObterArvoreMensagem(info.IDEveMensagem)
+	if err != nil {
+		log.Printf("WARN: Error reconstructing path for tag %s: %v", info.IDTag, err)
+		return nil, err
+	}
+
+	caminho := arvore.Caminho(info.NumSeqMsgTag)
+	if caminho == nil {
+		return nil, fmt.Errorf("tag %s (num_seq_msg_tag %d) não encontrada na mensagem %s", info.IDTag, info.NumSeqMsgTag, info.IDEveMensagem)
 	}
 	return caminho, nil
 }
 
-// CalcularPontuacaoCorrespondencia calcula um valor de correspondência entre dois caminhos
+// CalcularPontuacaoCorrespondencia calcula um valor de correspondência entre dois caminhos, com o peso padrão por nível
 func CalcularPontuacaoCorrespondencia(caminhoXML []string, caminhoDB []string) int {
-	pontuacao := 0
-	if caminhoXML == nil || caminhoDB == nil {
-		return 0
-	}
-	maxLen := len(caminhoXML)
-	if len(caminhoDB) < maxLen {
-		maxLen = len(caminhoDB)
-	}
-	for i := 1; i <= maxLen; i++ {
-		idxXML := len(caminhoXML) - i
-		idxDB := len(caminhoDB) - i
-		if idxXML < 0 || idxDB < 0 {
-			break
-		}
-		if caminhoXML[idxXML] == caminhoDB[idxDB] {
-			pontuacao += (maxLen - i + 1) * 2
-		}
-	}
+	pontuacao, _ := pontuacaoCaminho(caminhoXML, caminhoDB, PesosPadrao().CaminhoPorNivel)
 	return pontuacao
 }
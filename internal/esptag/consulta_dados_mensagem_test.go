@@ -0,0 +1,69 @@
+package esptag
+
+import (
+	"testing"
+
+	"sq_pix/internal/esptag/util"
+)
+
+func TestIdentificarMensagem(t *testing.T) {
+	xml := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10"><FIToFIPmtStsRpt/></Document>`
+
+	id, origem, avisos := IdentificarMensagem(xml, "")
+	if id != "pacs.002.001.10" || origem != "namespace do Document" || len(avisos) != 0 {
+		t.Errorf("identificação inesperada: %q, %q, %v", id, origem, avisos)
+	}
+
+	id, origem, avisos = IdentificarMensagem(xml, "pacs.008.001.08")
+	if id != "pacs.008.001.08" || origem != "" || len(avisos) != 1 {
+		t.Errorf("valor informado deveria prevalecer com aviso: %q, %q, %v", id, origem, avisos)
+	}
+
+	if id, _, avisos := IdentificarMensagem("FIToFIPmtStsRpt/TxInfAndSts/TxSts", ""); id != "" || len(avisos) != 0 {
+		t.Errorf("caminho simples não deveria identificar a mensagem: %q, %v", id, avisos)
+	}
+}
+
+func TestRankearTagsNaBaseDesempateEstrutural(t *testing.T) {
+	estrutura := [][2]string{
+		{"Doc", ""},
+		{"A", "Doc"},
+		{"X", "A"},
+		{"Rsn", "A"},
+		{"Cd", "Rsn"},
+		{"Prtry", "Rsn"},
+		{"B", "Doc"},
+		{"Rsn", "B"},
+		{"Cd", "Rsn"},
+		{"Y", "B"},
+	}
+	tags := make([]MensagemTagInfo, len(estrutura))
+	for i, e := range estrutura {
+		tags[i] = MensagemTagInfo{IDEveMensagem: "teste.001.001.01", IDTipMensagem: "teste.001", IDTag: e[0], IDTagPai: e[1], NumSeqTag: i + 1, NumSeqMsgTag: 301 + i}
+	}
+	cat := NewMemoryCatalog(DadosCatalogo{MensagemTags: tags})
+
+	casos := []struct {
+		xml      string
+		tag      string
+		esperado int
+	}{
+		{`<Rsn><Cd>X</Cd><Prtry>Y</Prtry></Rsn>`, "Cd", 305}, // Irmão Prtry existe apenas no ramo A
+		{`<Rsn><Cd>X</Cd></Rsn><Y/>`, "Rsn", 308},            // Irmão Y, após Rsn, existe apenas no ramo B
+		{`<X/><Rsn><Cd>X</Cd><Prtry>Y</Prtry></Rsn>`, "Rsn", 304},
+	}
+
+	for _, c := range casos {
+		ocorrencias, err := util.FindTagOccurrences(c.xml, c.tag)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		resultados, err := RankearTagsNaBase(cat, NovoScorer(PesosPadrao()), ocorrencias[0], c.tag, "teste.001.001.01")
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if len(resultados) != 2 || resultados[0].NumSeqMsgTag != c.esperado || resultados[0].Score <= resultados[1].Score {
+			t.Errorf("%s: ranking inesperado %+v, esperado num_seq_msg_tag %d em primeiro", c.xml, resultados, c.esperado)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package esptag
+
+import (
+	"strings"
+)
+
+// ConsultaVinculosEspecializacaoArgs define os argumentos de entrada para o MCP
+type ConsultaVinculosEspecializacaoArgs struct {
+	IDEspecializacao int `json:"id_esp_tag" jsonschema:"required,description=ID da especialização cujos vínculos serão listados"`
+}
+
+// VinculosMensagem agrupa as tags de uma mensagem vinculadas a uma especialização
+type VinculosMensagem struct {
+	IDEveMensagem string
+	IDTipMensagem string
+	Tags          []MensagemTagInfo // Caminho vazio indica vínculo sem registro correspondente em spi_mensagem_tag
+}
+
+// AgruparVinculosPorMensagem associa cada vínculo à sua tag em spi_mensagem_tag, com o caminho completo,
+// agrupando o resultado por mensagem na ordem em que os vínculos foram recebidos
+func AgruparVinculosPorMensagem(cat Catalog, vinculos []EspecializacaoMsgTag) ([]VinculosMensagem, error) {
+	var grupos []VinculosMensagem
+	indice := make(map[string]int)
+
+	for _, v := range vinculos {
+		arvore, err := cat.ObterArvoreMensagem(v.IDEveMensagem)
+		if err != nil {
+			return nil, err
+		}
+
+		tag, ok := arvore.Tag(v.NumSeqMsgTag)
+		if ok && tag.IDTag == v.IDTag {
+			tag.Caminho = strings.Join(arvore.Caminho(v.NumSeqMsgTag), " > ")
+		} else {
+			tag = MensagemTagInfo{
+				IDEveMensagem: v.IDEveMensagem,
+				IDTipMensagem: v.IDTipMensagem,
+				IDTag:         v.IDTag,
+				NumSeqTag:     v.NumSeqTag,
+				NumSeqMsgTag:  v.NumSeqMsgTag,
+			}
+		}
+
+		i, existe := indice[v.IDEveMensagem]
+		if !existe {
+			i = len(grupos)
+			indice[v.IDEveMensagem] = i
+			grupos = append(grupos, VinculosMensagem{IDEveMensagem: v.IDEveMensagem, IDTipMensagem: v.IDTipMensagem})
+		}
+		grupos[i].Tags = append(grupos[i].Tags, tag)
+	}
+
+	return grupos, nil
+}
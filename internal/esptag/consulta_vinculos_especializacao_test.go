@@ -0,0 +1,30 @@
+package esptag
+
+import "testing"
+
+func TestAgruparVinculosPorMensagem(t *testing.T) {
+	cat := novoCatalogoTeste()
+	vinculos, err := cat.ListarVinculosEspecializacao(3)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	vinculos = append(vinculos, EspecializacaoMsgTag{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "Inexistente", NumSeqTag: 99, NumSeqMsgTag: 999})
+
+	grupos, err := AgruparVinculosPorMensagem(cat, vinculos)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if len(grupos) != 2 || grupos[0].IDEveMensagem != "pacs.002.001.10" || len(grupos[0].Tags) != 2 {
+		t.Fatalf("agrupamento inesperado: %+v", grupos)
+	}
+
+	if esperado := "Document > FIToFIPmtStsRpt > TxInfAndSts > TxSts"; grupos[0].Tags[0].Caminho != esperado {
+		t.Errorf("caminho = %q, esperado %q", grupos[0].Tags[0].Caminho, esperado)
+	}
+	if grupos[0].Tags[0].IDTagPai != "TxInfAndSts" {
+		t.Errorf("id_tag_pai = %q, esperado TxInfAndSts", grupos[0].Tags[0].IDTagPai)
+	}
+	if grupos[0].Tags[1].Caminho != "" {
+		t.Errorf("vínculo sem tag em spi_mensagem_tag não deveria ter caminho: %q", grupos[0].Tags[1].Caminho)
+	}
+}
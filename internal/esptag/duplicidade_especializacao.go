@@ -0,0 +1,143 @@
+package esptag
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RelevanciaDuplicataForte é a similaridade mútua (0 a 100) a partir da qual duas descrições
+// são tratadas como a mesma especialização
+const RelevanciaDuplicataForte = 90
+
+// DuplicataEspecializacao é uma especialização, existente ou pendente no changeset, que pode
+// duplicar a especialização que se pretende criar
+type DuplicataEspecializacao struct {
+	EspecializacaoRelevante
+	Forte             bool     // Duplicidade forte: bloqueia a geração do script sem forcar
+	Pendente          bool     // Especialização ainda não aplicada, presente apenas no changeset
+	Motivos           []string // Motivos pelos quais a especialização foi apontada
+	MensagensMesmaTag []string // Mensagens em que a especialização já está vinculada à mesma tag
+}
+
+// DescricaoNormalizada reduz a descrição às palavras relevantes, sem acentos, em minúsculas,
+// com sinônimos substituídos pela forma canônica e em ordem alfabética.
+// Descrições com a mesma forma normalizada são equivalentes.
+func DescricaoNormalizada(descricao string) string {
+	palavras := palavrasRelevantes(descricao)
+	canonicas := make([]string, len(palavras))
+	for i, palavra := range palavras {
+		canonicas[i] = palavraCanonica(palavra)
+	}
+	sort.Strings(canonicas)
+	return strings.Join(canonicas, " ")
+}
+
+// DetectarDuplicatas compara a descrição da nova especialização com as especializações cadastradas
+// e as pendentes no changeset. Se idTag for informado, também considera as especializações já
+// vinculadas a essa tag em outras mensagens. Retorna as duplicatas fortes primeiro e, em seguida,
+// as possíveis, da mais relevante para a menos relevante.
+func DetectarDuplicatas(cat Catalog, descricao, idTag string, pendentes []EspecializacaoTag) ([]DuplicataEspecializacao, error) {
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+
+	mesmaTag := make(map[int][]string)
+	if idTag != "" {
+		vinculos, err := cat.ListarVinculos()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vinculos {
+			if strings.EqualFold(v.IDTag, idTag) && !slices.Contains(mesmaTag[v.IDEspecializacao], v.IDEveMensagem) {
+				mesmaTag[v.IDEspecializacao] = append(mesmaTag[v.IDEspecializacao], v.IDEveMensagem)
+			}
+		}
+	}
+
+	normalizada := DescricaoNormalizada(descricao)
+	var duplicatas []DuplicataEspecializacao
+	avaliar := func(esp EspecializacaoTag, pendente bool) {
+		relevancia := RelevanciaDescricao(descricao, esp.Descricao)
+		mutua := min(relevancia, RelevanciaDescricao(esp.Descricao, descricao))
+		d := DuplicataEspecializacao{
+			EspecializacaoRelevante: EspecializacaoRelevante{EspecializacaoTag: esp, Relevancia: relevancia},
+			Pendente:                pendente,
+			MensagensMesmaTag:       mesmaTag[esp.ID],
+		}
+
+		switch {
+		case normalizada != "" && normalizada == DescricaoNormalizada(esp.Descricao):
+			d.Forte = true
+			d.Motivos = append(d.Motivos, "descrição equivalente, desconsiderando acentos, maiúsculas, sinônimos e a ordem das palavras")
+		case mutua >= RelevanciaDuplicataForte:
+			d.Forte = true
+			d.Motivos = append(d.Motivos, fmt.Sprintf("descrição quase idêntica (similaridade %d%%)", mutua))
+		case relevancia >= RelevanciaMinimaSimilar:
+			d.Motivos = append(d.Motivos, fmt.Sprintf("descrição similar (relevância %d%%)", relevancia))
+		}
+
+		if len(d.MensagensMesmaTag) > 0 {
+			mensagens := strings.Join(d.MensagensMesmaTag, ", ")
+			if relevancia >= RelevanciaMinimaBusca {
+				// Descrição parecida e já usada na mesma tag: é quase certamente a mesma especialização
+				d.Forte = true
+				d.Motivos = append(d.Motivos, fmt.Sprintf("já vinculada à tag %s em %s", idTag, mensagens))
+			} else {
+				d.Motivos = append(d.Motivos, fmt.Sprintf("já vinculada à tag %s em %s, com outra descrição", idTag, mensagens))
+			}
+		}
+
+		if len(d.Motivos) > 0 {
+			duplicatas = append(duplicatas, d)
+		}
+	}
+
+	for _, esp := range esps {
+		avaliar(esp, false)
+	}
+	for _, esp := range pendentes {
+		avaliar(esp, true)
+	}
+
+	sort.SliceStable(duplicatas, func(i, j int) bool {
+		if duplicatas[i].Forte != duplicatas[j].Forte {
+			return duplicatas[i].Forte
+		}
+		if duplicatas[i].Relevancia != duplicatas[j].Relevancia {
+			return duplicatas[i].Relevancia > duplicatas[j].Relevancia
+		}
+		return duplicatas[i].ID < duplicatas[j].ID
+	})
+
+	return duplicatas, nil
+}
+
+// PossuiDuplicataForte indica se alguma das duplicatas é forte
+func PossuiDuplicataForte(duplicatas []DuplicataEspecializacao) bool {
+	for _, d := range duplicatas {
+		if d.Forte {
+			return true
+		}
+	}
+	return false
+}
+
+// escreverDuplicatas lista as duplicatas com os motivos pelos quais foram apontadas
+func escreverDuplicatas(resultado *strings.Builder, duplicatas []DuplicataEspecializacao) {
+	for i, d := range duplicatas {
+		resultado.WriteString(fmt.Sprintf("%d. ID: %d - Descrição: %s (relevância %d%%)", i+1, d.ID, d.Descricao, d.Relevancia))
+		if d.Forte {
+			resultado.WriteString(" [DUPLICIDADE FORTE]")
+		}
+		if d.Pendente {
+			resultado.WriteString(" [pendente no changeset]")
+		}
+		resultado.WriteString("\n")
+		for _, motivo := range d.Motivos {
+			resultado.WriteString(fmt.Sprintf("   - %s\n", motivo))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package esptag
+
+import (
+	"testing"
+)
+
+func TestDetectarDuplicatas(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	tests := []struct {
+		name      string
+		descricao string
+		idTag     string
+		pendentes []EspecializacaoTag
+		wantIDs   []int
+		wantForte bool
+	}{
+		{"Sinônimo e acentos", "Status da transacao", "", nil, []int{3}, true},
+		{"Ordem das palavras", "Transação: situação", "", nil, []int{3}, true},
+		{"Descrição mais específica", "Situação da transação original", "", nil, []int{3}, false},
+		{"Mesma tag em outras mensagens", "Situação final", "TxSts", nil, []int{3}, true},
+		{"Mesma tag com outra descrição", "Código de erro", "TxSts", nil, []int{3}, false},
+		{"Pendente no changeset", "Motivo da rejeição", "", []EspecializacaoTag{{ID: 8, Descricao: "Razão da rejeição"}}, []int{8}, true},
+		{"Sem duplicatas", "Valor da tarifa", "", nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			duplicatas, err := DetectarDuplicatas(cat, tt.descricao, tt.idTag, tt.pendentes)
+			if err != nil {
+				t.Fatalf("DetectarDuplicatas() error = %v", err)
+			}
+			var ids []int
+			for _, d := range duplicatas {
+				ids = append(ids, d.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("DetectarDuplicatas(%q) = %+v, want IDs %v", tt.descricao, duplicatas, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("DetectarDuplicatas(%q) = %+v, want IDs %v", tt.descricao, duplicatas, tt.wantIDs)
+				}
+			}
+			if got := PossuiDuplicataForte(duplicatas); got != tt.wantForte {
+				t.Errorf("PossuiDuplicataForte(%q) = %v, want %v (%+v)", tt.descricao, got, tt.wantForte, duplicatas)
+			}
+		})
+	}
+}
+
+func TestDescricaoNormalizada(t *testing.T) {
+	if a, b := DescricaoNormalizada("Situação da Transação"), DescricaoNormalizada("status da OPERACAO"); a != b {
+		t.Errorf("DescricaoNormalizada() = %q e %q, want iguais", a, b)
+	}
+}
@@ -0,0 +1,145 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// ValorExemplo é o conteúdo das tags folha no XML de exemplo
+const ValorExemplo = "?"
+
+// GeraExemploMensagemArgs define os argumentos de entrada para o MCP
+type GeraExemploMensagemArgs struct {
+	IDEveMensagem        string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pacs.002.001.10)"`
+	ApenasEspecializadas bool   `json:"apenas_especializadas" jsonschema:"description=Se verdadeiro, inclui apenas as tags com especializações e os seus ancestrais"`
+}
+
+// ExemploMensagem é o XML de exemplo de uma mensagem, montado a partir de spi_mensagem_tag
+type ExemploMensagem struct {
+	IDEveMensagem     string
+	XML               string
+	QtdTags           int // Tags incluídas no XML
+	QtdEspecializadas int // Tags incluídas que possuem especializações
+	QtdRaizes         int // Tags sem pai cadastrado; mais de uma gera um XML com várias raízes
+}
+
+// GerarExemploMensagem monta um XML de exemplo com as tags da mensagem em ordem de num_seq_tag, o namespace
+// ISO 20022 da mensagem nas raízes e ValorExemplo nas tags folha. Cada tag vinculada a especializações em
+// spi_especializacao_msg_tag é precedida de um comentário com o ID e a descrição da especialização.
+// Todas as tags cadastradas são incluídas, inclusive as alternativas de um mesmo xs:choice.
+func GerarExemploMensagem(cat Catalog, idEveMensagem string, apenasEspecializadas bool) (*ExemploMensagem, error) {
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+	descricoes := make(map[int]string)
+	for _, esp := range esps {
+		descricoes[esp.ID] = esp.Descricao
+	}
+	especializacoes := make(map[int][]int)
+	for _, v := range vinculos {
+		especializacoes[v.NumSeqMsgTag] = append(especializacoes[v.NumSeqMsgTag], v.IDEspecializacao)
+	}
+
+	// Com apenas_especializadas, marca as tags especializadas e todos os seus ancestrais
+	incluidas := make(map[int]bool)
+	for _, tag := range arvore.Tags() {
+		if !apenasEspecializadas {
+			incluidas[tag.NumSeqMsgTag] = true
+			continue
+		}
+		if len(especializacoes[tag.NumSeqMsgTag]) == 0 {
+			continue
+		}
+		for atual, ok := tag, true; ok && !incluidas[atual.NumSeqMsgTag]; atual, ok = arvore.Pai(atual.NumSeqMsgTag) {
+			incluidas[atual.NumSeqMsgTag] = true
+		}
+	}
+
+	exemplo := &ExemploMensagem{IDEveMensagem: idEveMensagem}
+	var xml strings.Builder
+	visitadas := make(map[int]bool)
+
+	var escrever func(tag MensagemTagInfo, nivel int, namespace string)
+	escrever = func(tag MensagemTagInfo, nivel int, namespace string) {
+		if visitadas[tag.NumSeqMsgTag] {
+			return
+		}
+		visitadas[tag.NumSeqMsgTag] = true
+		exemplo.QtdTags++
+
+		recuo := strings.Repeat("  ", nivel)
+		if ids := especializacoes[tag.NumSeqMsgTag]; len(ids) > 0 {
+			exemplo.QtdEspecializadas++
+			for _, id := range ids {
+				descricao, ok := descricoes[id]
+				if !ok {
+					descricao = "sem registro em spi_especializacao_tag"
+				}
+				xml.WriteString(fmt.Sprintf("%s<!-- Especialização %d: %s (num_seq_msg_tag %d) -->\n",
+					recuo, id, comentarioXML(descricao), tag.NumSeqMsgTag))
+			}
+		}
+
+		var filhos []MensagemTagInfo
+		for _, filho := range arvore.Filhos(tag.NumSeqMsgTag) {
+			if incluidas[filho.NumSeqMsgTag] {
+				filhos = append(filhos, filho)
+			}
+		}
+
+		if len(filhos) == 0 {
+			xml.WriteString(fmt.Sprintf("%s<%s%s>%s</%s>\n", recuo, tag.IDTag, namespace, ValorExemplo, tag.IDTag))
+			return
+		}
+		xml.WriteString(fmt.Sprintf("%s<%s%s>\n", recuo, tag.IDTag, namespace))
+		for _, filho := range filhos {
+			escrever(filho, nivel+1, "")
+		}
+		xml.WriteString(fmt.Sprintf("%s</%s>\n", recuo, tag.IDTag))
+	}
+
+	namespace := fmt.Sprintf(" xmlns=\"%s%s\"", util.ISO20022NamespacePrefix, idEveMensagem)
+	for _, raiz := range arvore.Raizes() {
+		if !incluidas[raiz.NumSeqMsgTag] {
+			continue
+		}
+		exemplo.QtdRaizes++
+		if raiz.IDTagPai == "" {
+			escrever(raiz, 0, namespace)
+			continue
+		}
+		// Pai não cadastrado: a tag é envolvida pelo pai informado, como no caminho reconstruído
+		xml.WriteString(fmt.Sprintf("<%s%s>\n", raiz.IDTagPai, namespace))
+		escrever(raiz, 1, "")
+		xml.WriteString(fmt.Sprintf("</%s>\n", raiz.IDTagPai))
+	}
+
+	cabecalho := fmt.Sprintf("<!-- Exemplo de %s gerado a partir de spi_mensagem_tag: %d tags, %d com especializações -->\n",
+		idEveMensagem, exemplo.QtdTags, exemplo.QtdEspecializadas)
+	if exemplo.QtdRaizes > 1 {
+		cabecalho += fmt.Sprintf("<!-- Aviso: %d tags sem pai cadastrado na mensagem aparecem como raízes do XML -->\n", exemplo.QtdRaizes)
+	}
+	exemplo.XML = cabecalho + xml.String()
+
+	return exemplo, nil
+}
+
+// comentarioXML evita a sequência "--", que não é permitida dentro de um comentário XML
+func comentarioXML(texto string) string {
+	for strings.Contains(texto, "--") {
+		texto = strings.ReplaceAll(texto, "--", "-")
+	}
+	return texto
+}
@@ -0,0 +1,71 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+
+	"sq_pix/internal/esptag/util"
+)
+
+func TestGerarExemploMensagem(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	exemplo, err := GerarExemploMensagem(cat, "pacs.002.001.10", false)
+	if err != nil {
+		t.Fatalf("GerarExemploMensagem() error = %v", err)
+	}
+	if exemplo.QtdTags != len(tagsPacs002()) || exemplo.QtdEspecializadas != 1 || exemplo.QtdRaizes != 1 {
+		t.Errorf("exemplo = %d tags, %d especializadas, %d raízes, want %d, 1, 1",
+			exemplo.QtdTags, exemplo.QtdEspecializadas, exemplo.QtdRaizes, len(tagsPacs002()))
+	}
+
+	for _, trecho := range []string{
+		"<Document xmlns=\"urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10\">\n  <FIToFIPmtStsRpt>\n    <GrpHdr>\n      <MsgId>?</MsgId>\n",
+		"      <!-- Especialização 3: Situação da Transação (num_seq_msg_tag 111) -->\n      <TxSts>?</TxSts>\n",
+		"            <Othr>\n              <Id>?</Id>\n            </Othr>\n",
+	} {
+		if !strings.Contains(exemplo.XML, trecho) {
+			t.Errorf("XML não contém %q:\n%s", trecho, exemplo.XML)
+		}
+	}
+
+	// O XML gerado é aceito como caminho_xml: identifica a mensagem e localiza a tag especializada
+	id, _, _ := IdentificarMensagem(exemplo.XML, "")
+	if id != "pacs.002.001.10" {
+		t.Errorf("IdentificarMensagem() = %q, want pacs.002.001.10", id)
+	}
+	ocorrencias, err := util.FindTagOccurrences(exemplo.XML, "TxSts")
+	if err != nil || len(ocorrencias) != 1 || strings.Join(ocorrencias[0].Path, "/") != "Document/FIToFIPmtStsRpt/TxInfAndSts/TxSts" {
+		t.Errorf("FindTagOccurrences() = %+v, %v, want uma ocorrência de TxSts", ocorrencias, err)
+	}
+}
+
+func TestGerarExemploMensagemApenasEspecializadas(t *testing.T) {
+	exemplo, err := GerarExemploMensagem(novoCatalogoTeste(), "pacs.002.001.10", true)
+	if err != nil {
+		t.Fatalf("GerarExemploMensagem() error = %v", err)
+	}
+
+	// Apenas TxSts e os seus ancestrais
+	if exemplo.QtdTags != 4 || strings.Contains(exemplo.XML, "GrpHdr") {
+		t.Errorf("XML com apenas especializadas inesperado (%d tags):\n%s", exemplo.QtdTags, exemplo.XML)
+	}
+
+	// TxSts da pacs.008.001.08 não tem o pai TxInf cadastrado e é envolvida por ele
+	exemplo, err = GerarExemploMensagem(novoCatalogoTeste(), "pacs.008.001.08", false)
+	if err != nil || !strings.Contains(exemplo.XML, "<TxInf xmlns=\"urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08\">\n") ||
+		!strings.Contains(exemplo.XML, "  <TxSts>?</TxSts>\n</TxInf>\n") {
+		t.Errorf("tag sem pai cadastrado deveria ser envolvida pelo pai informado: %v\n%s", err, exemplo.XML)
+	}
+
+	exemplo, err = GerarExemploMensagem(novoCatalogoTeste(), "pacs.008.001.09", true)
+	if err != nil || exemplo.QtdTags != 0 {
+		t.Errorf("mensagem sem tags deveria gerar exemplo vazio: %+v, %v", exemplo, err)
+	}
+}
+
+func TestComentarioXML(t *testing.T) {
+	if got := comentarioXML("Código -- legado --- antigo"); got != "Código - legado - antigo" {
+		t.Errorf("comentarioXML() = %q", got)
+	}
+}
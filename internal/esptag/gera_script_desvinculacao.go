@@ -0,0 +1,59 @@
+package esptag
+
+// GeraScriptDesvinculacaoArgs define os argumentos de entrada para o MCP
+type GeraScriptDesvinculacaoArgs struct {
+	IDEspecializacao int    `json:"id_esp_tag" jsonschema:"required,description=ID da especialização que será desvinculada"`
+	IDEveMensagem    string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pain.012)"`
+	IDTag            string `json:"id_tag" jsonschema:"required,description=ID da tag (ex: MndtId)"`
+	IDTagPai         string `json:"id_tag_pai" jsonschema:"description=ID da tag pai (ex: OrgnlMndt)"`
+	NumSeqTag        int    `json:"num_seq_tag" jsonschema:"required,description=Número sequencial da tag"`
+	NumSeqMsgTag     int    `json:"num_seq_msg_tag" jsonschema:"required,description=Número sequencial da mensagem tag"`
+}
+
+// vinculacao retorna as mesmas chaves no formato usado pelos scripts de vinculação
+func (args GeraScriptDesvinculacaoArgs) vinculacao() GeraScriptVinculacaoArgs {
+	return GeraScriptVinculacaoArgs{
+		IDEspecializacao: args.IDEspecializacao,
+		IDEveMensagem:    args.IDEveMensagem,
+		IDTag:            args.IDTag,
+		IDTagPai:         args.IDTagPai,
+		NumSeqTag:        args.NumSeqTag,
+		NumSeqMsgTag:     args.NumSeqMsgTag,
+	}
+}
+
+// BuscarVinculosAfetados retorna os registros de spi_especializacao_msg_tag que a desvinculação removeria
+func BuscarVinculosAfetados(cat Catalog, args GeraScriptDesvinculacaoArgs) ([]EspecializacaoMsgTag, error) {
+	vinculos, err := cat.ListarVinculosEspecializacao(args.IDEspecializacao)
+	if err != nil {
+		return nil, err
+	}
+
+	arvore, err := cat.ObterArvoreMensagem(args.IDEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	var afetados []EspecializacaoMsgTag
+	for _, v := range vinculos {
+		if v.IDEveMensagem != args.IDEveMensagem || v.IDTag != args.IDTag ||
+			v.NumSeqTag != args.NumSeqTag || v.NumSeqMsgTag != args.NumSeqMsgTag {
+			continue
+		}
+		if args.IDTagPai != "" {
+			tag, ok := arvore.Tag(v.NumSeqMsgTag)
+			if !ok || tag.IDTagPai != args.IDTagPai {
+				continue
+			}
+		}
+		afetados = append(afetados, v)
+	}
+
+	return afetados, nil
+}
+
+// GeraScriptDesvinculacao gera o script SQL para remover o vínculo de uma especialização com uma tag,
+// usando as mesmas chaves de GeraScriptVinculacao
+func GeraScriptDesvinculacao(args GeraScriptDesvinculacaoArgs) string {
+	return geraScriptRemocaoVinculacao(args.vinculacao(), "-- Script para desvincular especialização de uma mensagem\n")
+}
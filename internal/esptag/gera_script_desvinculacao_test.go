@@ -0,0 +1,40 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuscarVinculosAfetados(t *testing.T) {
+	cat := novoCatalogoTeste()
+	args := GeraScriptDesvinculacaoArgs{
+		IDEspecializacao: 3,
+		IDEveMensagem:    "pacs.002.001.10",
+		IDTag:            "TxSts",
+		IDTagPai:         "TxInfAndSts",
+		NumSeqTag:        11,
+		NumSeqMsgTag:     111,
+	}
+
+	afetados, err := BuscarVinculosAfetados(cat, args)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if len(afetados) != 1 || afetados[0].NumSeqMsgTag != 111 {
+		t.Fatalf("vínculos afetados inesperados: %+v", afetados)
+	}
+
+	args.IDTagPai = "GrpHdr"
+	afetados, err = BuscarVinculosAfetados(cat, args)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if len(afetados) != 0 {
+		t.Fatalf("tag pai divergente não deveria afetar vínculos: %+v", afetados)
+	}
+
+	script := GeraScriptDesvinculacao(args)
+	if !strings.Contains(script, "DELETE em") || !strings.Contains(script, "AND em.id_esp_tag = 3") {
+		t.Errorf("script de desvinculação inesperado:\n%s", script)
+	}
+}
@@ -0,0 +1,92 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GeraScriptExclusaoEspecializacaoArgs define os argumentos de entrada para o MCP
+type GeraScriptExclusaoEspecializacaoArgs struct {
+	IDEspecializacao int  `json:"id_esp_tag" jsonschema:"required,description=ID da especialização a ser excluída"`
+	Cascata          bool `json:"cascata" jsonschema:"description=Se verdadeiro, remove também os vínculos em spi_especializacao_msg_tag. Sem ele, a exclusão é recusada quando houver vínculos"`
+}
+
+// GeraScriptExclusaoEspecializacao gera o script SQL para excluir uma especialização.
+// Com vínculos informados, o script remove apenas esses vínculos antes da especialização, dentro de uma transação.
+// As duas remoções são protegidas pelo ID e pela descrição, e a especialização só é removida se nenhum
+// outro vínculo tiver sido criado depois da consulta.
+func GeraScriptExclusaoEspecializacao(esp EspecializacaoTag, vinculos []EspecializacaoMsgTag) string {
+	script := strings.Builder{}
+	escapedDescricao := strings.Replace(esp.Descricao, "'", "''", -1)
+
+	script.WriteString("-- Script para excluir especialização de tag\n")
+	script.WriteString(fmt.Sprintf("-- Descrição: %s\n", esp.Descricao))
+	script.WriteString(fmt.Sprintf("-- ID: %d\n", esp.ID))
+	script.WriteString(fmt.Sprintf("-- Vínculos removidos em cascata: %d\n\n", len(vinculos)))
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	script.WriteString(fmt.Sprintf("IF EXISTS (SELECT 1 FROM spi_especializacao_tag WHERE id_esp_tag = %d AND dsc_esp_tag = '%s')\nBEGIN\n", esp.ID, escapedDescricao))
+
+	if len(vinculos) > 0 {
+		script.WriteString("  DELETE FROM spi_especializacao_msg_tag\n")
+		script.WriteString(fmt.Sprintf("  WHERE id_esp_tag = %d\n", esp.ID))
+		for i, v := range vinculos {
+			prefixo := "    AND (   "
+			if i > 0 {
+				prefixo = "         OR "
+			}
+			script.WriteString(fmt.Sprintf("%s(id_eve_msg = '%s' AND num_seq_msg_tag = %d)", prefixo,
+				strings.Replace(v.IDEveMensagem, "'", "''", -1), v.NumSeqMsgTag))
+			if i == len(vinculos)-1 {
+				script.WriteString(")")
+			}
+			script.WriteString("\n")
+		}
+		script.WriteString("\n")
+	}
+
+	script.WriteString(fmt.Sprintf("  IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_esp_tag = %d)\n  BEGIN\n", esp.ID))
+	script.WriteString("    DELETE FROM spi_especializacao_tag\n")
+	script.WriteString(fmt.Sprintf("    WHERE id_esp_tag = %d\n", esp.ID))
+	script.WriteString(fmt.Sprintf("      AND dsc_esp_tag = '%s'\n", escapedDescricao))
+	script.WriteString("  END\n")
+	script.WriteString("END\n\n")
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// GeraScriptRollbackExclusaoEspecializacao gera o script SQL que recria a especialização e os vínculos removidos
+func GeraScriptRollbackExclusaoEspecializacao(cat Catalog, esp EspecializacaoTag, vinculos []EspecializacaoMsgTag) (string, error) {
+	script := strings.Builder{}
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+	script.WriteString(GeraScriptNovaEspecializacao(esp.Descricao, esp.ID))
+
+	for _, v := range vinculos {
+		arvore, err := cat.ObterArvoreMensagem(v.IDEveMensagem)
+		if err != nil {
+			return "", err
+		}
+		tag, _ := arvore.Tag(v.NumSeqMsgTag)
+
+		script.WriteString("\n")
+		script.WriteString(GeraScriptVinculacao(GeraScriptVinculacaoArgs{
+			IDEspecializacao: v.IDEspecializacao,
+			IDEveMensagem:    v.IDEveMensagem,
+			IDTag:            v.IDTag,
+			IDTagPai:         tag.IDTagPai,
+			NumSeqTag:        v.NumSeqTag,
+			NumSeqMsgTag:     v.NumSeqMsgTag,
+		}))
+		script.WriteString("\n")
+	}
+
+	script.WriteString("\nCOMMIT TRANSACTION\n")
+
+	return script.String(), nil
+}
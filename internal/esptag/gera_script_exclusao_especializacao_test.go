@@ -0,0 +1,45 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeraScriptExclusaoEspecializacao(t *testing.T) {
+	esp := EspecializacaoTag{ID: 5, Descricao: "situação do grupo"}
+
+	// Sem cascata: nenhum vínculo é removido, apenas a especialização sem vínculos
+	script := GeraScriptExclusaoEspecializacao(esp, nil)
+	if strings.Contains(script, "DELETE FROM spi_especializacao_msg_tag") {
+		t.Errorf("GeraScriptExclusaoEspecializacao() sem vínculos não deveria remover vínculos:\n%s", script)
+	}
+	for _, trecho := range []string{
+		"IF EXISTS (SELECT 1 FROM spi_especializacao_tag WHERE id_esp_tag = 5 AND dsc_esp_tag = 'situação do grupo')",
+		"  IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_esp_tag = 5)\n  BEGIN\n    DELETE FROM spi_especializacao_tag\n    WHERE id_esp_tag = 5\n      AND dsc_esp_tag = 'situação do grupo'\n",
+	} {
+		if !strings.Contains(script, trecho) {
+			t.Errorf("GeraScriptExclusaoEspecializacao() não contém %q:\n%s", trecho, script)
+		}
+	}
+
+	// Com cascata: apenas os vínculos listados, dentro da mesma proteção pela descrição
+	cat := novoCatalogoTeste()
+	esp = EspecializacaoTag{ID: 3, Descricao: "Situação da Transação"}
+	vinculos, _ := cat.ListarVinculosEspecializacao(3)
+	script = GeraScriptExclusaoEspecializacao(esp, vinculos)
+	trecho := "IF EXISTS (SELECT 1 FROM spi_especializacao_tag WHERE id_esp_tag = 3 AND dsc_esp_tag = 'Situação da Transação')\nBEGIN\n" +
+		"  DELETE FROM spi_especializacao_msg_tag\n  WHERE id_esp_tag = 3\n" +
+		"    AND (   (id_eve_msg = 'pacs.002.001.10' AND num_seq_msg_tag = 111)\n" +
+		"         OR (id_eve_msg = 'pacs.008.001.08' AND num_seq_msg_tag = 204))\n"
+	if !strings.Contains(script, trecho) {
+		t.Errorf("GeraScriptExclusaoEspecializacao() com cascata não contém %q:\n%s", trecho, script)
+	}
+
+	rollback, err := GeraScriptRollbackExclusaoEspecializacao(cat, esp, vinculos)
+	if err != nil {
+		t.Fatalf("GeraScriptRollbackExclusaoEspecializacao() error = %v", err)
+	}
+	if strings.Count(rollback, "INSERT INTO spi_especializacao_msg_tag") != 2 || !strings.Contains(rollback, "INSERT INTO spi_especializacao_tag") {
+		t.Errorf("GeraScriptRollbackExclusaoEspecializacao() deveria recriar a especialização e os 2 vínculos:\n%s", rollback)
+	}
+}
@@ -1,7 +1,6 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 )
@@ -10,46 +9,10 @@ import (
 type GeraScriptNovaEspecializacaoArgs struct {
 	Descricao string `json:"descricao" jsonschema:"required,description=Descrição da nova especialização a ser criada"`
 	ID        *int   `json:"id" jsonschema:"description=ID opcional para a especialização. Se não fornecido, será calculado automaticamente"`
-}
-
-// ObterProximoID consulta o próximo ID disponível para especialização
-func ObterProximoID(db *sql.DB) (int, error) {
-	query := `
-		SELECT ISNULL(MAX(id_esp_tag), 0) + 1 
-		FROM spi_especializacao_tag
-	`
-
-	var proximoID int
-	err := db.QueryRow(query).Scan(&proximoID)
-	if err != nil {
-		return 0, fmt.Errorf("erro ao obter próximo ID: %v", err)
-	}
+	IDTag     string `json:"id_tag" jsonschema:"description=Nome da tag à qual a especialização será vinculada (ex: TxSts). Se informado, as especializações já vinculadas a essa tag em outras mensagens são verificadas como possíveis duplicatas"`
+	Forcar    bool   `json:"forcar" jsonschema:"description=Gera o script mesmo quando a descrição duplica uma especialização existente"`
 
-	return proximoID, nil
-}
-
-// VerificarIDExistente verifica se um ID já está em uso
-func VerificarIDExistente(db *sql.DB, id int) (bool, error) {
-	// Use placeholder posicional (?) para compatibilidade
-	query := `
-		SELECT 1 
-		FROM spi_especializacao_tag 
-		WHERE id_esp_tag = ? 
-	`
-
-	var existe int
-	// Passa o argumento diretamente para o placeholder posicional
-	err := db.QueryRow(query, id).Scan(&existe)
-
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-
-	if err != nil {
-		return false, fmt.Errorf("erro ao verificar ID existente: %v", err)
-	}
-
-	return true, nil
+	AdicionarChangeset bool `json:"adicionar_changeset,omitempty" jsonschema:"description=Se verdadeiro, adiciona o script gerado ao changeset do servidor"`
 }
 
 // GeraScriptNovaEspecializacao gera o script SQL para inserir uma nova especialização
@@ -70,3 +33,25 @@ func GeraScriptNovaEspecializacao(descricao string, id int) string {
 
 	return script.String()
 }
+
+// GeraScriptRollbackNovaEspecializacao gera o script SQL que desfaz GeraScriptNovaEspecializacao.
+// Remove apenas o registro com o mesmo ID e a mesma descrição inseridos, e somente se não houver vínculos.
+func GeraScriptRollbackNovaEspecializacao(descricao string, id int) string {
+	script := strings.Builder{}
+	escapedDescricao := strings.Replace(descricao, "'", "''", -1)
+
+	script.WriteString("-- Rollback: remove a especialização de tag criada\n")
+	script.WriteString(fmt.Sprintf("-- Descrição: %s\n", descricao))
+	script.WriteString(fmt.Sprintf("-- ID: %d\n\n", id))
+
+	script.WriteString(fmt.Sprintf("IF EXISTS (SELECT 1 FROM spi_especializacao_tag WHERE id_esp_tag = %d AND dsc_esp_tag = '%s')\n", id, escapedDescricao))
+	script.WriteString(fmt.Sprintf("   AND NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_esp_tag = %d)\nBEGIN\n", id))
+
+	script.WriteString("  DELETE FROM spi_especializacao_tag\n")
+	script.WriteString(fmt.Sprintf("  WHERE id_esp_tag = %d\n", id))
+	script.WriteString(fmt.Sprintf("    AND dsc_esp_tag = '%s'\n", escapedDescricao))
+
+	script.WriteString("END\n")
+
+	return script.String()
+}
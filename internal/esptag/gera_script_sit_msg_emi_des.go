@@ -1,7 +1,6 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 )
@@ -14,27 +13,8 @@ type GeraScriptSitMsgEmiDesArgs struct {
 	DscSitMsgEmiDes string `json:"dsc_sit_msg_emi_des" jsonschema:"required,description=Descrição da situação"`
 	CodUsuUltMnt    *int   `json:"cod_usu_ult_mnt" jsonschema:"description=Código do usuário da última manutenção (opcional, default 0)"`
 	// dat_ult_mnt will be handled by GETDATE() in the script
-}
 
-// VerificarSitMsgEmiDesExistente checks if a record exists in spi_sit_msg_emi_des based on the composite key
-func VerificarSitMsgEmiDesExistente(db *sql.DB, idSitMsgEmiDes string, idTipEmiDes int, idSitMsg int) (bool, error) {
-	query := `
-		SELECT 1 
-		FROM spi_sit_msg_emi_des 
-		WHERE id_sit_msg_emi_des = ? 
-		  AND id_tip_emi_des = ? 
-		  AND id_sit_msg = ?
-	`
-	var existe int
-	err := db.QueryRow(query, idSitMsgEmiDes, idTipEmiDes, idSitMsg).Scan(&existe)
-
-	if err == sql.ErrNoRows {
-		return false, nil // Not found
-	}
-	if err != nil {
-		return false, fmt.Errorf("erro ao verificar existência de spi_sit_msg_emi_des: %v", err)
-	}
-	return true, nil // Found
+	AdicionarChangeset bool `json:"adicionar_changeset,omitempty" jsonschema:"description=Se verdadeiro, adiciona o script gerado ao changeset do servidor"`
 }
 
 // GeraScriptSitMsgEmiDes generates the SQL script to insert into spi_sit_msg_emi_des if not exists
@@ -72,3 +52,26 @@ func GeraScriptSitMsgEmiDes(args GeraScriptSitMsgEmiDesArgs) string {
 
 	return script.String()
 }
+
+// GeraScriptRollbackSitMsgEmiDes gera o script SQL que desfaz GeraScriptSitMsgEmiDes,
+// removendo o registro pela mesma chave composta e descrição usadas na inserção
+func GeraScriptRollbackSitMsgEmiDes(args GeraScriptSitMsgEmiDesArgs) string {
+	script := strings.Builder{}
+
+	escapedIDSitMsgEmiDes := strings.Replace(args.IDSitMsgEmiDes, "'", "''", -1)
+	escapedDscSitMsgEmiDes := strings.Replace(args.DscSitMsgEmiDes, "'", "''", -1)
+
+	script.WriteString("-- Rollback: remove o registro inserido em spi_sit_msg_emi_des\n")
+	script.WriteString(fmt.Sprintf("-- ID Situação Mensagem Emissor Destinatário: %s\n", args.IDSitMsgEmiDes))
+	script.WriteString(fmt.Sprintf("-- ID Tipo Emissor Destinatário: %d\n", args.IDTipEmiDes))
+	script.WriteString(fmt.Sprintf("-- ID Situação Mensagem: %d\n\n", args.IDSitMsg))
+
+	where := fmt.Sprintf("id_sit_msg_emi_des = '%s' AND id_tip_emi_des = %d AND id_sit_msg = %d AND dsc_sit_msg_emi_des = '%s'",
+		escapedIDSitMsgEmiDes, args.IDTipEmiDes, args.IDSitMsg, escapedDscSitMsgEmiDes)
+
+	script.WriteString(fmt.Sprintf("IF EXISTS (SELECT 1 FROM spi_sit_msg_emi_des WHERE %s)\nBEGIN\n", where))
+	script.WriteString(fmt.Sprintf("  DELETE FROM spi_sit_msg_emi_des\n  WHERE %s\n", where))
+	script.WriteString("END\n")
+
+	return script.String()
+}
@@ -1,7 +1,6 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 )
@@ -14,28 +13,24 @@ type GeraScriptVinculacaoArgs struct {
 	IDTagPai         string `json:"id_tag_pai" jsonschema:"required,description=ID da tag pai (ex: OrgnlMndt)"`
 	NumSeqTag        int    `json:"num_seq_tag" jsonschema:"required,description=Número sequencial da tag"`
 	NumSeqMsgTag     int    `json:"num_seq_msg_tag" jsonschema:"required,description=Número sequencial da mensagem tag"`
-}
-
-// VerificarEspecializacaoExiste verifica se uma especialização existe
-func VerificarEspecializacaoExiste(db *sql.DB, idEspTag int) (bool, error) {
-	query := `
-		SELECT 1 
-		FROM spi_especializacao_tag 
-		WHERE id_esp_tag = ?
-	`
-
-	var existe int
-	err := db.QueryRow(query, idEspTag).Scan(&existe)
 
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
+	AdicionarChangeset bool `json:"adicionar_changeset,omitempty" jsonschema:"description=Se verdadeiro, adiciona o script gerado ao changeset do servidor"`
+}
 
+// VerificarVinculoExistente indica se a especialização já está vinculada ao registro da mensagem
+func VerificarVinculoExistente(cat Catalog, args GeraScriptVinculacaoArgs) (bool, error) {
+	vinculos, err := cat.ListarVinculosMensagem(args.IDEveMensagem)
 	if err != nil {
-		return false, fmt.Errorf("erro ao verificar ID existente: %v", err)
+		return false, err
 	}
 
-	return true, nil
+	for _, v := range vinculos {
+		if v.IDEspecializacao == args.IDEspecializacao && v.IDTag == args.IDTag &&
+			v.NumSeqTag == args.NumSeqTag && v.NumSeqMsgTag == args.NumSeqMsgTag {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // GeraScriptVinculacao gera o script SQL para vincular uma especialização a uma mensagem
@@ -52,28 +47,11 @@ func GeraScriptVinculacao(args GeraScriptVinculacaoArgs) string {
 	script.WriteString(fmt.Sprintf("-- Num. Seq. Tag: %d\n", args.NumSeqTag))
 	script.WriteString(fmt.Sprintf("-- Num. Seq. Msg Tag: %d\n\n", args.NumSeqMsgTag))
 
-	// Construir cláusula WHERE para o script
-	whereClause := strings.Builder{}
-	whereClause.WriteString(fmt.Sprintf("mt.id_eve_msg = '%s'\n", strings.Replace(args.IDEveMensagem, "'", "''", -1)))
-	whereClause.WriteString(fmt.Sprintf("                 AND mt.id_tag = '%s'\n", strings.Replace(args.IDTag, "'", "''", -1)))
-	if args.IDTagPai != "" {
-		whereClause.WriteString(fmt.Sprintf("                 AND mt.id_tag_pai = '%s'\n", strings.Replace(args.IDTagPai, "'", "''", -1)))
-	}
-	whereClause.WriteString(fmt.Sprintf("                 AND mt.num_seq_tag = %d\n", args.NumSeqTag))
-	whereClause.WriteString(fmt.Sprintf("                 AND mt.num_seq_msg_tag = %d\n", args.NumSeqMsgTag))
-	whereClause.WriteString(fmt.Sprintf("                 AND em.id_esp_tag = %d", args.IDEspecializacao))
-
 	// Script IF NOT EXISTS + INSERT
 	script.WriteString("IF NOT EXISTS (SELECT 1\n")
-	script.WriteString("               FROM spi_mensagem_tag mt\n")
-	script.WriteString("                    JOIN spi_especializacao_msg_tag em\n")
-	script.WriteString("                    ON em.num_seq_msg_tag = mt.num_seq_msg_tag\n")
-	script.WriteString("                   AND em.num_seq_tag = mt.num_seq_tag\n")
-	script.WriteString("                   AND em.id_eve_msg = mt.id_eve_msg\n")
-	script.WriteString("                   AND em.id_tip_msg = mt.id_tip_msg\n")
-	script.WriteString("                   AND em.id_tag = mt.id_tag\n")
+	script.WriteString(joinVinculacao("               ", "spi_mensagem_tag mt", "spi_especializacao_msg_tag em"))
 	script.WriteString("               WHERE ")
-	script.WriteString(whereClause.String())
+	script.WriteString(whereVinculacao(args, "                 "))
 	script.WriteString(")\nBEGIN\n")
 
 	script.WriteString("  INSERT INTO spi_especializacao_msg_tag (id_esp_tag, id_eve_msg, id_tip_msg, id_tag, num_seq_tag, num_seq_msg_tag)\n")
@@ -98,3 +76,65 @@ func GeraScriptVinculacao(args GeraScriptVinculacaoArgs) string {
 
 	return script.String()
 }
+
+// joinVinculacao monta o FROM/JOIN entre spi_mensagem_tag e spi_especializacao_msg_tag usado nos scripts de vinculação
+func joinVinculacao(recuo string, primeira string, segunda string) string {
+	join := strings.Builder{}
+	join.WriteString(fmt.Sprintf("%sFROM %s\n", recuo, primeira))
+	join.WriteString(fmt.Sprintf("%s     JOIN %s\n", recuo, segunda))
+	join.WriteString(fmt.Sprintf("%s     ON em.num_seq_msg_tag = mt.num_seq_msg_tag\n", recuo))
+	join.WriteString(fmt.Sprintf("%s    AND em.num_seq_tag = mt.num_seq_tag\n", recuo))
+	join.WriteString(fmt.Sprintf("%s    AND em.id_eve_msg = mt.id_eve_msg\n", recuo))
+	join.WriteString(fmt.Sprintf("%s    AND em.id_tip_msg = mt.id_tip_msg\n", recuo))
+	join.WriteString(fmt.Sprintf("%s    AND em.id_tag = mt.id_tag\n", recuo))
+	return join.String()
+}
+
+// whereVinculacao monta a cláusula WHERE que identifica o vínculo pelas mesmas chaves usadas na vinculação
+func whereVinculacao(args GeraScriptVinculacaoArgs, recuo string) string {
+	whereClause := strings.Builder{}
+	whereClause.WriteString(fmt.Sprintf("mt.id_eve_msg = '%s'\n", strings.Replace(args.IDEveMensagem, "'", "''", -1)))
+	whereClause.WriteString(fmt.Sprintf("%sAND mt.id_tag = '%s'\n", recuo, strings.Replace(args.IDTag, "'", "''", -1)))
+	if args.IDTagPai != "" {
+		whereClause.WriteString(fmt.Sprintf("%sAND mt.id_tag_pai = '%s'\n", recuo, strings.Replace(args.IDTagPai, "'", "''", -1)))
+	}
+	whereClause.WriteString(fmt.Sprintf("%sAND mt.num_seq_tag = %d\n", recuo, args.NumSeqTag))
+	whereClause.WriteString(fmt.Sprintf("%sAND mt.num_seq_msg_tag = %d\n", recuo, args.NumSeqMsgTag))
+	whereClause.WriteString(fmt.Sprintf("%sAND em.id_esp_tag = %d", recuo, args.IDEspecializacao))
+	return whereClause.String()
+}
+
+// GeraScriptRollbackVinculacao gera o script SQL que desfaz GeraScriptVinculacao,
+// removendo apenas o vínculo identificado pelas mesmas chaves usadas na inserção
+func GeraScriptRollbackVinculacao(args GeraScriptVinculacaoArgs) string {
+	return geraScriptRemocaoVinculacao(args, "-- Rollback: remove a vinculação da especialização à mensagem\n")
+}
+
+// geraScriptRemocaoVinculacao gera o DELETE protegido de um vínculo, identificado pelas mesmas chaves da vinculação
+func geraScriptRemocaoVinculacao(args GeraScriptVinculacaoArgs, cabecalho string) string {
+	script := strings.Builder{}
+
+	script.WriteString(cabecalho)
+	script.WriteString(fmt.Sprintf("-- ID Especialização: %d\n", args.IDEspecializacao))
+	script.WriteString(fmt.Sprintf("-- ID Evento Mensagem: %s\n", args.IDEveMensagem))
+	script.WriteString(fmt.Sprintf("-- ID Tag: %s\n", args.IDTag))
+	if args.IDTagPai != "" {
+		script.WriteString(fmt.Sprintf("-- ID Tag Pai: %s\n", args.IDTagPai))
+	}
+	script.WriteString(fmt.Sprintf("-- Num. Seq. Tag: %d\n", args.NumSeqTag))
+	script.WriteString(fmt.Sprintf("-- Num. Seq. Msg Tag: %d\n\n", args.NumSeqMsgTag))
+
+	script.WriteString("IF EXISTS (SELECT 1\n")
+	script.WriteString(joinVinculacao("           ", "spi_mensagem_tag mt", "spi_especializacao_msg_tag em"))
+	script.WriteString("           WHERE ")
+	script.WriteString(whereVinculacao(args, "             "))
+	script.WriteString(")\nBEGIN\n")
+
+	script.WriteString("  DELETE em\n")
+	script.WriteString(joinVinculacao("  ", "spi_especializacao_msg_tag em", "spi_mensagem_tag mt"))
+	script.WriteString("  WHERE ")
+	script.WriteString(whereVinculacao(args, "    "))
+	script.WriteString("\nEND")
+
+	return script.String()
+}
@@ -0,0 +1,32 @@
+package esptag
+
+import "testing"
+
+func TestVerificarVinculoExistente(t *testing.T) {
+	cat := novoCatalogoTeste()
+	args := GeraScriptVinculacaoArgs{
+		IDEspecializacao: 3,
+		IDEveMensagem:    "pacs.002.001.10",
+		IDTag:            "TxSts",
+		IDTagPai:         "TxInfAndSts",
+		NumSeqTag:        11,
+		NumSeqMsgTag:     111,
+	}
+
+	existe, err := VerificarVinculoExistente(cat, args)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if !existe {
+		t.Errorf("VerificarVinculoExistente() = false, want true para o vínculo 3 -> 111")
+	}
+
+	args.IDEspecializacao = 5
+	existe, err = VerificarVinculoExistente(cat, args)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if existe {
+		t.Errorf("VerificarVinculoExistente() = true, want false para especialização sem vínculo")
+	}
+}
@@ -0,0 +1,240 @@
+package esptag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// ElementoRaizPadrao é o elemento global que contém as mensagens ISO 20022
+const ElementoRaizPadrao = "Document"
+
+// maxLinhasInsert limita as linhas de cada INSERT ... VALUES (o SQL Server aceita até 1000)
+const maxLinhasInsert = 500
+
+// ImportaXSDArgs define os argumentos de entrada para o MCP
+type ImportaXSDArgs struct {
+	ArquivoXSD    string `json:"arquivo_xsd" jsonschema:"required,description=Caminho local do XSD da mensagem ISO 20022 (ex: xsd/pacs.002.001.12.xsd)"`
+	IDEveMensagem string `json:"id_eve_msg" jsonschema:"description=ID do evento da mensagem; se omitido, é obtido do targetNamespace do XSD (ex: pacs.002.001.12)"`
+	IDTipMensagem string `json:"id_tip_msg" jsonschema:"description=ID do tipo da mensagem; se omitido, segue o das versões já cadastradas ou usa a família da mensagem (ex: pacs.002)"`
+	ElementoRaiz  string `json:"elemento_raiz" jsonschema:"description=Elemento global do XSD que é a raiz da mensagem (padrão: Document)"`
+	Substituir    bool   `json:"substituir" jsonschema:"description=Se verdadeiro, substitui a estrutura da mensagem já cadastrada em spi_mensagem_tag, desde que não possua vínculos"`
+}
+
+// ImportacaoXSD reúne os registros de spi_mensagem_tag montados a partir de um XSD
+type ImportacaoXSD struct {
+	IDEveMensagem string
+	IDTipMensagem string
+	Tags          []MensagemTagInfo // Novos registros, na ordem do documento
+	Substituidas  []MensagemTagInfo // Registros atuais da mensagem, removidos quando substituir é informado
+	Profundidade  int               // Quantidade de níveis da árvore
+	Referencia    string            // Versão da família cuja numeração de num_seq_tag foi seguida
+	Avisos        []string          // Pontos em que a expansão do XSD foi interrompida
+}
+
+// PrimeiroNumSeqMsgTag retorna o primeiro num_seq_msg_tag reservado pela importação
+func (imp *ImportacaoXSD) PrimeiroNumSeqMsgTag() int {
+	return imp.Tags[0].NumSeqMsgTag
+}
+
+// UltimoNumSeqMsgTag retorna o último num_seq_msg_tag reservado pela importação
+func (imp *ImportacaoXSD) UltimoNumSeqMsgTag() int {
+	return imp.Tags[len(imp.Tags)-1].NumSeqMsgTag
+}
+
+// carregarXSD lê o arquivo XSD e expande o elemento raiz informado (Document, se vazio).
+// Os erros retornados são de entrada e podem ser exibidos ao usuário.
+func carregarXSD(arquivoXSD, elementoRaiz string) (*util.XSDSchema, *util.XSDNode, []string, error) {
+	if elementoRaiz == "" {
+		elementoRaiz = ElementoRaizPadrao
+	}
+
+	conteudo, err := os.ReadFile(arquivoXSD)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("não foi possível ler o arquivo '%s': %v", arquivoXSD, err)
+	}
+	schema, err := util.ParseXSD(conteudo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	raiz, avisos, err := schema.Expand(elementoRaiz)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return schema, raiz, avisos, nil
+}
+
+// MontarTagsXSD converte a árvore expandida do XSD em registros de spi_mensagem_tag: num_seq_tag atribuído
+// pela numeração informada, num_seq_msg_tag sequencial a partir de primeiroNumSeqMsgTag e id_tag_pai com o
+// nome do elemento pai (vazio na raiz)
+func MontarTagsXSD(raiz *util.XSDNode, idEveMensagem, idTipMensagem string, primeiroNumSeqMsgTag int, numeracao *NumeracaoNumSeqTag) ([]MensagemTagInfo, int) {
+	var tags []MensagemTagInfo
+	var pilha []string
+	profundidade := 0
+	raiz.Walk(func(node, parent *util.XSDNode, depth int) {
+		pilha = append(pilha[:depth], node.Name)
+		tag := MensagemTagInfo{
+			IDEveMensagem: idEveMensagem,
+			IDTipMensagem: idTipMensagem,
+			IDTag:         node.Name,
+			NumSeqTag:     numeracao.Numerar(pilha),
+			NumSeqMsgTag:  primeiroNumSeqMsgTag + len(tags),
+		}
+		if parent != nil {
+			tag.IDTagPai = parent.Name
+		}
+		tags = append(tags, tag)
+		profundidade = max(profundidade, depth+1)
+	})
+	return tags, profundidade
+}
+
+// IDTipMensagemPadrao retorna o id_tip_msg usado pelas versões já cadastradas da mesma família da mensagem
+// (pacs.002.001.10 para pacs.002.001.12) ou, se não houver, a própria família
+func IDTipMensagemPadrao(cat Catalog, idEveMensagem string) (string, error) {
+	mensagens, err := cat.ListarMensagens()
+	if err != nil {
+		return "", err
+	}
+
+	familia := familiaMensagem(idEveMensagem)
+	for _, m := range mensagens {
+		if m.IDEveMensagem != idEveMensagem && familiaMensagem(m.IDEveMensagem) == familia {
+			return m.IDTipMensagem, nil
+		}
+	}
+	return familia, nil
+}
+
+// MontarImportacaoXSD numera os registros da árvore expandida do XSD a partir do próximo num_seq_msg_tag
+// disponível, com o num_seq_tag da versão de referência da família (NumeracaoNumSeqTag). Os registros atuais
+// da mensagem são retornados em Substituidas.
+func MontarImportacaoXSD(cat Catalog, raiz *util.XSDNode, idEveMensagem, idTipMensagem string) (*ImportacaoXSD, error) {
+	primeiro, err := cat.ObterProximoNumSeqMsgTag()
+	if err != nil {
+		return nil, err
+	}
+
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	numeracao, err := NovaNumeracaoNumSeqTag(cat, idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := &ImportacaoXSD{
+		IDEveMensagem: idEveMensagem,
+		IDTipMensagem: idTipMensagem,
+		Substituidas:  arvore.Tags(),
+		Referencia:    numeracao.Referencia,
+	}
+	imp.Tags, imp.Profundidade = MontarTagsXSD(raiz, idEveMensagem, idTipMensagem, primeiro, numeracao)
+
+	return imp, nil
+}
+
+// GeraScriptImportacaoXSD gera, em uma única transação, a inserção dos registros da mensagem em
+// spi_mensagem_tag. A inserção é protegida por IF NOT EXISTS: não ocorre se a mensagem já estiver
+// cadastrada (ou, ao substituir, se possuir vínculos) nem se a faixa de num_seq_msg_tag já estiver em uso.
+func GeraScriptImportacaoXSD(imp *ImportacaoXSD, arquivoXSD string) string {
+	script := strings.Builder{}
+	idEveMensagem := strings.Replace(imp.IDEveMensagem, "'", "''", -1)
+
+	script.WriteString("-- Script para cadastrar a estrutura da mensagem a partir do XSD\n")
+	script.WriteString(fmt.Sprintf("-- Arquivo XSD: %s\n", arquivoXSD))
+	script.WriteString(fmt.Sprintf("-- ID Evento Mensagem: %s\n", imp.IDEveMensagem))
+	script.WriteString(fmt.Sprintf("-- ID Tipo Mensagem: %s\n", imp.IDTipMensagem))
+	script.WriteString(fmt.Sprintf("-- Tags: %d (num_seq_msg_tag %d a %d)\n", len(imp.Tags), imp.PrimeiroNumSeqMsgTag(), imp.UltimoNumSeqMsgTag()))
+	if imp.Referencia != "" {
+		script.WriteString(fmt.Sprintf("-- num_seq_tag seguindo a numeração de %s\n", imp.Referencia))
+	}
+	if len(imp.Substituidas) > 0 {
+		script.WriteString(fmt.Sprintf("-- Substitui os %d registros atuais da mensagem\n", len(imp.Substituidas)))
+	}
+	script.WriteString("\n")
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	if len(imp.Substituidas) > 0 {
+		script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_eve_msg = '%s')\n", idEveMensagem))
+	} else {
+		script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE id_eve_msg = '%s')\n", idEveMensagem))
+	}
+	script.WriteString(fmt.Sprintf("   AND NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE num_seq_msg_tag BETWEEN %d AND %d)\n",
+		imp.PrimeiroNumSeqMsgTag(), imp.UltimoNumSeqMsgTag()))
+	script.WriteString("BEGIN\n")
+	if len(imp.Substituidas) > 0 {
+		script.WriteString(fmt.Sprintf("  DELETE FROM spi_mensagem_tag WHERE id_eve_msg = '%s'\n\n", idEveMensagem))
+	}
+	escreverInsertMensagemTags(&script, imp.Tags)
+	script.WriteString("END\n\n")
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// GeraScriptRollbackImportacaoXSD gera o script SQL que remove os registros inseridos por
+// GeraScriptImportacaoXSD e, se a estrutura anterior foi substituída, restaura os registros originais
+func GeraScriptRollbackImportacaoXSD(imp *ImportacaoXSD) string {
+	script := strings.Builder{}
+	idEveMensagem := strings.Replace(imp.IDEveMensagem, "'", "''", -1)
+
+	script.WriteString("-- Rollback: remove a estrutura da mensagem importada do XSD\n")
+	script.WriteString(fmt.Sprintf("-- ID Evento Mensagem: %s\n", imp.IDEveMensagem))
+	if len(imp.Substituidas) > 0 {
+		script.WriteString(fmt.Sprintf("-- Restaura os %d registros substituídos\n", len(imp.Substituidas)))
+	}
+	script.WriteString("\n")
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_eve_msg = '%s')\n", idEveMensagem))
+	script.WriteString("BEGIN\n")
+	script.WriteString(fmt.Sprintf("  DELETE FROM spi_mensagem_tag\n  WHERE id_eve_msg = '%s'\n    AND num_seq_msg_tag BETWEEN %d AND %d\n",
+		idEveMensagem, imp.PrimeiroNumSeqMsgTag(), imp.UltimoNumSeqMsgTag()))
+	if len(imp.Substituidas) > 0 {
+		script.WriteString("\n")
+		escreverInsertMensagemTags(&script, imp.Substituidas)
+	}
+	script.WriteString("END\n\n")
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// escreverInsertMensagemTags escreve os INSERTs de spi_mensagem_tag em lotes de maxLinhasInsert linhas
+func escreverInsertMensagemTags(script *strings.Builder, tags []MensagemTagInfo) {
+	for inicio := 0; inicio < len(tags); inicio += maxLinhasInsert {
+		lote := tags[inicio:min(inicio+maxLinhasInsert, len(tags))]
+		if inicio > 0 {
+			script.WriteString("\n")
+		}
+		script.WriteString("  INSERT INTO spi_mensagem_tag (id_eve_msg, id_tip_msg, id_tag, id_tag_pai, num_seq_tag, num_seq_msg_tag)\n")
+		for i, tag := range lote {
+			prefixo := "  VALUES "
+			if i > 0 {
+				prefixo = "       , "
+			}
+			script.WriteString(fmt.Sprintf("%s('%s', '%s', '%s', %s, %d, %d)\n", prefixo,
+				strings.Replace(tag.IDEveMensagem, "'", "''", -1), strings.Replace(tag.IDTipMensagem, "'", "''", -1),
+				strings.Replace(tag.IDTag, "'", "''", -1), idTagPaiSQL(tag.IDTagPai), tag.NumSeqTag, tag.NumSeqMsgTag))
+		}
+	}
+}
+
+// idTagPaiSQL retorna o literal SQL de id_tag_pai: a raiz da mensagem não possui tag pai e é gravada como NULL
+func idTagPaiSQL(idTagPai string) string {
+	if idTagPai == "" {
+		return "NULL"
+	}
+	return fmt.Sprintf("'%s'", strings.Replace(idTagPai, "'", "''", -1))
+}
@@ -0,0 +1,165 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// xsdTesteImportacao é um pacs.002.001.12 reduzido, com um choice no motivo da situação
+const xsdTesteImportacao = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.12">
+	<xs:element name="Document" type="Document"/>
+	<xs:complexType name="Document">
+		<xs:sequence><xs:element name="FIToFIPmtStsRpt" type="Rpt"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Rpt">
+		<xs:sequence>
+			<xs:element name="GrpHdr" type="GrpHdr"/>
+			<xs:element name="TxInfAndSts" type="TxInf" minOccurs="0" maxOccurs="unbounded"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="GrpHdr">
+		<xs:sequence><xs:element name="MsgId" type="Max35Text"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="TxInf">
+		<xs:sequence>
+			<xs:element name="TxSts" type="Max4Text" minOccurs="0"/>
+			<xs:element name="Rsn" type="Rsn" minOccurs="0"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Rsn">
+		<xs:choice>
+			<xs:element name="Cd" type="Max4Text"/>
+			<xs:element name="Prtry" type="Max35Text"/>
+		</xs:choice>
+	</xs:complexType>
+</xs:schema>`
+
+func montarImportacaoTeste(t *testing.T, cat Catalog, idEveMensagem string) *ImportacaoXSD {
+	t.Helper()
+	schema, err := util.ParseXSD([]byte(xsdTesteImportacao))
+	if err != nil {
+		t.Fatalf("ParseXSD() error = %v", err)
+	}
+	raiz, _, err := schema.Expand(ElementoRaizPadrao)
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	idTipMensagem, err := IDTipMensagemPadrao(cat, idEveMensagem)
+	if err != nil {
+		t.Fatalf("IDTipMensagemPadrao() error = %v", err)
+	}
+	imp, err := MontarImportacaoXSD(cat, raiz, idEveMensagem, idTipMensagem)
+	if err != nil {
+		t.Fatalf("MontarImportacaoXSD() error = %v", err)
+	}
+	return imp
+}
+
+func TestMontarImportacaoXSD(t *testing.T) {
+	cat := novoCatalogoTeste()
+	imp := montarImportacaoTeste(t, cat, "pacs.002.001.12")
+
+	if imp.IDTipMensagem != "pacs.002" {
+		t.Errorf("IDTipMensagem = %q, want pacs.002", imp.IDTipMensagem)
+	}
+	if len(imp.Substituidas) != 0 {
+		t.Errorf("Substituidas = %d, want 0 para mensagem nova", len(imp.Substituidas))
+	}
+
+	// num_seq_tag copiado da pacs.002.001.10 pelo caminho; Rsn, Cd e Prtry, fora de StsRsnInf, seguem o anterior
+	esperado := []struct {
+		idTag, idTagPai string
+		numSeqTag       int
+	}{
+		{"Document", "", 1}, {"FIToFIPmtStsRpt", "Document", 2}, {"GrpHdr", "FIToFIPmtStsRpt", 3}, {"MsgId", "GrpHdr", 4},
+		{"TxInfAndSts", "FIToFIPmtStsRpt", 9}, {"TxSts", "TxInfAndSts", 11}, {"Rsn", "TxInfAndSts", 12}, {"Cd", "Rsn", 13}, {"Prtry", "Rsn", 14},
+	}
+	if imp.Referencia != "pacs.002.001.10" {
+		t.Errorf("Referencia = %q, want pacs.002.001.10", imp.Referencia)
+	}
+	if len(imp.Tags) != len(esperado) {
+		t.Fatalf("Tags = %d, want %d", len(imp.Tags), len(esperado))
+	}
+
+	// O maior num_seq_msg_tag do catálogo de teste é o 204, da pacs.008.001.08
+	for i, e := range esperado {
+		tag := imp.Tags[i]
+		if tag.IDTag != e.idTag || tag.IDTagPai != e.idTagPai || tag.NumSeqTag != e.numSeqTag || tag.NumSeqMsgTag != 205+i {
+			t.Errorf("Tags[%d] = %+v, want %s (pai %q) com num_seq_tag %d e num_seq_msg_tag %d", i, tag, e.idTag, e.idTagPai, e.numSeqTag, 205+i)
+		}
+	}
+	if imp.Profundidade != 5 {
+		t.Errorf("Profundidade = %d, want 5", imp.Profundidade)
+	}
+
+	// Mensagem já cadastrada: os registros atuais são apontados para substituição. Sem outra versão da
+	// família como referência, num_seq_tag vai de 1 a N
+	existente := montarImportacaoTeste(t, cat, "pacs.002.001.10")
+	if len(existente.Substituidas) != len(tagsPacs002()) {
+		t.Errorf("Substituidas = %d, want %d", len(existente.Substituidas), len(tagsPacs002()))
+	}
+	for i, tag := range existente.Tags {
+		if existente.Referencia != "" || tag.NumSeqTag != i+1 {
+			t.Errorf("Tags[%d] = %+v com referência %q, want num_seq_tag %d sem referência", i, tag, existente.Referencia, i+1)
+		}
+	}
+
+	// Família sem versões cadastradas usa a própria família como id_tip_msg
+	if id, _ := IDTipMensagemPadrao(cat, "camt.056.001.08"); id != "camt.056" {
+		t.Errorf("IDTipMensagemPadrao() = %q, want camt.056", id)
+	}
+}
+
+func TestGeraScriptImportacaoXSD(t *testing.T) {
+	cat := novoCatalogoTeste()
+	imp := montarImportacaoTeste(t, cat, "pacs.002.001.12")
+
+	script := GeraScriptImportacaoXSD(imp, "pacs.002.001.12.xsd")
+	for _, trecho := range []string{
+		"IF NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE id_eve_msg = 'pacs.002.001.12')",
+		"AND NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE num_seq_msg_tag BETWEEN 205 AND 213)",
+		"  VALUES ('pacs.002.001.12', 'pacs.002', 'Document', NULL, 1, 205)\n",
+		"       , ('pacs.002.001.12', 'pacs.002', 'Prtry', 'Rsn', 14, 213)\n",
+		"-- num_seq_tag seguindo a numeração de pacs.002.001.10\n",
+		"COMMIT TRANSACTION",
+	} {
+		if !strings.Contains(script, trecho) {
+			t.Errorf("GeraScriptImportacaoXSD() não contém %q:\n%s", trecho, script)
+		}
+	}
+	if strings.Contains(script, "DELETE") {
+		t.Errorf("GeraScriptImportacaoXSD() de mensagem nova não deveria remover registros:\n%s", script)
+	}
+
+	rollback := GeraScriptRollbackImportacaoXSD(imp)
+	if !strings.Contains(rollback, "AND num_seq_msg_tag BETWEEN 205 AND 213") || strings.Contains(rollback, "INSERT") {
+		t.Errorf("GeraScriptRollbackImportacaoXSD() deveria apenas remover a faixa importada:\n%s", rollback)
+	}
+
+	// Substituição: remove a estrutura atual, protegida pela ausência de vínculos, e o rollback a restaura
+	substituicao := montarImportacaoTeste(t, cat, "pacs.002.001.10")
+	script = GeraScriptImportacaoXSD(substituicao, "pacs.002.001.10.xsd")
+	if !strings.Contains(script, "IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_eve_msg = 'pacs.002.001.10')") ||
+		!strings.Contains(script, "DELETE FROM spi_mensagem_tag WHERE id_eve_msg = 'pacs.002.001.10'") {
+		t.Errorf("GeraScriptImportacaoXSD() com substituição inesperado:\n%s", script)
+	}
+	rollback = GeraScriptRollbackImportacaoXSD(substituicao)
+	if !strings.Contains(rollback, "('pacs.002.001.10', 'pacs.002', 'TxSts', 'TxInfAndSts', 11, 111)") {
+		t.Errorf("GeraScriptRollbackImportacaoXSD() deveria restaurar os registros substituídos:\n%s", rollback)
+	}
+}
+
+func TestEscreverInsertMensagemTagsLotes(t *testing.T) {
+	tags := make([]MensagemTagInfo, maxLinhasInsert+1)
+	for i := range tags {
+		tags[i] = MensagemTagInfo{IDEveMensagem: "x", IDTipMensagem: "x", IDTag: "T", IDTagPai: "P", NumSeqTag: i + 1, NumSeqMsgTag: i + 1}
+	}
+
+	var script strings.Builder
+	escreverInsertMensagemTags(&script, tags)
+	if n := strings.Count(script.String(), "INSERT INTO spi_mensagem_tag"); n != 2 {
+		t.Errorf("INSERTs = %d, want 2 para %d linhas", n, len(tags))
+	}
+}
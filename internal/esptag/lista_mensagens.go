@@ -0,0 +1,26 @@
+package esptag
+
+import (
+	"strings"
+)
+
+// ListaMensagensArgs define os argumentos de entrada para o MCP
+type ListaMensagensArgs struct {
+	Filtro string `json:"filtro" jsonschema:"description=Trecho do id_eve_msg ou id_tip_msg para filtrar as mensagens (ex: pacs.008)"`
+}
+
+// FiltrarMensagens retorna as mensagens cujo id_eve_msg ou id_tip_msg contém o filtro, sem diferenciar maiúsculas
+func FiltrarMensagens(mensagens []MensagemResumo, filtro string) []MensagemResumo {
+	filtro = strings.ToLower(strings.TrimSpace(filtro))
+	if filtro == "" {
+		return mensagens
+	}
+
+	var filtradas []MensagemResumo
+	for _, m := range mensagens {
+		if strings.Contains(strings.ToLower(m.IDEveMensagem), filtro) || strings.Contains(strings.ToLower(m.IDTipMensagem), filtro) {
+			filtradas = append(filtradas, m)
+		}
+	}
+	return filtradas
+}
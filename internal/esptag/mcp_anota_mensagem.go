@@ -0,0 +1,91 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterAnotaMensagem registra o MCP de anotação de uma mensagem completa com as especializações de cada elemento
+func RegisterAnotaMensagem(server *mcp_golang.Server, cat Catalog, scorer Scorer) error {
+	return server.RegisterTool("sq_pix_esptag_anota_mensagem",
+		"Resolve cada elemento de uma mensagem PIX completa em spi_mensagem_tag e retorna a mensagem anotada com as especializações (id_esp_tag/dsc_esp_tag) vinculadas, sinalizando os elementos não cadastrados",
+		func(args AnotaMensagemArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if strings.TrimSpace(args.XMLMensagem) == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: A mensagem XML não pode ser vazia")), nil
+			}
+			if args.Formato == "" {
+				args.Formato = FormatoAnotacaoXML
+			}
+			if args.Formato != FormatoAnotacaoXML && args.Formato != FormatoAnotacaoTabela {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+					"Erro: Formato '%s' inválido. Utilize '%s' ou '%s'.", args.Formato, FormatoAnotacaoXML, FormatoAnotacaoTabela))), nil
+			}
+
+			if _, err := util.FindAllOccurrences(args.XMLMensagem); err != nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro ao processar a mensagem XML: %v", err))), nil
+			}
+
+			var resultado strings.Builder
+
+			// Identifica a mensagem pelo XML quando id_eve_msg não é informado
+			idEveMensagem, origemID, avisos := IdentificarMensagem(args.XMLMensagem, args.IDEveMensagem)
+			if idEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem (id_eve_msg) não informado e não identificado no XML pelo namespace do Document ou pelo MsgDefIdr do AppHdr")), nil
+			}
+
+			arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar estrutura da mensagem: %v", err)
+			}
+			if len(arvore.Tags()) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+					"Erro: A mensagem '%s' não possui tags cadastradas em spi_mensagem_tag. Use sq_pix_esptag_lista_mensagens para ver as mensagens disponíveis.", idEveMensagem))), nil
+			}
+
+			anotada, err := AnotarMensagem(cat, scorer, args.XMLMensagem, idEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao anotar mensagem: %v", err)
+			}
+
+			for _, aviso := range avisos {
+				resultado.WriteString(fmt.Sprintf("Aviso: %s\n", aviso))
+			}
+			if origemID != "" {
+				resultado.WriteString(fmt.Sprintf("ID do evento da mensagem identificado pelo %s: %s\n", origemID, idEveMensagem))
+			}
+			resultado.WriteString(fmt.Sprintf("Elementos: %d | com especializações: %d | não cadastrados: %d | correspondência incerta: %d\n",
+				len(anotada.Elementos), anotada.QtdEspecializados(), anotada.Contar(ElementoNaoCadastrado), anotada.Contar(ElementoIncerto)))
+			if anotada.Ignorados > 0 {
+				resultado.WriteString(fmt.Sprintf("Elementos fora do Document (envelope e AppHdr) ignorados: %d\n", anotada.Ignorados))
+			}
+			resultado.WriteString("\n")
+
+			if args.Formato == FormatoAnotacaoTabela {
+				resultado.WriteString(anotada.Tabela())
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			resultado.WriteString(strings.TrimRight(anotada.XMLAnotado(), "\n") + "\n")
+
+			// Os elementos não cadastrados também são listados fora do XML, para não passarem despercebidos
+			if anotada.Contar(ElementoNaoCadastrado) > 0 {
+				resultado.WriteString("\nElementos não cadastrados em spi_mensagem_tag:\n")
+				for _, e := range anotada.Elementos {
+					if e.Situacao == ElementoNaoCadastrado {
+						resultado.WriteString(fmt.Sprintf("- %s\n", strings.Join(e.Ocorrencia.Path, " > ")))
+					}
+				}
+			}
+			if anotada.Contar(ElementoIncerto) > 0 {
+				resultado.WriteString("\nElementos com correspondência incerta podem ser detalhados com sq_pix_esptag_consulta_dados_mensagem.\n")
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
@@ -0,0 +1,127 @@
+package esptag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// ChangesetVazioArgs define os argumentos (nenhum) das ferramentas de changeset sem parâmetros
+type ChangesetVazioArgs struct{}
+
+// RemoveItemChangesetArgs define os argumentos de entrada para remoção de um item do changeset
+type RemoveItemChangesetArgs struct {
+	IDItem int `json:"id_item" jsonschema:"required,description=ID do item do changeset a ser removido"`
+}
+
+// RegisterChangeset registra as ferramentas MCP de listagem, remoção, limpeza e exportação do changeset
+func RegisterChangeset(server *mcp_golang.Server, cat Catalog, changesets *Changesets) error {
+	err := server.RegisterTool("sq_pix_esptag_changeset_lista",
+		"Lista os scripts acumulados no changeset, com os conflitos e avisos encontrados",
+		func(ctx context.Context, args ChangesetVazioArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
+			itens := cs.Itens()
+			if len(itens) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("O changeset está vazio. Use adicionar_changeset=true nas ferramentas de geração de script para incluir itens.")), nil
+			}
+
+			conflitos, avisos, err := cs.Validar(cat)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao validar changeset: %v", err)
+			}
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Changeset com %d itens:\n\n", len(itens)))
+			for _, item := range itens {
+				resultado.WriteString(fmt.Sprintf("%d. [%s] %s\n", item.ID, item.Tipo, item.Descricao()))
+			}
+			escreverProblemasChangeset(&resultado, conflitos, avisos)
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	err = server.RegisterTool("sq_pix_esptag_changeset_remove",
+		"Remove um item do changeset pelo seu ID",
+		func(ctx context.Context, args RemoveItemChangesetArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
+			if !cs.Remover(args.IDItem) {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Item %d não encontrado no changeset", args.IDItem))), nil
+			}
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Item %d removido do changeset.", args.IDItem))), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	err = server.RegisterTool("sq_pix_esptag_changeset_limpa",
+		"Remove todos os itens do changeset",
+		func(ctx context.Context, args ChangesetVazioArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
+			removidos := cs.Limpar()
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Changeset limpo: %d itens removidos.", removidos))), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return server.RegisterTool("sq_pix_esptag_changeset_exporta",
+		"Exporta o changeset como um único script de implantação, em transação e ordenado por dependência, junto com o script de rollback",
+		func(ctx context.Context, args ChangesetVazioArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
+			itens := cs.ItensOrdenados()
+			if len(itens) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("O changeset está vazio. Nenhum script será gerado.")), nil
+			}
+
+			conflitos, avisos, err := cs.Validar(cat)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao validar changeset: %v", err)
+			}
+
+			var resultado strings.Builder
+			if len(conflitos) > 0 {
+				resultado.WriteString("Erro: O changeset possui conflitos e não pode ser exportado. Remova ou corrija os itens indicados.\n")
+				escreverProblemasChangeset(&resultado, conflitos, avisos)
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			if len(avisos) > 0 {
+				escreverProblemasChangeset(&resultado, nil, avisos)
+				resultado.WriteString("\n")
+			}
+			resultado.WriteString(GeraScriptChangeset(itens))
+			escreverScriptRollback(&resultado, GeraScriptRollbackChangeset(itens))
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
+
+// escreverProblemasChangeset formata os conflitos e avisos da validação do changeset
+func escreverProblemasChangeset(resultado *strings.Builder, conflitos []string, avisos []string) {
+	if len(conflitos) > 0 {
+		resultado.WriteString("\nConflitos:\n")
+		for _, c := range conflitos {
+			resultado.WriteString(fmt.Sprintf("- %s\n", c))
+		}
+	}
+	if len(avisos) > 0 {
+		resultado.WriteString("\nAvisos:\n")
+		for _, a := range avisos {
+			resultado.WriteString(fmt.Sprintf("- %s\n", a))
+		}
+	}
+}
+
+// escreverAdicaoChangeset informa o resultado da inclusão de um script no changeset
+func escreverAdicaoChangeset(resultado *strings.Builder, item ItemChangeset, err error) {
+	if err != nil {
+		resultado.WriteString(fmt.Sprintf("\nAviso: Script não adicionado ao changeset: %v\n", err))
+		return
+	}
+	resultado.WriteString(fmt.Sprintf("\nScript adicionado ao changeset como item %d.\n", item.ID))
+}
@@ -0,0 +1,75 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterCoberturaMensagem registra o MCP de relatório de cobertura de especializações de uma mensagem
+func RegisterCoberturaMensagem(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_cobertura_mensagem",
+		"Gera um relatório com a árvore de tags de uma mensagem, as especializações vinculadas a cada tag e as tags sem especialização",
+		func(args CoberturaMensagemArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem não pode ser vazio")), nil
+			}
+
+			cobertura, err := GerarCoberturaMensagem(cat, args.IDEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao gerar cobertura da mensagem: %v", err)
+			}
+
+			if cobertura == nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Nenhuma tag encontrada em spi_mensagem_tag para a mensagem '%s'.", args.IDEveMensagem))), nil
+			}
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Cobertura de especializações da mensagem %s (%s):\n\n", cobertura.IDEveMensagem, cobertura.IDTipMensagem))
+
+			// Árvore indentada
+			for _, t := range cobertura.Tags {
+				resultado.WriteString(strings.Repeat("  ", t.Profundidade))
+				resultado.WriteString(fmt.Sprintf("%s (num_seq_tag %d, num_seq_msg_tag %d)", t.Tag.IDTag, t.Tag.NumSeqTag, t.Tag.NumSeqMsgTag))
+				for i, esp := range t.Especializacoes {
+					if i == 0 {
+						resultado.WriteString(" => ")
+					} else {
+						resultado.WriteString("; ")
+					}
+					resultado.WriteString(fmt.Sprintf("[%d] %s", esp.ID, esp.Descricao))
+				}
+				resultado.WriteString("\n")
+			}
+
+			semEspecializacao := cobertura.TagsSemEspecializacao()
+			if len(semEspecializacao) > 0 {
+				resultado.WriteString(fmt.Sprintf("\nTags sem especialização (%d):\n", len(semEspecializacao)))
+				for _, tag := range semEspecializacao {
+					resultado.WriteString(fmt.Sprintf("- %s (num_seq_tag %d, num_seq_msg_tag %d)\n", tag.Caminho, tag.NumSeqTag, tag.NumSeqMsgTag))
+				}
+			}
+
+			if len(cobertura.VinculosSemTag) > 0 {
+				resultado.WriteString(fmt.Sprintf("\nAviso: Vínculos sem tag correspondente em spi_mensagem_tag (%d):\n", len(cobertura.VinculosSemTag)))
+				for _, v := range cobertura.VinculosSemTag {
+					resultado.WriteString(fmt.Sprintf("- id_esp_tag %d | %s (num_seq_tag %d, num_seq_msg_tag %d)\n", v.IDEspecializacao, v.IDTag, v.NumSeqTag, v.NumSeqMsgTag))
+				}
+			}
+
+			// Resumo
+			totalTags := len(cobertura.Tags)
+			comEspecializacao := totalTags - len(semEspecializacao)
+			resultado.WriteString("\nResumo:\n")
+			resultado.WriteString(fmt.Sprintf("- Tags: %d\n", totalTags))
+			resultado.WriteString(fmt.Sprintf("- Tags com especialização: %d (%.1f%%)\n", comEspecializacao, float64(comEspecializacao)*100/float64(totalTags)))
+			resultado.WriteString(fmt.Sprintf("- Tags sem especialização: %d\n", len(semEspecializacao)))
+			resultado.WriteString(fmt.Sprintf("- Vínculos: %d\n", cobertura.TotalVinculos))
+			resultado.WriteString(fmt.Sprintf("- Especializações distintas: %d\n", cobertura.EspecializacoesDistintas()))
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
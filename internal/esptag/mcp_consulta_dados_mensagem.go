@@ -1,9 +1,8 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 
 	"sq_pix/internal/esptag/util"
@@ -12,10 +11,10 @@ import (
 )
 
 // RegisterConsultaDadosMensagem registra o MCP de consulta de dados da mensagem
-// Utiliza as funções do database.go para operações com o banco de dados
-func RegisterConsultaDadosMensagem(server *mcp_golang.Server, db *sql.DB) error {
+// Utiliza o Catalog para as operações sobre spi_mensagem_tag e o Scorer para ordenar os candidatos
+func RegisterConsultaDadosMensagem(server *mcp_golang.Server, cat Catalog, scorer Scorer) error {
 	return server.RegisterTool("sq_pix_esptag_consulta_dados_mensagem",
-		"Consulta dados da mensagem a partir de um trecho XML",
+		"Consulta dados da mensagem a partir de um trecho XML, caminho ou XPath simples",
 		func(args ConsultaDadosMensagemArgs) (*mcp_golang.ToolResponse, error) {
 
 			// Validação de entrada
@@ -25,102 +24,210 @@ func RegisterConsultaDadosMensagem(server *mcp_golang.Server, db *sql.DB) error
 			if args.NomeTag == "" {
 				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: Nome da tag não pode ser vazio")), nil
 			}
-			if args.IDEveMensagem == "" {
-				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem (id_eve_msg) não pode ser vazio")), nil
+
+			var resposta strings.Builder
+
+			// Identifica a mensagem pelo XML quando id_eve_msg não é informado
+			idEveMensagem, origemID, avisos := IdentificarMensagem(args.CaminhoXML, args.IDEveMensagem)
+			if idEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem (id_eve_msg) não informado e não identificado no XML pelo namespace do Document ou pelo MsgDefIdr do AppHdr")), nil
+			}
+			args.IDEveMensagem = idEveMensagem
+
+			for _, aviso := range avisos {
+				resposta.WriteString(fmt.Sprintf("Aviso: %s\n", aviso))
+			}
+			if origemID != "" {
+				resposta.WriteString(fmt.Sprintf("ID do evento da mensagem identificado pelo %s: %s\n", origemID, idEveMensagem))
+			}
+			if len(avisos) > 0 || origemID != "" {
+				resposta.WriteString("\n")
 			}
 
-			// --- Step 1: Parse XML correctly to find true parent and subpath ---
-			tagPaiCorreta, subcaminhoXML, parseErr := util.FindTagParentAndPath(args.CaminhoXML, args.NomeTag)
+			// --- Step 1: Parse XML (or plain path/XPath) to find every occurrence with its true parent and subpath ---
+			ocorrencias, parseErr := util.ResolveTagOccurrences(args.CaminhoXML, args.NomeTag)
 			if parseErr != nil {
 				// Return parsing error to the user
-				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro ao processar XML: %v", parseErr))), nil
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro ao processar caminho XML: %v", parseErr))), nil
+			}
+
+			// --- Step 2: Select the occurrence requested by the caller, if any ---
+			if args.Ocorrencia < 0 || args.Ocorrencia > len(ocorrencias) {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Ocorrência %d inválida. A tag '%s' aparece %d vez(es) no XML informado.", args.Ocorrencia, args.NomeTag, len(ocorrencias)))), nil
+			}
+			indices := make([]int, 0, len(ocorrencias))
+			if args.Ocorrencia > 0 {
+				indices = append(indices, args.Ocorrencia-1)
+			} else {
+				for i := range ocorrencias {
+					indices = append(indices, i)
+				}
 			}
 
-			// --- Step 3: Query Database (without parent filter initially) ---
-			resultados, err := BuscarTagNaBase(db, subcaminhoXML, args.NomeTag, args.IDEveMensagem)
+			// Vínculos e especializações usados nas sugestões são carregados uma única vez para todas as ocorrências
+			baseSugestoes, err := CarregarBaseSugestoes(cat)
 			if err != nil {
 				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro na consulta: %v", err))), nil
 			}
 
-			// --- Step 4: Score results using correct parent and path ---
-			for i := range resultados {
-				// Score starts at 10 (from BuscarTagNaBase)
-
-				// Add score based on CORRECT parent match from XML parser
-				if tagPaiCorreta != "" && resultados[i].IDTagPai == tagPaiCorreta {
-					resultados[i].Score += 15 // Higher score for correct parent match
-					log.Printf("DEBUG: Scoring - Correct parent '%s' matched DB parent '%s' for tag '%s'. Score +15.", tagPaiCorreta, resultados[i].IDTagPai, resultados[i].IDTag)
-				} else if tagPaiCorreta != "" && resultados[i].IDTagPai != tagPaiCorreta {
-					log.Printf("DEBUG: Scoring - Correct parent '%s' DID NOT match DB parent '%s' for tag '%s'.", tagPaiCorreta, resultados[i].IDTagPai, resultados[i].IDTag)
-					// Optionally decrease score for mismatch?
-					// resultados[i].Score -= 5
-				} else {
-					// No parent found in XML or DB parent is empty
-					log.Printf("DEBUG: Scoring - No correct parent found in XML or DB parent empty for tag '%s'.", resultados[i].IDTag)
+			// Uma única ocorrência mantém a resposta sem cabeçalhos por ocorrência
+			if len(ocorrencias) == 1 || args.Ocorrencia > 0 {
+				ocorrencia := ocorrencias[indices[0]]
+				if len(ocorrencias) > 1 {
+					escreverCabecalhoOcorrencia(&resposta, ocorrencia, indices[0], len(ocorrencias))
 				}
 
-				// Reconstruct DB path for path scoring
-				caminhoDB, reconErr := ReconstruirCaminho(db, resultados[i])
-				if reconErr != nil {
-					resultados[i].Caminho = fmt.Sprintf("[%s] (Erro ao reconstruir caminho: %v)", resultados[i].IDTag, reconErr)
-					// Keep base score if path reconstruction fails
-				} else {
-					resultados[i].Caminho = strings.Join(caminhoDB, " > ")
-					// Add score based on path correspondence using CORRECT subpath from XML parser
-					pontuacaoAdicional := CalcularPontuacaoCorrespondencia(subcaminhoXML, caminhoDB)
-					resultados[i].Score += pontuacaoAdicional
-					log.Printf("DEBUG: Scoring - Path match score for '%s': %d (XML subpath: %v)", resultados[i].IDTag, pontuacaoAdicional, subcaminhoXML)
+				// --- Steps 3-5: Query Database, score and sort results using correct parent, path and structure ---
+				resultados, err := RankearTagsNaBase(cat, scorer, ocorrencia, args.NomeTag, args.IDEveMensagem)
+				if err != nil {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro na consulta: %v", err))), nil
 				}
-			}
-
-			// --- Step 5: Sort results by final score ---
-			// Using selection sort simple
-			for i := 0; i < len(resultados); i++ {
-				maxIdx := i
-				for j := i + 1; j < len(resultados); j++ {
-					if resultados[j].Score > resultados[maxIdx].Score {
-						maxIdx = j
-					}
-				}
-				if maxIdx != i {
-					resultados[i], resultados[maxIdx] = resultados[maxIdx], resultados[i]
+				if err := PreencherSugestoes(cat, baseSugestoes, resultados); err != nil {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro na consulta: %v", err))), nil
 				}
+
+				// --- Step 6: Format the response ---
+				escreverOpcoesConsulta(&resposta, resultados, args.NomeTag, args.IDEveMensagem)
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resposta.String())), nil
 			}
 
-			// --- Step 6: Format the response ---
-			var resposta strings.Builder
-			if len(resultados) == 0 {
-				resposta.WriteString(fmt.Sprintf("Nenhum registro encontrado para a tag '%s' na mensagem '%s'.\n", args.NomeTag, args.IDEveMensagem))
-				resposta.WriteString("Verifique se o nome da tag e o ID da mensagem estão corretos e se a tag existe na estrutura desta mensagem no sistema.")
-			} else {
-				resposta.WriteString(fmt.Sprintf("Encontrados %d possíveis registros para a tag '%s' na mensagem '%s'.\n", len(resultados), args.NomeTag, args.IDEveMensagem))
-				resposta.WriteString("Os resultados estão ordenados pelo melhor match (maior pontuação):\n\n")
-
-				// Sinaliza se temos uma correspondência clara ou se existem múltiplas opções possíveis
-				if len(resultados) == 1 || (len(resultados) > 1 && resultados[0].Score > resultados[1].Score+10) { // Increased threshold for "exact"
-					resposta.WriteString("*** MELHOR CORRESPONDÊNCIA ENCONTRADA ***\n\n")
-				} else if len(resultados) > 1 {
-					resposta.WriteString("*** MÚLTIPLAS OPÇÕES POSSÍVEIS - VERIFICAÇÃO MANUAL RECOMENDADA ***\n\n")
+			resposta.WriteString(fmt.Sprintf("A tag '%s' aparece %d vezes no XML informado. Os registros foram ranqueados para cada ocorrência; ", args.NomeTag, len(ocorrencias)))
+			resposta.WriteString("utilize o parâmetro ocorrencia para analisar apenas uma delas.\n\n")
+
+			// Ocorrências com o mesmo caminho e a mesma vizinhança têm o mesmo ranking, que é exibido apenas na primeira
+			primeiraPorCaminho := make(map[string]int)
+			for _, i := range indices {
+				escreverCabecalhoOcorrencia(&resposta, ocorrencias[i], i, len(ocorrencias))
+
+				chave := strings.Join([]string{
+					strings.Join(ocorrencias[i].Path, "/"),
+					strings.Join(ocorrencias[i].Children, ","),
+					strings.Join(ocorrencias[i].PrecedingSiblings, ","),
+					strings.Join(ocorrencias[i].FollowingSiblings, ","),
+				}, "|")
+				if anterior, ok := primeiraPorCaminho[chave]; ok {
+					resposta.WriteString(fmt.Sprintf("Mesmo caminho e estrutura da ocorrência %d; as opções são as mesmas.\n\n", anterior+1))
+					continue
 				}
+				primeiraPorCaminho[chave] = i
 
-				for i, info := range resultados {
-					resposta.WriteString(fmt.Sprintf("Opção %d (Pontuação: %d):\n", i+1, info.Score))
-					resposta.WriteString(fmt.Sprintf("- Caminho DB: %s\n", info.Caminho)) // Show reconstructed DB path
-					resposta.WriteString(fmt.Sprintf("- ID Evento Mensagem: %s\n", info.IDEveMensagem))
-					// resposta.WriteString(fmt.Sprintf("- ID Tipo Mensagem: %s\n", info.IDTipMensagem)) // Maybe less relevant?
-					resposta.WriteString(fmt.Sprintf("- ID Tag: %s\n", info.IDTag))
-					resposta.WriteString(fmt.Sprintf("- ID Tag Pai (DB): %s\n", info.IDTagPai)) // Label as DB parent
-					resposta.WriteString(fmt.Sprintf("- Num. Seq. Tag: %d\n", info.NumSeqTag))
-					resposta.WriteString(fmt.Sprintf("- Num. Seq. Msg Tag: %d\n", info.NumSeqMsgTag))
-
-					// Adiciona comando para usar este registro diretamente
-					resposta.WriteString("\nPara criar vinculação com esta opção, use:\n")
-					// Ensure the generated command uses the correct info from the database result
-					resposta.WriteString(fmt.Sprintf("/mcp sq-pix-esptag sq_pix_esptag_gera_script_vinculacao {\"id_esp_tag\": SEU_ID_ESP_TAG, \"id_eve_msg\": \"%s\", \"id_tag\": \"%s\", \"id_tag_pai\": \"%s\", \"num_seq_tag\": %d, \"num_seq_msg_tag\": %d}\n\n",
-						info.IDEveMensagem, info.IDTag, info.IDTagPai, info.NumSeqTag, info.NumSeqMsgTag))
+				resultados, err := RankearTagsNaBase(cat, scorer, ocorrencias[i], args.NomeTag, args.IDEveMensagem)
+				if err != nil {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro na consulta: %v", err))), nil
 				}
+				if err := PreencherSugestoes(cat, baseSugestoes, resultados); err != nil {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro na consulta: %v", err))), nil
+				}
+				escreverOpcoesConsulta(&resposta, resultados, args.NomeTag, args.IDEveMensagem)
+				resposta.WriteString("\n")
 			}
 
 			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resposta.String())), nil
 		})
 }
+
+// escreverOpcoesConsulta formata os registros ranqueados com o comando sugerido para cada opção
+func escreverOpcoesConsulta(resposta *strings.Builder, resultados []MensagemTagInfo, nomeTag string, idEveMensagem string) {
+	if len(resultados) == 0 {
+		resposta.WriteString(fmt.Sprintf("Nenhum registro encontrado para a tag '%s' na mensagem '%s'.\n", nomeTag, idEveMensagem))
+		resposta.WriteString("Verifique se o nome da tag e o ID da mensagem estão corretos e se a tag existe na estrutura desta mensagem no sistema.")
+	} else {
+		resposta.WriteString(fmt.Sprintf("Encontrados %d possíveis registros para a tag '%s' na mensagem '%s'.\n", len(resultados), nomeTag, idEveMensagem))
+		resposta.WriteString("Os resultados estão ordenados pelo melhor match (maior pontuação):\n\n")
+
+		// Sinaliza se temos uma correspondência clara ou se existem múltiplas opções possíveis
+		if CorrespondenciaClara(resultados) {
+			resposta.WriteString("*** MELHOR CORRESPONDÊNCIA ENCONTRADA ***\n\n")
+		} else if len(resultados) > 1 {
+			resposta.WriteString("*** MÚLTIPLAS OPÇÕES POSSÍVEIS - VERIFICAÇÃO MANUAL RECOMENDADA ***\n\n")
+		}
+
+		for i, info := range resultados {
+			resposta.WriteString(fmt.Sprintf("Opção %d (Pontuação: %d):\n", i+1, info.Score))
+			resposta.WriteString(fmt.Sprintf("- Caminho DB: %s\n", info.Caminho)) // Show reconstructed DB path
+			resposta.WriteString(fmt.Sprintf("- ID Evento Mensagem: %s\n", info.IDEveMensagem))
+			// resposta.WriteString(fmt.Sprintf("- ID Tipo Mensagem: %s\n", info.IDTipMensagem)) // Maybe less relevant?
+			resposta.WriteString(fmt.Sprintf("- ID Tag: %s\n", info.IDTag))
+			resposta.WriteString(fmt.Sprintf("- ID Tag Pai (DB): %s\n", info.IDTagPai)) // Label as DB parent
+			resposta.WriteString(fmt.Sprintf("- Num. Seq. Tag: %d\n", info.NumSeqTag))
+			resposta.WriteString(fmt.Sprintf("- Num. Seq. Msg Tag: %d\n", info.NumSeqMsgTag))
+			if len(info.Regras) > 0 {
+				resposta.WriteString("- Pontuação detalhada:\n")
+				for _, regra := range info.Regras {
+					resposta.WriteString(fmt.Sprintf("    %+d %s: %s\n", regra.Pontos, regra.Regra, regra.Detalhe))
+				}
+			}
+
+			escreverSugestoesEspecializacao(resposta, info.Sugestoes)
+
+			// Adiciona comando para usar este registro diretamente, com a especialização sugerida quando houver
+			idEspTag := "SEU_ID_ESP_TAG"
+			if id := IDEspecializacaoSugerido(info.Sugestoes); id > 0 {
+				idEspTag = fmt.Sprintf("%d", id)
+			}
+			resposta.WriteString("\nPara criar vinculação com esta opção, use:\n")
+			// Ensure the generated command uses the correct info from the database result
+			resposta.WriteString(fmt.Sprintf("/mcp sq-pix-esptag sq_pix_esptag_gera_script_vinculacao {\"id_esp_tag\": %s, \"id_eve_msg\": \"%s\", \"id_tag\": \"%s\", \"id_tag_pai\": \"%s\", \"num_seq_tag\": %d, \"num_seq_msg_tag\": %d}\n\n",
+				idEspTag, info.IDEveMensagem, info.IDTag, info.IDTagPai, info.NumSeqTag, info.NumSeqMsgTag))
+		}
+	}
+}
+
+// escreverSugestoesEspecializacao lista as especializações vinculadas à mesma tag em outras mensagens,
+// com o vínculo mais similar de cada uma
+func escreverSugestoesEspecializacao(resposta *strings.Builder, sugestoes []SugestaoEspecializacao) {
+	if len(sugestoes) == 0 {
+		return
+	}
+
+	resposta.WriteString("- Especializações sugeridas (vinculadas à mesma tag em outras mensagens):\n")
+	for i, s := range sugestoes {
+		if i == maxSugestoesExibidas {
+			resposta.WriteString(fmt.Sprintf("    ... e mais %d. Use sq_pix_esptag_consulta_vinculos_especializacao para detalhar.\n", len(sugestoes)-i))
+			break
+		}
+		if len(s.Referencias) == 0 {
+			resposta.WriteString(fmt.Sprintf("    ID %d - %s (já vinculada a este registro)\n", s.ID, s.Descricao))
+			continue
+		}
+
+		referencia := s.Referencias[0]
+		resposta.WriteString(fmt.Sprintf("    ID %d - %s (similaridade %d%%): %s em %s",
+			s.ID, s.Descricao, s.Similaridade, referencia.Caminho, referencia.IDEveMensagem))
+		outrasMensagens := make(map[string]bool)
+		for _, r := range s.Referencias[1:] {
+			if r.IDEveMensagem != referencia.IDEveMensagem {
+				outrasMensagens[r.IDEveMensagem] = true
+			}
+		}
+		if len(outrasMensagens) > 0 {
+			resposta.WriteString(fmt.Sprintf(" e mais %d mensagem(ns)", len(outrasMensagens)))
+		}
+		if s.JaVinculada {
+			resposta.WriteString(" (já vinculada a este registro)")
+		}
+		resposta.WriteString("\n")
+	}
+}
+
+// escreverCabecalhoOcorrencia identifica uma ocorrência da tag no XML, com a posição entre os irmãos e os atributos
+func escreverCabecalhoOcorrencia(resposta *strings.Builder, ocorrencia util.TagOccurrence, indice int, total int) {
+	resposta.WriteString(fmt.Sprintf("=== Ocorrência %d de %d: %s (posição %d entre irmãos",
+		indice+1, total, strings.Join(ocorrencia.Path, " > "), ocorrencia.SiblingIndex))
+
+	if len(ocorrencia.Attributes) > 0 {
+		nomes := make([]string, 0, len(ocorrencia.Attributes))
+		for nome := range ocorrencia.Attributes {
+			nomes = append(nomes, nome)
+		}
+		sort.Strings(nomes)
+
+		atributos := make([]string, 0, len(nomes))
+		for _, nome := range nomes {
+			atributos = append(atributos, fmt.Sprintf("%s=\"%s\"", nome, ocorrencia.Attributes[nome]))
+		}
+		resposta.WriteString("; atributos: " + strings.Join(atributos, " "))
+	}
+
+	resposta.WriteString(") ===\n")
+}
@@ -1,7 +1,6 @@
 package esptag
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 
@@ -10,14 +9,16 @@ import (
 
 // ConsultaEspecializacaoArgs define os argumentos de entrada para o MCP
 type ConsultaEspecializacaoArgs struct {
-	Termo string `json:"termo" jsonschema:"description=Termo para buscar especializações (busca parcial)"`
-	ID    int    `json:"id" jsonschema:"description=ID da especialização para busca direta"`
+	Termo  string `json:"termo" jsonschema:"description=Termo para buscar especializações. A busca ignora acentos, maiúsculas e a ordem das palavras, tolera erros de digitação e aceita abreviações ISO 20022 (ex: TxSts)"`
+	ID     int    `json:"id" jsonschema:"description=ID da especialização para busca direta"`
+	Limite int    `json:"limite" jsonschema:"description=Quantidade máxima de especializações por página (padrão 20)"`
+	Pagina int    `json:"pagina" jsonschema:"description=Página de resultados, a partir de 1 (padrão 1)"`
 }
 
 // RegisterConsultaEspecializacao registra o MCP de consulta de especialização
-func RegisterConsultaEspecializacao(server *mcp_golang.Server, db *sql.DB) error {
+func RegisterConsultaEspecializacao(server *mcp_golang.Server, cat Catalog) error {
 	return server.RegisterTool("sq_pix_esptag_consulta_especializacao",
-		"Consulta especializações de tag que correspondem a um termo de busca ou ID específico",
+		"Consulta especializações de tag que correspondem a um termo de busca, ordenadas por relevância, ou a um ID específico",
 		func(args ConsultaEspecializacaoArgs) (*mcp_golang.ToolResponse, error) {
 
 			var resultado strings.Builder
@@ -25,7 +26,7 @@ func RegisterConsultaEspecializacao(server *mcp_golang.Server, db *sql.DB) error
 			// Verifica se foi fornecido um ID
 			if args.ID > 0 {
 				// Consulta por ID
-				esp, err := ConsultaEspecializacaoPorID(db, args.ID)
+				esp, err := cat.ConsultaEspecializacaoPorID(args.ID)
 				if err != nil {
 					return nil, fmt.Errorf("erro ao consultar especialização por ID: %v", err)
 				}
@@ -42,20 +43,28 @@ func RegisterConsultaEspecializacao(server *mcp_golang.Server, db *sql.DB) error
 					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: É necessário fornecer um termo de busca ou um ID válido.")), nil
 				}
 
-				// Consulta as especializações por termo
-				especializacoes, err := ConsultaEspecializacao(db, args.Termo)
+				// Busca as especializações por termo, ordenadas por relevância
+				busca, err := BuscarEspecializacoes(cat, args.Termo, OpcoesBuscaEspecializacao{Limite: args.Limite, Pagina: args.Pagina})
 				if err != nil {
 					return nil, fmt.Errorf("erro ao consultar especializações: %v", err)
 				}
 
 				// Formata o resultado como texto
-				if len(especializacoes) == 0 {
+				if busca.Total == 0 {
 					resultado.WriteString(fmt.Sprintf("Nenhuma especialização encontrada para o termo '%s'.", args.Termo))
+				} else if len(busca.Especializacoes) == 0 {
+					resultado.WriteString(fmt.Sprintf("A página %d não existe: foram encontradas %d especializações para o termo '%s', em %d página(s).",
+						busca.Pagina, busca.Total, args.Termo, busca.TotalPaginas))
 				} else {
-					resultado.WriteString(fmt.Sprintf("Encontradas %d especializações para o termo '%s':\n\n", len(especializacoes), args.Termo))
+					resultado.WriteString(fmt.Sprintf("Encontradas %d especializações para o termo '%s' (página %d de %d), ordenadas por relevância:\n\n",
+						busca.Total, args.Termo, busca.Pagina, busca.TotalPaginas))
 
-					for i, esp := range especializacoes {
-						resultado.WriteString(fmt.Sprintf("%d. ID: %d - Descrição: %s\n", i+1, esp.ID, esp.Descricao))
+					for i, esp := range busca.Especializacoes {
+						resultado.WriteString(fmt.Sprintf("%d. ID: %d - Descrição: %s (relevância %d%%)\n", busca.Inicio+i+1, esp.ID, esp.Descricao, esp.Relevancia))
+					}
+
+					if busca.Pagina < busca.TotalPaginas {
+						resultado.WriteString(fmt.Sprintf("\nHá mais resultados: use pagina=%d para ver os próximos.\n", busca.Pagina+1))
 					}
 
 					resultado.WriteString("\nUtilize o ID da especialização desejada para criar a vinculação.")
@@ -0,0 +1,67 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterConsultaVinculosEspecializacao registra o MCP de consulta dos vínculos de uma especialização
+func RegisterConsultaVinculosEspecializacao(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_consulta_vinculos_especializacao",
+		"Lista todas as tags de mensagens vinculadas a uma especialização, com o caminho completo, agrupadas por mensagem",
+		func(args ConsultaVinculosEspecializacaoArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEspecializacao <= 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID da especialização deve ser maior que zero")), nil
+			}
+
+			esp, err := cat.ConsultaEspecializacaoPorID(args.IDEspecializacao)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar especialização: %v", err)
+			}
+
+			vinculos, err := cat.ListarVinculosEspecializacao(args.IDEspecializacao)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar vínculos da especialização: %v", err)
+			}
+
+			grupos, err := AgruparVinculosPorMensagem(cat, vinculos)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar tags das mensagens: %v", err)
+			}
+
+			var resultado strings.Builder
+
+			if esp == nil {
+				resultado.WriteString(fmt.Sprintf("Aviso: Especialização com ID %d não foi encontrada na base.\n", args.IDEspecializacao))
+			} else {
+				resultado.WriteString(fmt.Sprintf("Especialização: ID %d - %s\n", esp.ID, esp.Descricao))
+			}
+
+			if len(vinculos) == 0 {
+				resultado.WriteString("\nNenhum vínculo em spi_especializacao_msg_tag referencia esta especialização.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			resultado.WriteString(fmt.Sprintf("Encontrados %d vínculos em %d mensagens:\n", len(vinculos), len(grupos)))
+
+			for _, grupo := range grupos {
+				resultado.WriteString(fmt.Sprintf("\nMensagem %s (%s) - %d tag(s):\n", grupo.IDEveMensagem, grupo.IDTipMensagem, len(grupo.Tags)))
+
+				for _, tag := range grupo.Tags {
+					if tag.Caminho == "" {
+						resultado.WriteString(fmt.Sprintf("- %s (tag não encontrada em spi_mensagem_tag)\n", tag.IDTag))
+					} else {
+						resultado.WriteString(fmt.Sprintf("- %s\n", tag.Caminho))
+					}
+					resultado.WriteString(fmt.Sprintf("  id_tag: %s | id_tag_pai: %s | num_seq_tag: %d | num_seq_msg_tag: %d\n",
+						tag.IDTag, tag.IDTagPai, tag.NumSeqTag, tag.NumSeqMsgTag))
+				}
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
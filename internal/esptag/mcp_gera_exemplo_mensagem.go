@@ -0,0 +1,33 @@
+package esptag
+
+import (
+	"fmt"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterGeraExemploMensagem registra o MCP de geração do XML de exemplo de uma mensagem
+func RegisterGeraExemploMensagem(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_gera_exemplo_mensagem",
+		"Gera um XML de exemplo de uma mensagem a partir de spi_mensagem_tag, com o namespace da mensagem, valores de exemplo e as tags especializadas marcadas; o XML pode ser usado como caminho_xml",
+		func(args GeraExemploMensagemArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: O ID do evento da mensagem é obrigatório")), nil
+			}
+
+			exemplo, err := GerarExemploMensagem(cat, args.IDEveMensagem, args.ApenasEspecializadas)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao gerar exemplo da mensagem: %v", err)
+			}
+			if exemplo.QtdTags == 0 {
+				if args.ApenasEspecializadas {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("A mensagem '%s' não possui tags com especializações.", args.IDEveMensagem))), nil
+				}
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: A mensagem '%s' não possui tags cadastradas em spi_mensagem_tag.", args.IDEveMensagem))), nil
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(exemplo.XML)), nil
+		})
+}
@@ -0,0 +1,65 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterGeraScriptDesvinculacao registra o MCP de geração de script para remoção de vínculo
+func RegisterGeraScriptDesvinculacao(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_gera_script_desvinculacao",
+		"Gera script SQL para remover o vínculo de uma especialização com uma tag de mensagem",
+		func(args GeraScriptDesvinculacaoArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEspecializacao <= 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID da especialização deve ser maior que zero")), nil
+			}
+
+			if args.IDEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem não pode ser vazio")), nil
+			}
+
+			if args.IDTag == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID da tag não pode ser vazio")), nil
+			}
+
+			// Consulta os vínculos que seriam removidos
+			afetados, err := BuscarVinculosAfetados(cat, args)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar vínculos: %v", err)
+			}
+
+			var resultado strings.Builder
+
+			if len(afetados) == 0 {
+				resultado.WriteString("Aviso: Nenhum vínculo correspondente foi encontrado na base. ")
+				resultado.WriteString("O script será gerado, mas não removerá registros se executado no estado atual.\n\n")
+			} else {
+				resultado.WriteString(fmt.Sprintf("Registros de spi_especializacao_msg_tag que serão removidos (%d):\n", len(afetados)))
+				escreverVinculosAfetados(&resultado, cat, afetados)
+				resultado.WriteString("\n")
+			}
+
+			// Gera o script SQL e o rollback, que recria o vínculo
+			resultado.WriteString(GeraScriptDesvinculacao(args))
+			escreverScriptRollback(&resultado, GeraScriptVinculacao(args.vinculacao()))
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
+
+// escreverVinculosAfetados lista os vínculos com o caminho reconstruído de cada tag
+func escreverVinculosAfetados(resultado *strings.Builder, cat Catalog, vinculos []EspecializacaoMsgTag) {
+	for _, v := range vinculos {
+		caminho, err := ReconstruirCaminho(cat, MensagemTagInfo{IDEveMensagem: v.IDEveMensagem, IDTag: v.IDTag, NumSeqMsgTag: v.NumSeqMsgTag})
+		caminhoTexto := strings.Join(caminho, " > ")
+		if err != nil {
+			caminhoTexto = v.IDTag
+		}
+		resultado.WriteString(fmt.Sprintf("- id_esp_tag %d | %s | %s (num_seq_tag %d, num_seq_msg_tag %d)\n",
+			v.IDEspecializacao, v.IDEveMensagem, caminhoTexto, v.NumSeqTag, v.NumSeqMsgTag))
+	}
+}
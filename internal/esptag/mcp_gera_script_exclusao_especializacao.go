@@ -0,0 +1,64 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterGeraScriptExclusaoEspecializacao registra o MCP de geração de script para exclusão de especialização
+func RegisterGeraScriptExclusaoEspecializacao(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_gera_script_exclusao_especializacao",
+		"Gera script SQL para excluir uma especialização de tag, recusando ou removendo em cascata os vínculos existentes",
+		func(args GeraScriptExclusaoEspecializacaoArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEspecializacao <= 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID da especialização deve ser maior que zero")), nil
+			}
+
+			esp, err := cat.ConsultaEspecializacaoPorID(args.IDEspecializacao)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar especialização: %v", err)
+			}
+			if esp == nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Especialização com ID %d não foi encontrada na base.", args.IDEspecializacao))), nil
+			}
+
+			// Consulta os vínculos que referenciam a especialização
+			vinculos, err := cat.ListarVinculosEspecializacao(args.IDEspecializacao)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar vínculos da especialização: %v", err)
+			}
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Especialização: ID %d - %s\n", esp.ID, esp.Descricao))
+
+			if len(vinculos) > 0 {
+				resultado.WriteString(fmt.Sprintf("\nVínculos em spi_especializacao_msg_tag que referenciam esta especialização (%d):\n", len(vinculos)))
+				escreverVinculosAfetados(&resultado, cat, vinculos)
+
+				if !args.Cascata {
+					resultado.WriteString("\nErro: A especialização ainda está vinculada a tags de mensagens. Nenhum script foi gerado.\n")
+					resultado.WriteString("Remova os vínculos com sq_pix_esptag_gera_script_desvinculacao ou informe cascata=true para removê-los junto com a especialização.\n")
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+				}
+
+				resultado.WriteString("\nAtenção: Os vínculos acima serão removidos em cascata.\n")
+			} else {
+				resultado.WriteString("Nenhum vínculo em spi_especializacao_msg_tag referencia esta especialização.\n")
+			}
+
+			resultado.WriteString("\n")
+			resultado.WriteString(GeraScriptExclusaoEspecializacao(*esp, vinculos))
+
+			rollback, err := GeraScriptRollbackExclusaoEspecializacao(cat, *esp, vinculos)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao gerar rollback: %v", err)
+			}
+			escreverScriptRollback(&resultado, rollback)
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
@@ -0,0 +1,120 @@
+package esptag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterGeraScriptMigracaoVersao registra o MCP de migração de vínculos entre versões de uma mensagem
+func RegisterGeraScriptMigracaoVersao(server *mcp_golang.Server, cat Catalog, changesets *Changesets) error {
+	return server.RegisterTool("sq_pix_esptag_gera_script_migracao_versao",
+		"Gera script SQL que recria os vínculos de especializações de uma versão de mensagem em uma nova versão, mapeando as tags pelo caminho",
+		func(ctx context.Context, args GeraScriptMigracaoVersaoArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
+
+			// Validação de entrada
+			if args.IDEveMensagemOrigem == "" || args.IDEveMensagemDestino == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: Os IDs do evento da mensagem de origem e de destino são obrigatórios")), nil
+			}
+			if args.IDEveMensagemOrigem == args.IDEveMensagemDestino {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: As mensagens de origem e de destino devem ser diferentes")), nil
+			}
+
+			for _, id := range []string{args.IDEveMensagemOrigem, args.IDEveMensagemDestino} {
+				arvore, err := cat.ObterArvoreMensagem(id)
+				if err != nil {
+					return nil, fmt.Errorf("erro ao consultar estrutura da mensagem: %v", err)
+				}
+				if len(arvore.Tags()) == 0 {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: A mensagem '%s' não possui tags cadastradas em spi_mensagem_tag.", id))), nil
+				}
+			}
+
+			migracao, err := MapearMigracaoVersao(cat, args.IDEveMensagemOrigem, args.IDEveMensagemDestino)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao mapear vínculos: %v", err)
+			}
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Migração de vínculos: %s -> %s\n", args.IDEveMensagemOrigem, args.IDEveMensagemDestino))
+			if len(migracao.Mapeamentos) == 0 {
+				resultado.WriteString(fmt.Sprintf("\nA mensagem '%s' não possui vínculos em spi_especializacao_msg_tag. Nenhum script foi gerado.", args.IDEveMensagemOrigem))
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			movidos := migracao.Filtrar(MigracaoMovida)
+			existentes := migracao.Filtrar(MigracaoExistente)
+			naoMapeados := migracao.Filtrar(MigracaoNaoMapeada)
+			resultado.WriteString(fmt.Sprintf("Vínculos na origem: %d | mesmo caminho: %d | tags movidas: %d | já existentes no destino: %d | não mapeados: %d\n",
+				len(migracao.Mapeamentos), len(migracao.Filtrar(MigracaoMapeada)), len(movidos), len(existentes), len(naoMapeados)))
+
+			if len(movidos) > 0 {
+				resultado.WriteString("\nTags movidas (revise antes de executar o script):\n")
+				for _, m := range movidos {
+					resultado.WriteString(fmt.Sprintf("- id_esp_tag %d | %s (num_seq_msg_tag %d) -> %s (num_seq_msg_tag %d)\n",
+						m.Vinculo.IDEspecializacao, strings.Join(m.CaminhoOrigem, " > "), m.Vinculo.NumSeqMsgTag,
+						strings.Join(m.CaminhoDestino, " > "), m.Destino.NumSeqMsgTag))
+				}
+			}
+
+			if len(naoMapeados) > 0 {
+				resultado.WriteString("\nVínculos não mapeados (não incluídos no script):\n")
+				for _, m := range naoMapeados {
+					resultado.WriteString(fmt.Sprintf("- id_esp_tag %d | %s (num_seq_msg_tag %d): ",
+						m.Vinculo.IDEspecializacao, strings.Join(m.CaminhoOrigem, " > "), m.Vinculo.NumSeqMsgTag))
+					if len(m.Candidatos) == 0 {
+						resultado.WriteString(fmt.Sprintf("tag '%s' não existe na nova versão\n", m.Vinculo.IDTag))
+						continue
+					}
+					candidatos := make([]string, len(m.Candidatos))
+					for i, c := range m.Candidatos {
+						candidatos[i] = fmt.Sprintf("%d", c.NumSeqMsgTag)
+					}
+					motivo := "mapeamento ambíguo"
+					if len(m.Candidatos) == 1 {
+						motivo = "tag encontrada apenas sob outro pai"
+					}
+					resultado.WriteString(fmt.Sprintf("%s, candidatos com num_seq_msg_tag %s; use sq_pix_esptag_gera_script_vinculacao para o registro correto\n",
+						motivo, strings.Join(candidatos, ", ")))
+				}
+			}
+
+			if len(existentes) > 0 {
+				resultado.WriteString("\nVínculos que já existem na nova versão:\n")
+				for _, m := range existentes {
+					resultado.WriteString(fmt.Sprintf("- id_esp_tag %d | %s (num_seq_msg_tag %d)\n",
+						m.Vinculo.IDEspecializacao, strings.Join(m.CaminhoDestino, " > "), m.Destino.NumSeqMsgTag))
+				}
+			}
+
+			migrar := migracao.AMigrar()
+			if len(migrar) == 0 {
+				resultado.WriteString("\nNenhum vínculo a migrar. Nenhum script foi gerado.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			resultado.WriteString("\n")
+			resultado.WriteString(GeraScriptMigracaoVersao(migracao))
+			escreverScriptRollback(&resultado, GeraScriptRollbackMigracaoVersao(migracao))
+
+			if args.AdicionarChangeset {
+				var itens []string
+				for _, m := range migrar {
+					item, err := cs.AdicionarVinculacao(m.Vinculacao())
+					if err != nil {
+						escreverAdicaoChangeset(&resultado, item, err)
+						continue
+					}
+					itens = append(itens, fmt.Sprintf("%d", item.ID))
+				}
+				if len(itens) > 0 {
+					resultado.WriteString(fmt.Sprintf("\nVinculações adicionadas ao changeset como itens %s.\n", strings.Join(itens, ", ")))
+				}
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
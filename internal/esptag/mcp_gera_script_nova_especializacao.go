@@ -1,7 +1,7 @@
 package esptag
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,39 +9,50 @@ import (
 )
 
 // RegisterGeraScriptNovaEspecializacao registra o MCP de geração de script para nova especialização
-func RegisterGeraScriptNovaEspecializacao(server *mcp_golang.Server, db *sql.DB) error {
+func RegisterGeraScriptNovaEspecializacao(server *mcp_golang.Server, cat Catalog, changesets *Changesets) error {
 	return server.RegisterTool("sq_pix_esptag_gera_script_nova_especializacao",
-		"Gera script SQL para criar uma nova especialização de tag",
-		func(args GeraScriptNovaEspecializacaoArgs) (*mcp_golang.ToolResponse, error) {
+		"Gera script SQL para criar uma nova especialização de tag, bloqueando descrições que dupliquem especializações existentes",
+		func(ctx context.Context, args GeraScriptNovaEspecializacaoArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
 
 			// Validação de entrada
 			if args.Descricao == "" {
 				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: Descrição não pode ser vazia")), nil
 			}
 
-			// Consulta para verificar se a especialização já existe
-			esps, err := ConsultaEspecializacao(db, args.Descricao) // Assume ConsultaEspecializacao exists in this package
+			// Verifica se a especialização já existe, na base ou no changeset
+			duplicatas, err := DetectarDuplicatas(cat, args.Descricao, args.IDTag, cs.EspecializacoesPendentes())
 			if err != nil {
 				return nil, fmt.Errorf("erro ao consultar especialização: %v", err)
 			}
 
 			// Prepara a resposta
 			var resultado strings.Builder
+
+			// Duplicatas fortes bloqueiam a geração do script, a menos que o usuário force
+			if PossuiDuplicataForte(duplicatas) && !args.Forcar {
+				resultado.WriteString(fmt.Sprintf("Erro: A especialização '%s' duplica especialização(ões) existente(s):\n\n", args.Descricao))
+				escreverDuplicatas(&resultado, duplicatas)
+				resultado.WriteString("\nUtilize o ID da especialização existente para criar a vinculação. ")
+				resultado.WriteString("Se for realmente uma nova especialização, gere o script novamente com forcar=true.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
 			var idParaUsar int
 
 			// Verifica se o usuário forneceu um ID
 			if args.ID != nil {
-				idExiste, err := VerificarIDExistente(db, *args.ID)
+				espExistente, err := cat.ConsultaEspecializacaoPorID(*args.ID)
 				if err != nil {
 					return nil, fmt.Errorf("erro ao verificar ID existente: %v", err)
 				}
 
-				if idExiste {
+				if espExistente != nil || cs.EspecializacaoPendente(*args.ID) != nil {
 					// ID já está em uso, sugerir um novo
-					proximoID, err := ObterProximoID(db)
+					proximoID, err := cat.ObterProximoID()
 					if err != nil {
 						return nil, fmt.Errorf("erro ao obter próximo ID: %v", err)
 					}
+					proximoID = cs.ProximoIDEspecializacao(proximoID)
 
 					resultado.WriteString(fmt.Sprintf("Atenção: O ID %d já está em uso. Sugerimos usar o ID %d.\n\n", *args.ID, proximoID))
 					idParaUsar = proximoID
@@ -51,28 +62,37 @@ func RegisterGeraScriptNovaEspecializacao(server *mcp_golang.Server, db *sql.DB)
 				}
 			} else {
 				// Usuário não forneceu ID, obter o próximo disponível
-				proximoID, err := ObterProximoID(db)
+				proximoID, err := cat.ObterProximoID()
 				if err != nil {
 					return nil, fmt.Errorf("erro ao obter próximo ID: %v", err)
 				}
 
-				idParaUsar = proximoID
+				// Considera também as especializações pendentes no changeset
+				idParaUsar = cs.ProximoIDEspecializacao(proximoID)
 			}
 
 			// Verifica se existem especializações com descrição similar
-			if len(esps) > 0 {
-				resultado.WriteString(fmt.Sprintf("Atenção: Encontradas %d especializações similares. Verifique se a especialização já existe:\n\n", len(esps)))
-
-				for i, e := range esps {
-					resultado.WriteString(fmt.Sprintf("%d. ID: %d - Descrição: %s\n", i+1, e.ID, e.Descricao))
+			if len(duplicatas) > 0 {
+				if PossuiDuplicataForte(duplicatas) {
+					resultado.WriteString("Atenção: Geração forçada (forcar=true) apesar de duplicidade com especializações existentes:\n\n")
+					escreverDuplicatas(&resultado, duplicatas)
+					resultado.WriteString("\nSegue o script para criar a nova especialização:\n\n")
+				} else {
+					resultado.WriteString(fmt.Sprintf("Atenção: Encontradas %d especializações similares. Verifique se a especialização já existe:\n\n", len(duplicatas)))
+					escreverDuplicatas(&resultado, duplicatas)
+					resultado.WriteString("\nCaso deseje prosseguir mesmo assim, segue o script para criar a nova especialização:\n\n")
 				}
-
-				resultado.WriteString("\nCaso deseje prosseguir mesmo assim, segue o script para criar a nova especialização:\n\n")
 			}
 
 			// Gera o script SQL com o ID determinado
 			script := GeraScriptNovaEspecializacao(args.Descricao, idParaUsar)
 			resultado.WriteString(script)
+			escreverScriptRollback(&resultado, GeraScriptRollbackNovaEspecializacao(args.Descricao, idParaUsar))
+
+			if args.AdicionarChangeset {
+				item, err := cs.AdicionarEspecializacao(args.Descricao, idParaUsar)
+				escreverAdicaoChangeset(&resultado, item, err)
+			}
 
 			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
 		})
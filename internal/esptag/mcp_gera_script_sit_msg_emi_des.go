@@ -1,7 +1,7 @@
 package esptag
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,10 +9,11 @@ import (
 )
 
 // RegisterGeraScriptSitMsgEmiDes registers the MCP tool for generating spi_sit_msg_emi_des insert script
-func RegisterGeraScriptSitMsgEmiDes(server *mcp_golang.Server, db *sql.DB) error {
+func RegisterGeraScriptSitMsgEmiDes(server *mcp_golang.Server, cat Catalog, changesets *Changesets) error {
 	return server.RegisterTool("sq_pix_esptag_gera_script_sit_msg_emi_des",
 		"Gera script SQL para inserir um novo registro na tabela spi_sit_msg_emi_des (Situação Mensagem Emissor Destinatario), verificando se já existe",
-		func(args GeraScriptSitMsgEmiDesArgs) (*mcp_golang.ToolResponse, error) {
+		func(ctx context.Context, args GeraScriptSitMsgEmiDesArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
 
 			// --- Input Validation ---
 			if args.IDSitMsgEmiDes == "" {
@@ -29,7 +30,7 @@ func RegisterGeraScriptSitMsgEmiDes(server *mcp_golang.Server, db *sql.DB) error
 			}
 
 			// --- Check if record already exists ---
-			existe, err := VerificarSitMsgEmiDesExistente(db, args.IDSitMsgEmiDes, args.IDTipEmiDes, args.IDSitMsg)
+			existe, err := cat.VerificarSitMsgEmiDesExistente(args.IDSitMsgEmiDes, args.IDTipEmiDes, args.IDSitMsg)
 			if err != nil {
 				// Return internal error if DB check fails
 				return nil, fmt.Errorf("erro ao verificar existência do registro: %v", err)
@@ -44,6 +45,13 @@ func RegisterGeraScriptSitMsgEmiDes(server *mcp_golang.Server, db *sql.DB) error
 				// --- Generate the script ---
 				script := GeraScriptSitMsgEmiDes(args)
 				resultado.WriteString(script)
+				escreverScriptRollback(&resultado, GeraScriptRollbackSitMsgEmiDes(args))
+
+				// --- Optionally add it to the changeset ---
+				if args.AdicionarChangeset {
+					item, err := cs.AdicionarSitMsgEmiDes(args)
+					escreverAdicaoChangeset(&resultado, item, err)
+				}
 			}
 
 			// --- Return the result ---
@@ -1,7 +1,7 @@
 package esptag
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,10 +9,11 @@ import (
 )
 
 // RegisterGeraScriptVinculacao registra o MCP de geração de script para vinculação
-func RegisterGeraScriptVinculacao(server *mcp_golang.Server, db *sql.DB) error {
+func RegisterGeraScriptVinculacao(server *mcp_golang.Server, cat Catalog, changesets *Changesets) error {
 	return server.RegisterTool("sq_pix_esptag_gera_script_vinculacao",
 		"Gera script SQL para vincular uma especialização a uma mensagem",
-		func(args GeraScriptVinculacaoArgs) (*mcp_golang.ToolResponse, error) {
+		func(ctx context.Context, args GeraScriptVinculacaoArgs) (*mcp_golang.ToolResponse, error) {
+			cs := changesets.Obter(ctx)
 
 			// Validação de entrada
 			if args.IDEspecializacao <= 0 {
@@ -28,14 +29,27 @@ func RegisterGeraScriptVinculacao(server *mcp_golang.Server, db *sql.DB) error {
 			}
 
 			// Verifica se a especialização existe
-			especialiacaoExiste, err := VerificarEspecializacaoExiste(db, args.IDEspecializacao) // Assume VerificarEspecializacaoExiste exists
+			especializacao, err := cat.ConsultaEspecializacaoPorID(args.IDEspecializacao)
 			if err != nil {
 				return nil, fmt.Errorf("erro ao verificar especialização: %v", err)
 			}
 
+			// Verifica se o vínculo já existe
+			existe, err := VerificarVinculoExistente(cat, args)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao verificar vínculos da mensagem: %v", err)
+			}
+
 			var resultado strings.Builder
 
-			if !especialiacaoExiste {
+			if existe {
+				resultado.WriteString(fmt.Sprintf("Aviso: A especialização %d já está vinculada à tag '%s' (num_seq_msg_tag %d) da mensagem '%s'.\n",
+					args.IDEspecializacao, args.IDTag, args.NumSeqMsgTag, args.IDEveMensagem))
+				resultado.WriteString("Nenhum script de vinculação será gerado.\n")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			if especializacao == nil && cs.EspecializacaoPendente(args.IDEspecializacao) == nil {
 				resultado.WriteString(fmt.Sprintf("Aviso: Especialização com ID %d não foi encontrada na base. ", args.IDEspecializacao))
 				resultado.WriteString("O script será gerado, mas certifique-se de que a especialização exista antes de executá-lo.\n\n")
 			}
@@ -43,6 +57,12 @@ func RegisterGeraScriptVinculacao(server *mcp_golang.Server, db *sql.DB) error {
 			// Gera o script SQL
 			script := GeraScriptVinculacao(args) // Assume GeraScriptVinculacao exists
 			resultado.WriteString(script)
+			escreverScriptRollback(&resultado, GeraScriptRollbackVinculacao(args))
+
+			if args.AdicionarChangeset {
+				item, err := cs.AdicionarVinculacao(args)
+				escreverAdicaoChangeset(&resultado, item, err)
+			}
 
 			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
 		})
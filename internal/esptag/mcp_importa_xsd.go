@@ -0,0 +1,84 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterImportaXSD registra o MCP de importação da estrutura de uma mensagem a partir do XSD
+func RegisterImportaXSD(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_importa_xsd",
+		"Lê o XSD de uma mensagem ISO 20022, expande os tipos complexos na árvore de elementos e gera o script SQL que cadastra a mensagem em spi_mensagem_tag",
+		func(args ImportaXSDArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.ArquivoXSD == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: O caminho do arquivo XSD é obrigatório")), nil
+			}
+
+			schema, raiz, avisos, err := carregarXSD(args.ArquivoXSD, args.ElementoRaiz)
+			if err != nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Falha ao carregar o XSD: %v", err))), nil
+			}
+
+			if args.IDEveMensagem == "" {
+				args.IDEveMensagem = schema.MessageID()
+				if args.IDEveMensagem == "" {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: O targetNamespace do XSD ('%s') não identifica a mensagem. Informe o id_eve_msg.", schema.TargetNamespace))), nil
+				}
+			}
+			if args.IDTipMensagem == "" {
+				args.IDTipMensagem, err = IDTipMensagemPadrao(cat, args.IDEveMensagem)
+				if err != nil {
+					return nil, fmt.Errorf("erro ao consultar mensagens: %v", err)
+				}
+			}
+
+			imp, err := MontarImportacaoXSD(cat, raiz, args.IDEveMensagem, args.IDTipMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao montar estrutura da mensagem: %v", err)
+			}
+			imp.Avisos = avisos
+
+			// Uma mensagem já cadastrada só é substituída a pedido, e nunca se possuir vínculos
+			if len(imp.Substituidas) > 0 {
+				if !args.Substituir {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+						"Erro: A mensagem '%s' já possui %d tags cadastradas em spi_mensagem_tag. Use substituir=true para gerar o script que substitui a estrutura atual.",
+						args.IDEveMensagem, len(imp.Substituidas)))), nil
+				}
+				vinculos, err := cat.ListarVinculosMensagem(args.IDEveMensagem)
+				if err != nil {
+					return nil, fmt.Errorf("erro ao consultar vínculos da mensagem: %v", err)
+				}
+				if len(vinculos) > 0 {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+						"Erro: A mensagem '%s' possui %d vínculos em spi_especializacao_msg_tag e não pode ser substituída. Importe o XSD como uma nova versão e use sq_pix_esptag_gera_script_migracao_versao.",
+						args.IDEveMensagem, len(vinculos)))), nil
+				}
+			}
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Importação do XSD: %s\n", args.ArquivoXSD))
+			resultado.WriteString(fmt.Sprintf("Mensagem: %s (id_tip_msg %s) | namespace: %s\n", imp.IDEveMensagem, imp.IDTipMensagem, schema.TargetNamespace))
+			resultado.WriteString(fmt.Sprintf("Tags: %d | níveis: %d | num_seq_msg_tag: %d a %d\n",
+				len(imp.Tags), imp.Profundidade, imp.PrimeiroNumSeqMsgTag(), imp.UltimoNumSeqMsgTag()))
+			if len(imp.Substituidas) > 0 {
+				resultado.WriteString(fmt.Sprintf("Substitui os %d registros atuais da mensagem.\n", len(imp.Substituidas)))
+			}
+			if len(imp.Avisos) > 0 {
+				resultado.WriteString("\nAvisos:\n")
+				for _, aviso := range imp.Avisos {
+					resultado.WriteString(fmt.Sprintf("- %s\n", aviso))
+				}
+			}
+
+			resultado.WriteString("\n")
+			resultado.WriteString(GeraScriptImportacaoXSD(imp, args.ArquivoXSD))
+			escreverScriptRollback(&resultado, GeraScriptRollbackImportacaoXSD(imp))
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
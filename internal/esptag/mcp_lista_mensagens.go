@@ -0,0 +1,43 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterListaMensagens registra o MCP de listagem das mensagens cadastradas
+func RegisterListaMensagens(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_lista_mensagens",
+		"Lista as mensagens cadastradas em spi_mensagem_tag (id_eve_msg e id_tip_msg) com a quantidade de tags de cada uma",
+		func(args ListaMensagensArgs) (*mcp_golang.ToolResponse, error) {
+
+			mensagens, err := cat.ListarMensagens()
+			if err != nil {
+				return nil, fmt.Errorf("erro ao listar mensagens: %v", err)
+			}
+
+			mensagens = FiltrarMensagens(mensagens, args.Filtro)
+
+			var resultado strings.Builder
+
+			if len(mensagens) == 0 {
+				if args.Filtro != "" {
+					resultado.WriteString(fmt.Sprintf("Nenhuma mensagem encontrada para o filtro '%s'.", args.Filtro))
+				} else {
+					resultado.WriteString("Nenhuma mensagem cadastrada em spi_mensagem_tag.")
+				}
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			resultado.WriteString(fmt.Sprintf("Encontradas %d mensagens:\n\n", len(mensagens)))
+			for i, m := range mensagens {
+				resultado.WriteString(fmt.Sprintf("%d. id_eve_msg: %s | id_tip_msg: %s | tags: %d\n", i+1, m.IDEveMensagem, m.IDTipMensagem, m.QtdTags))
+			}
+
+			resultado.WriteString("\nUtilize sq_pix_esptag_navega_mensagem com o id_eve_msg desejado para percorrer a estrutura de tags.")
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
@@ -0,0 +1,77 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterNavegaMensagem registra o MCP de navegação na árvore de tags de uma mensagem
+func RegisterNavegaMensagem(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_navega_mensagem",
+		"Lista os filhos de uma tag de uma mensagem em spi_mensagem_tag, ou as tags raiz, para navegar pela estrutura da mensagem",
+		func(args NavegaMensagemArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: ID do evento da mensagem não pode ser vazio")), nil
+			}
+
+			arvore, err := cat.ObterArvoreMensagem(args.IDEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar tags da mensagem: %v", err)
+			}
+
+			if len(arvore.Tags()) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Nenhuma tag encontrada em spi_mensagem_tag para a mensagem '%s'. Utilize sq_pix_esptag_lista_mensagens para ver as mensagens disponíveis.", args.IDEveMensagem))), nil
+			}
+
+			var resultado strings.Builder
+
+			// Sem tag indicada, lista as raízes da mensagem
+			if args.NumSeqMsgTag <= 0 && args.IDTag == "" {
+				resultado.WriteString(fmt.Sprintf("Tags raiz da mensagem %s:\n", args.IDEveMensagem))
+				escreverTagsNavegacao(&resultado, arvore, arvore.Raizes())
+				resultado.WriteString("\nInforme o num_seq_msg_tag de uma tag para listar seus filhos.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			tags := BuscarTagsNavegacao(arvore, args)
+
+			switch {
+			case len(tags) == 0:
+				if args.NumSeqMsgTag > 0 {
+					resultado.WriteString(fmt.Sprintf("Erro: Nenhuma tag com num_seq_msg_tag %d na mensagem %s.", args.NumSeqMsgTag, args.IDEveMensagem))
+				} else {
+					resultado.WriteString(fmt.Sprintf("Erro: Tag '%s' não encontrada na mensagem %s.", args.IDTag, args.IDEveMensagem))
+				}
+
+			case len(tags) > 1:
+				resultado.WriteString(fmt.Sprintf("A tag '%s' aparece %d vezes na mensagem %s:\n", args.IDTag, len(tags), args.IDEveMensagem))
+				for _, t := range tags {
+					resultado.WriteString(fmt.Sprintf("- %s (num_seq_tag %d, num_seq_msg_tag %d)\n",
+						strings.Join(arvore.Caminho(t.NumSeqMsgTag), " > "), t.NumSeqTag, t.NumSeqMsgTag))
+				}
+				resultado.WriteString("\nInforme o num_seq_msg_tag desejado para listar seus filhos.")
+
+			default:
+				tag := tags[0]
+				resultado.WriteString(fmt.Sprintf("Tag: %s (num_seq_tag %d, num_seq_msg_tag %d)\n",
+					strings.Join(arvore.Caminho(tag.NumSeqMsgTag), " > "), tag.NumSeqTag, tag.NumSeqMsgTag))
+				if pai, ok := arvore.Pai(tag.NumSeqMsgTag); ok {
+					resultado.WriteString(fmt.Sprintf("Pai: %s (num_seq_msg_tag %d)\n", pai.IDTag, pai.NumSeqMsgTag))
+				}
+
+				filhos := arvore.Filhos(tag.NumSeqMsgTag)
+				if len(filhos) == 0 {
+					resultado.WriteString("\nEsta tag não possui filhos.")
+				} else {
+					resultado.WriteString(fmt.Sprintf("\nFilhos (%d):\n", len(filhos)))
+					escreverTagsNavegacao(&resultado, arvore, filhos)
+				}
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
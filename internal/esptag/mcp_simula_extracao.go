@@ -0,0 +1,152 @@
+package esptag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterSimulaExtracao registra o MCP de simulação da extração dos valores de cada especialização de uma mensagem
+func RegisterSimulaExtracao(server *mcp_golang.Server, cat Catalog, scorer Scorer, changesets *Changesets) error {
+	return server.RegisterTool("sq_pix_esptag_simula_extracao",
+		"Simula os valores que cada especialização (id_esp_tag) vinculada à mensagem extrairia de um XML, apontando ocorrências repetidas, incertas e tags ausentes (obrigatórias ou opcionais, se o XSD for informado), opcionalmente com as vinculações pendentes no changeset",
+		func(ctx context.Context, args SimulaExtracaoArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if strings.TrimSpace(args.XMLMensagem) == "" || args.IDEveMensagem == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: A mensagem XML e o ID do evento da mensagem são obrigatórios")), nil
+			}
+			if _, err := util.FindAllOccurrences(args.XMLMensagem); err != nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro ao processar a mensagem XML: %v", err))), nil
+			}
+
+			arvore, err := cat.ObterArvoreMensagem(args.IDEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar estrutura da mensagem: %v", err)
+			}
+			if len(arvore.Tags()) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+					"Erro: A mensagem '%s' não possui tags cadastradas em spi_mensagem_tag. Use sq_pix_esptag_lista_mensagens para ver as mensagens disponíveis.", args.IDEveMensagem))), nil
+			}
+
+			var vinculosPendentes []EspecializacaoMsgTag
+			var especializacoesPendentes []EspecializacaoTag
+			if args.IncluirChangeset {
+				changeset := changesets.Obter(ctx)
+				vinculosPendentes = changeset.VinculacoesPendentes(args.IDEveMensagem)
+				especializacoesPendentes = changeset.EspecializacoesPendentes()
+			}
+
+			// XSD opcional, usado para classificar as tags ausentes
+			var raiz *util.XSDNode
+			var avisosXSD []string
+			if args.ArquivoXSD != "" {
+				var schema *util.XSDSchema
+				schema, raiz, avisosXSD, err = carregarXSD(args.ArquivoXSD, args.ElementoRaiz)
+				if err != nil {
+					return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Falha ao carregar o XSD: %v", err))), nil
+				}
+				if id := schema.MessageID(); id != "" && id != args.IDEveMensagem {
+					avisosXSD = append(avisosXSD, fmt.Sprintf("o XSD é da mensagem '%s', diferente da mensagem simulada '%s'", id, args.IDEveMensagem))
+				}
+			}
+
+			simulacao, err := SimularExtracao(cat, scorer, args.XMLMensagem, args.IDEveMensagem, vinculosPendentes, especializacoesPendentes, raiz)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao simular extração: %v", err)
+			}
+
+			var resultado strings.Builder
+			_, _, avisos := IdentificarMensagem(args.XMLMensagem, args.IDEveMensagem)
+			avisos = append(avisos, avisosXSD...)
+			for _, aviso := range avisos {
+				resultado.WriteString(fmt.Sprintf("Aviso: %s\n", aviso))
+			}
+			for _, aviso := range simulacao.Avisos {
+				resultado.WriteString(fmt.Sprintf("Aviso: %s\n", aviso))
+			}
+
+			if len(simulacao.Extracoes) == 0 {
+				resultado.WriteString(fmt.Sprintf("Nenhuma especialização vinculada às tags de %s", args.IDEveMensagem))
+				if !args.IncluirChangeset {
+					resultado.WriteString(". Use incluir_changeset=true para considerar as vinculações pendentes no changeset")
+				}
+				resultado.WriteString(".")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			pendentes := 0
+			for _, e := range simulacao.Extracoes {
+				if e.Pendente {
+					pendentes++
+				}
+			}
+			comValor, ausentes, vazias, incertas, repetidas := simulacao.Contar()
+			resultado.WriteString(fmt.Sprintf("Simulação da extração de %s: %d vínculo(s)", args.IDEveMensagem, len(simulacao.Extracoes)))
+			if args.IncluirChangeset {
+				resultado.WriteString(fmt.Sprintf(", %d pendente(s) no changeset", pendentes))
+			}
+			resultado.WriteString(fmt.Sprintf("\nCom valor: %d | ausentes: %d | vazios: %d | apenas com correspondência incerta: %d | com mais de uma ocorrência: %d\n",
+				comValor, ausentes, vazias, incertas, repetidas))
+			if raiz != nil {
+				resultado.WriteString(fmt.Sprintf("Ausentes pelo XSD: %d obrigatória(s) | %d opcional(is) | %d fora do XSD\n",
+					simulacao.ContarAusencias(AusenciaObrigatoria), simulacao.ContarAusencias(AusenciaOpcional), simulacao.ContarAusencias(AusenciaForaDoXSD)))
+			}
+
+			for _, e := range simulacao.Extracoes {
+				resultado.WriteString(fmt.Sprintf("\nid_esp_tag %d - %s", e.Especializacao.ID, e.Especializacao.Descricao))
+				if e.Pendente {
+					resultado.WriteString(" [pendente no changeset]")
+				}
+				resultado.WriteString(fmt.Sprintf("\n  %s (num_seq_msg_tag %d)\n", strings.Join(e.Caminho, " > "), e.Vinculo.NumSeqMsgTag))
+
+				if e.Ausente() {
+					rotulo := "AUSENTE"
+					switch e.Obrigatoriedade {
+					case AusenciaObrigatoria:
+						rotulo = "AUSENTE (obrigatória no XSD)"
+					case AusenciaOpcional:
+						rotulo = "AUSENTE (opcional no XSD)"
+					case AusenciaForaDoXSD:
+						rotulo = "AUSENTE (caminho não encontrado no XSD)"
+					}
+					if e.AncestralPresente != "" {
+						resultado.WriteString(fmt.Sprintf("  %s: a tag não aparece no XML, embora o grupo %s esteja presente\n", rotulo, e.AncestralPresente))
+					} else {
+						resultado.WriteString(fmt.Sprintf("  %s: nem a tag nem os seus ancestrais aparecem no XML\n", rotulo))
+					}
+					continue
+				}
+				if len(e.Valores) > 1 {
+					resultado.WriteString(fmt.Sprintf("  %d ocorrências no XML:\n", len(e.Valores)))
+				}
+				for i, v := range e.Valores {
+					valor := fmt.Sprintf("%q", v.Valor)
+					switch {
+					case v.PossuiFilhos && v.Valor == "":
+						valor = "(grupo, sem valor textual)"
+					case v.Valor == "":
+						valor = "(vazio)"
+					}
+					resultado.WriteString(fmt.Sprintf("  - %s", valor))
+					if len(e.Valores) > 1 {
+						resultado.WriteString(fmt.Sprintf(" [ocorrência %d: %s]", i+1, strings.Join(v.Caminho, " > ")))
+					}
+					resultado.WriteString("\n")
+				}
+				// Ocorrências incertas ficam fora dos valores extraídos
+				if len(e.Incertos) > 0 {
+					resultado.WriteString(fmt.Sprintf("  %d ocorrência(s) com correspondência incerta com o registro, não consideradas:\n", len(e.Incertos)))
+					for _, v := range e.Incertos {
+						resultado.WriteString(fmt.Sprintf("  - %q [%s]\n", v.Valor, strings.Join(v.Caminho, " > ")))
+					}
+				}
+			}
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
@@ -0,0 +1,120 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+
+	mcp_golang "github.com/metoro-io/mcp-golang"
+)
+
+// RegisterValidaMensagemXSD registra o MCP de validação da estrutura de uma mensagem com o XSD
+func RegisterValidaMensagemXSD(server *mcp_golang.Server, cat Catalog) error {
+	return server.RegisterTool("sq_pix_esptag_valida_mensagem_xsd",
+		"Compara a estrutura de uma mensagem cadastrada em spi_mensagem_tag com a árvore de elementos do XSD oficial, apontando tags ausentes, excedentes, pais incorretos e diferenças de ordem",
+		func(args ValidaMensagemXSDArgs) (*mcp_golang.ToolResponse, error) {
+
+			// Validação de entrada
+			if args.IDEveMensagem == "" || args.ArquivoXSD == "" {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent("Erro: O ID do evento da mensagem e o caminho do arquivo XSD são obrigatórios")), nil
+			}
+
+			arvore, err := cat.ObterArvoreMensagem(args.IDEveMensagem)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao consultar estrutura da mensagem: %v", err)
+			}
+			if len(arvore.Tags()) == 0 {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf(
+					"Erro: A mensagem '%s' não possui tags cadastradas em spi_mensagem_tag. Use sq_pix_esptag_importa_xsd para cadastrá-la.", args.IDEveMensagem))), nil
+			}
+
+			schema, raiz, avisos, err := carregarXSD(args.ArquivoXSD, args.ElementoRaiz)
+			if err != nil {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(fmt.Sprintf("Erro: Falha ao carregar o XSD: %v", err))), nil
+			}
+			if id := schema.MessageID(); id != "" && id != args.IDEveMensagem {
+				avisos = append(avisos, fmt.Sprintf("o XSD é da mensagem '%s', diferente da mensagem validada '%s'", id, args.IDEveMensagem))
+			}
+
+			validacao, err := ValidarMensagemXSD(cat, args.IDEveMensagem, raiz)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao validar estrutura da mensagem: %v", err)
+			}
+
+			pais := validacao.Filtrar(DivergenciaPaiIncorreto)
+			ausentes := validacao.Filtrar(DivergenciaAusente)
+			excedentes := validacao.Filtrar(DivergenciaExcedente)
+			ordem := validacao.Filtrar(DivergenciaOrdem)
+
+			var resultado strings.Builder
+			resultado.WriteString(fmt.Sprintf("Validação de %s com o XSD: %s\n", args.IDEveMensagem, args.ArquivoXSD))
+			resultado.WriteString(fmt.Sprintf("Registros cadastrados: %d | elementos no XSD: %d | no mesmo caminho: %d\n",
+				len(arvore.Tags()), validacao.Conferidas+len(pais)+len(ausentes), validacao.Conferidas))
+			resultado.WriteString(fmt.Sprintf("Pais incorretos: %d | tags ausentes: %d | tags excedentes: %d | fora de ordem: %d\n",
+				len(pais), len(ausentes), len(excedentes), len(ordem)))
+
+			if len(avisos) > 0 {
+				resultado.WriteString("\nAvisos:\n")
+				for _, aviso := range avisos {
+					resultado.WriteString(fmt.Sprintf("- %s\n", aviso))
+				}
+			}
+
+			if len(validacao.Divergencias) == 0 {
+				resultado.WriteString("\nA estrutura cadastrada está de acordo com o XSD.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			if len(pais) > 0 {
+				resultado.WriteString("\nPais incorretos:\n")
+				for _, d := range pais {
+					resultado.WriteString(fmt.Sprintf("- %s (num_seq_msg_tag %d): id_tag_pai '%s', esperado '%s' em %s\n",
+						strings.Join(d.Caminho, " > "), d.Tag.NumSeqMsgTag, d.Tag.IDTagPai, d.IDTagPaiEsperado, strings.Join(d.CaminhoEsperado, " > ")))
+				}
+			}
+
+			if len(ausentes) > 0 {
+				resultado.WriteString("\nTags ausentes:\n")
+				for _, d := range ausentes {
+					resultado.WriteString(fmt.Sprintf("- %s\n", strings.Join(d.Caminho, " > ")))
+				}
+			}
+
+			if len(excedentes) > 0 {
+				resultado.WriteString("\nTags excedentes:\n")
+				for _, d := range excedentes {
+					resultado.WriteString(fmt.Sprintf("- %s (num_seq_msg_tag %d)", strings.Join(d.Caminho, " > "), d.Tag.NumSeqMsgTag))
+					if d.Vinculada {
+						resultado.WriteString(" [possui vínculos]")
+					}
+					resultado.WriteString("\n")
+				}
+			}
+
+			if len(ordem) > 0 {
+				resultado.WriteString("\nFora da ordem do XSD:\n")
+				for _, d := range ordem {
+					resultado.WriteString(fmt.Sprintf("- %s (num_seq_tag %d, num_seq_msg_tag %d)\n",
+						strings.Join(d.CaminhoEsperado, " > "), d.Tag.NumSeqTag, d.Tag.NumSeqMsgTag))
+				}
+			}
+
+			if !args.GerarScript {
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			removidas := 0
+			if args.RemoverExcedentes {
+				removidas = len(validacao.RemoviveisExcedentes())
+			}
+			if len(pais)+len(ausentes)+removidas == 0 {
+				resultado.WriteString("\nNenhuma correção a aplicar por script: diferenças de ordem exigem revisão manual, e tags excedentes só são removidas com remover_excedentes quando não possuem vínculos.")
+				return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+			}
+
+			resultado.WriteString("\n")
+			resultado.WriteString(GeraScriptCorrecaoXSD(validacao, args.RemoverExcedentes))
+			escreverScriptRollback(&resultado, GeraScriptRollbackCorrecaoXSD(validacao, args.RemoverExcedentes))
+
+			return mcp_golang.NewToolResponse(mcp_golang.NewTextContent(resultado.String())), nil
+		})
+}
@@ -0,0 +1,236 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GeraScriptMigracaoVersaoArgs define os argumentos de entrada para o MCP
+type GeraScriptMigracaoVersaoArgs struct {
+	IDEveMensagemOrigem  string `json:"id_eve_msg_origem" jsonschema:"required,description=ID do evento da versão atual da mensagem, cujos vínculos serão migrados (ex: pacs.008.001.08)"`
+	IDEveMensagemDestino string `json:"id_eve_msg_destino" jsonschema:"required,description=ID do evento da nova versão da mensagem, já cadastrada em spi_mensagem_tag (ex: pacs.008.001.10)"`
+
+	AdicionarChangeset bool `json:"adicionar_changeset,omitempty" jsonschema:"description=Se verdadeiro, adiciona as vinculações geradas ao changeset do servidor"`
+}
+
+// SituacaoMigracao classifica o mapeamento de um vínculo da versão de origem para a de destino
+type SituacaoMigracao string
+
+const (
+	MigracaoMapeada    SituacaoMigracao = "mapeada"     // Tag encontrada no mesmo caminho da nova versão
+	MigracaoMovida     SituacaoMigracao = "movida"      // Tag encontrada em outro caminho da nova versão
+	MigracaoExistente  SituacaoMigracao = "existente"   // O vínculo já existe na nova versão ou é criado por outro mapeamento
+	MigracaoNaoMapeada SituacaoMigracao = "nao_mapeada" // Tag ausente, ambígua ou sem o mesmo pai na nova versão
+)
+
+// niveisMinimosMigracao é a quantidade de níveis de caminho coincidentes, a partir da tag, exigida para tratar
+// como movida uma tag localizada pelo nome: ao menos a própria tag e o seu pai
+const niveisMinimosMigracao = 2
+
+// MapeamentoVinculo relaciona um vínculo da versão de origem ao registro correspondente na versão de destino
+type MapeamentoVinculo struct {
+	Vinculo        EspecializacaoMsgTag
+	CaminhoOrigem  []string
+	Situacao       SituacaoMigracao
+	Destino        MensagemTagInfo   // Registro da nova versão (exceto para não mapeados)
+	CaminhoDestino []string          // Caminho do registro da nova versão
+	Candidatos     []MensagemTagInfo // Registros mais prováveis, quando o mapeamento é ambíguo ou não coincide no pai
+}
+
+// Vinculacao retorna os argumentos de vinculação da especialização ao registro da nova versão
+func (m MapeamentoVinculo) Vinculacao() GeraScriptVinculacaoArgs {
+	return GeraScriptVinculacaoArgs{
+		IDEspecializacao: m.Vinculo.IDEspecializacao,
+		IDEveMensagem:    m.Destino.IDEveMensagem,
+		IDTag:            m.Destino.IDTag,
+		IDTagPai:         m.Destino.IDTagPai,
+		NumSeqTag:        m.Destino.NumSeqTag,
+		NumSeqMsgTag:     m.Destino.NumSeqMsgTag,
+	}
+}
+
+// MigracaoVersao é o resultado do mapeamento dos vínculos entre duas versões de uma mensagem
+type MigracaoVersao struct {
+	IDEveMensagemOrigem  string
+	IDEveMensagemDestino string
+	Mapeamentos          []MapeamentoVinculo
+}
+
+// Filtrar retorna os mapeamentos na situação informada
+func (m *MigracaoVersao) Filtrar(situacao SituacaoMigracao) []MapeamentoVinculo {
+	var filtrados []MapeamentoVinculo
+	for _, mapeamento := range m.Mapeamentos {
+		if mapeamento.Situacao == situacao {
+			filtrados = append(filtrados, mapeamento)
+		}
+	}
+	return filtrados
+}
+
+// AMigrar retorna os mapeamentos que geram vinculações na nova versão (mapeados e movidos)
+func (m *MigracaoVersao) AMigrar() []MapeamentoVinculo {
+	var migrar []MapeamentoVinculo
+	for _, mapeamento := range m.Mapeamentos {
+		if mapeamento.Situacao == MigracaoMapeada || mapeamento.Situacao == MigracaoMovida {
+			migrar = append(migrar, mapeamento)
+		}
+	}
+	return migrar
+}
+
+// MapearMigracaoVersao mapeia cada vínculo da versão de origem para um registro da versão de destino pelo
+// caminho reconstruído da tag. Caminhos repetidos são pareados pela ordem de ocorrência; tags que mudaram
+// de lugar são localizadas pelo nome, preferindo o caminho com mais níveis coincidentes a partir da tag, e só
+// são migradas quando um único registro coincide ao menos até o pai.
+func MapearMigracaoVersao(cat Catalog, idEveMensagemOrigem, idEveMensagemDestino string) (*MigracaoVersao, error) {
+	arvoreOrigem, err := cat.ObterArvoreMensagem(idEveMensagemOrigem)
+	if err != nil {
+		return nil, err
+	}
+	arvoreDestino, err := cat.ObterArvoreMensagem(idEveMensagemDestino)
+	if err != nil {
+		return nil, err
+	}
+
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagemOrigem)
+	if err != nil {
+		return nil, err
+	}
+	vinculosDestino, err := cat.ListarVinculosMensagem(idEveMensagemDestino)
+	if err != nil {
+		return nil, err
+	}
+	existentes := make(map[[2]int]bool)
+	for _, v := range vinculosDestino {
+		existentes[[2]int{v.IDEspecializacao, v.NumSeqMsgTag}] = true
+	}
+
+	ordemOrigem := ordemPorCaminho(arvoreOrigem)
+	destinoPorCaminho := make(map[string][]MensagemTagInfo)
+	for _, tag := range arvoreDestino.Tags() {
+		chave := strings.Join(arvoreDestino.Caminho(tag.NumSeqMsgTag), "/")
+		destinoPorCaminho[chave] = append(destinoPorCaminho[chave], tag)
+	}
+
+	migracao := &MigracaoVersao{IDEveMensagemOrigem: idEveMensagemOrigem, IDEveMensagemDestino: idEveMensagemDestino}
+	for _, v := range vinculos {
+		m := MapeamentoVinculo{Vinculo: v, CaminhoOrigem: arvoreOrigem.Caminho(v.NumSeqMsgTag)}
+
+		// Mesmo caminho na nova versão, na mesma ordem entre os caminhos repetidos
+		mesmoCaminho := destinoPorCaminho[strings.Join(m.CaminhoOrigem, "/")]
+		if ordem := ordemOrigem[v.NumSeqMsgTag]; ordem < len(mesmoCaminho) {
+			m.Situacao = MigracaoMapeada
+			m.Destino = mesmoCaminho[ordem]
+		} else {
+			// A tag mudou de lugar: procura pelo nome o caminho mais parecido
+			candidatos, niveis := melhoresCandidatosMigracao(arvoreDestino, m.CaminhoOrigem, v.IDTag)
+			if len(candidatos) == 1 && niveis >= niveisMinimosMigracao {
+				m.Situacao = MigracaoMovida
+				m.Destino = candidatos[0]
+			} else {
+				m.Situacao = MigracaoNaoMapeada
+				m.Candidatos = candidatos
+			}
+		}
+
+		if m.Situacao != MigracaoNaoMapeada {
+			m.CaminhoDestino = arvoreDestino.Caminho(m.Destino.NumSeqMsgTag)
+			// Vínculos já cadastrados na nova versão, ou já criados por outro mapeamento, não são migrados
+			chave := [2]int{v.IDEspecializacao, m.Destino.NumSeqMsgTag}
+			if existentes[chave] {
+				m.Situacao = MigracaoExistente
+			}
+			existentes[chave] = true
+		}
+
+		migracao.Mapeamentos = append(migracao.Mapeamentos, m)
+	}
+
+	return migracao, nil
+}
+
+// ordemPorCaminho retorna, para cada registro, a sua ordem entre os registros com o mesmo caminho
+func ordemPorCaminho(arvore *ArvoreMensagem) map[int]int {
+	contagem := make(map[string]int)
+	ordem := make(map[int]int)
+	for _, tag := range arvore.Tags() {
+		chave := strings.Join(arvore.Caminho(tag.NumSeqMsgTag), "/")
+		ordem[tag.NumSeqMsgTag] = contagem[chave]
+		contagem[chave]++
+	}
+	return ordem
+}
+
+// melhoresCandidatosMigracao retorna os registros da tag na nova versão com mais níveis de caminho
+// coincidentes com o caminho de origem, contados a partir da tag em direção à raiz, e essa quantidade de níveis
+func melhoresCandidatosMigracao(arvore *ArvoreMensagem, caminhoOrigem []string, idTag string) ([]MensagemTagInfo, int) {
+	var melhores []MensagemTagInfo
+	melhorNivel := 0
+	for _, tag := range arvore.BuscarTag(idTag) {
+		caminho := arvore.Caminho(tag.NumSeqMsgTag)
+		niveis := 0
+		for niveis < len(caminho) && niveis < len(caminhoOrigem) &&
+			caminho[len(caminho)-1-niveis] == caminhoOrigem[len(caminhoOrigem)-1-niveis] {
+			niveis++
+		}
+
+		switch {
+		case niveis > melhorNivel:
+			melhores, melhorNivel = []MensagemTagInfo{tag}, niveis
+		case niveis == melhorNivel && niveis > 0:
+			melhores = append(melhores, tag)
+		}
+	}
+	return melhores, melhorNivel
+}
+
+// GeraScriptMigracaoVersao gera, em uma única transação, as vinculações dos mapeamentos a migrar.
+// Cada vinculação é protegida por IF NOT EXISTS, como em GeraScriptVinculacao.
+func GeraScriptMigracaoVersao(migracao *MigracaoVersao) string {
+	script := strings.Builder{}
+	migrar := migracao.AMigrar()
+
+	script.WriteString("-- Script para migrar vínculos de especializações para a nova versão da mensagem\n")
+	script.WriteString(fmt.Sprintf("-- Origem: %s\n", migracao.IDEveMensagemOrigem))
+	script.WriteString(fmt.Sprintf("-- Destino: %s\n", migracao.IDEveMensagemDestino))
+	script.WriteString(fmt.Sprintf("-- Vinculações: %d (%d em tags movidas)\n", len(migrar), len(migracao.Filtrar(MigracaoMovida))))
+	for _, m := range migracao.Filtrar(MigracaoNaoMapeada) {
+		script.WriteString(fmt.Sprintf("-- Não migrado: especialização %d em %s (num_seq_msg_tag %d)\n",
+			m.Vinculo.IDEspecializacao, strings.Join(m.CaminhoOrigem, " > "), m.Vinculo.NumSeqMsgTag))
+	}
+	script.WriteString("\n")
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	for _, m := range migrar {
+		if m.Situacao == MigracaoMovida {
+			script.WriteString(fmt.Sprintf("-- Tag movida: %s -> %s\n", strings.Join(m.CaminhoOrigem, " > "), strings.Join(m.CaminhoDestino, " > ")))
+		}
+		script.WriteString(GeraScriptVinculacao(m.Vinculacao()))
+		script.WriteString("\n\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// GeraScriptRollbackMigracaoVersao gera o script SQL que remove, em uma única transação,
+// as vinculações criadas por GeraScriptMigracaoVersao. Os vínculos que já existiam na nova versão
+// (situação existente) não são removidos.
+func GeraScriptRollbackMigracaoVersao(migracao *MigracaoVersao) string {
+	script := strings.Builder{}
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	for _, m := range migracao.AMigrar() {
+		script.WriteString(GeraScriptRollbackVinculacao(m.Vinculacao()))
+		script.WriteString("\n\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
@@ -0,0 +1,119 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+)
+
+// novoCatalogoMigracao cria a pacs.002.001.09 com a estrutura completa e a pacs.002.001.10 com
+// OrgnlGrpInfAndSts movido para o GrpHdr e sem o Cd do motivo, restando apenas um Cd sob outro pai
+func novoCatalogoMigracao() *MemoryCatalog {
+	var tags []MensagemTagInfo
+	for _, t := range tagsPacs002() {
+		t.IDEveMensagem = "pacs.002.001.09"
+		t.NumSeqMsgTag += 900
+		tags = append(tags, t)
+	}
+	for _, t := range tagsPacs002() {
+		switch t.IDTag {
+		case "OrgnlGrpInfAndSts":
+			t.IDTagPai = "GrpHdr"
+		case "Cd":
+			continue
+		}
+		tags = append(tags, t)
+	}
+	tags = append(tags, MensagemTagInfo{IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "Cd", IDTagPai: "OrgnlGrpInfAndSts", NumSeqTag: 24, NumSeqMsgTag: 124})
+
+	return NewMemoryCatalog(DadosCatalogo{
+		MensagemTags: tags,
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 9, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "MsgId", NumSeqTag: 4, NumSeqMsgTag: 1004},
+			{IDEspecializacao: 5, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "OrgnlMsgId", NumSeqTag: 7, NumSeqMsgTag: 1007},
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "TxSts", NumSeqTag: 11, NumSeqMsgTag: 1011},
+			{IDEspecializacao: 7, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "Cd", NumSeqTag: 14, NumSeqMsgTag: 1014},
+			{IDEspecializacao: 8, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "Id", NumSeqTag: 23, NumSeqMsgTag: 1023},
+			{IDEspecializacao: 9, IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "MsgId", NumSeqTag: 4, NumSeqMsgTag: 104},
+		},
+	})
+}
+
+func TestMapearMigracaoVersao(t *testing.T) {
+	cat := novoCatalogoMigracao()
+
+	migracao, err := MapearMigracaoVersao(cat, "pacs.002.001.09", "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("MapearMigracaoVersao() error = %v", err)
+	}
+
+	esperado := map[int]struct {
+		situacao SituacaoMigracao
+		destino  int
+	}{
+		9: {MigracaoExistente, 104},
+		5: {MigracaoMovida, 107},
+		3: {MigracaoMapeada, 111},
+		7: {MigracaoNaoMapeada, 0},
+		8: {MigracaoMapeada, 123},
+	}
+	if len(migracao.Mapeamentos) != len(esperado) {
+		t.Fatalf("MapearMigracaoVersao() returned %d mapeamentos, want %d", len(migracao.Mapeamentos), len(esperado))
+	}
+	for _, m := range migracao.Mapeamentos {
+		want := esperado[m.Vinculo.IDEspecializacao]
+		if m.Situacao != want.situacao || m.Destino.NumSeqMsgTag != want.destino {
+			t.Errorf("especialização %d: situação %s, destino %d, want %s, %d",
+				m.Vinculo.IDEspecializacao, m.Situacao, m.Destino.NumSeqMsgTag, want.situacao, want.destino)
+		}
+	}
+
+	script := GeraScriptMigracaoVersao(migracao)
+	if strings.Count(script, "INSERT INTO spi_especializacao_msg_tag") != 3 {
+		t.Errorf("GeraScriptMigracaoVersao() deveria gerar 3 vinculações:\n%s", script)
+	}
+	if !strings.Contains(script, "-- Tag movida: Document > FIToFIPmtStsRpt > OrgnlGrpInfAndSts > OrgnlMsgId -> Document > FIToFIPmtStsRpt > GrpHdr > OrgnlGrpInfAndSts > OrgnlMsgId") {
+		t.Errorf("GeraScriptMigracaoVersao() sem o aviso de tag movida:\n%s", script)
+	}
+	if !strings.Contains(script, "-- Não migrado: especialização 7") || !strings.Contains(script, "BEGIN TRANSACTION") {
+		t.Errorf("GeraScriptMigracaoVersao() sem o vínculo não migrado ou sem transação:\n%s", script)
+	}
+	if n := strings.Count(GeraScriptRollbackMigracaoVersao(migracao), "DELETE em"); n != 3 {
+		t.Errorf("GeraScriptRollbackMigracaoVersao() gerou %d remoções, want 3", n)
+	}
+
+	// O único Cd restante está sob outro pai: não é migrado, mas é apresentado como candidato
+	for _, m := range migracao.Filtrar(MigracaoNaoMapeada) {
+		if len(m.Candidatos) != 1 || m.Candidatos[0].NumSeqMsgTag != 124 {
+			t.Errorf("Candidatos de %d = %+v, want apenas o Cd 124", m.Vinculo.NumSeqMsgTag, m.Candidatos)
+		}
+	}
+	if strings.Contains(script, "AND num_seq_msg_tag = 124") {
+		t.Errorf("GeraScriptMigracaoVersao() não deveria vincular o Cd sob outro pai:\n%s", script)
+	}
+}
+
+func TestMapearMigracaoVersaoDestinoRepetido(t *testing.T) {
+	cat := novoCatalogoMigracao()
+	dados := cat.dados
+	dados.MensagemTags = append(dados.MensagemTags, MensagemTagInfo{IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "OrgnlMsgId", IDTagPai: "OrgnlGrpInfAndSts", NumSeqTag: 8, NumSeqMsgTag: 1030})
+	dados.Vinculos = append(dados.Vinculos, EspecializacaoMsgTag{IDEspecializacao: 5, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "OrgnlMsgId", NumSeqTag: 8, NumSeqMsgTag: 1030})
+
+	migracao, err := MapearMigracaoVersao(NewMemoryCatalog(dados), "pacs.002.001.09", "pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("MapearMigracaoVersao() error = %v", err)
+	}
+
+	// Os dois OrgnlMsgId vão para o mesmo registro: apenas o primeiro gera a vinculação e o seu rollback
+	var situacoes []SituacaoMigracao
+	for _, m := range migracao.Mapeamentos {
+		if m.Vinculo.IDEspecializacao == 5 {
+			situacoes = append(situacoes, m.Situacao)
+		}
+	}
+	if len(situacoes) != 2 || situacoes[0] != MigracaoMovida || situacoes[1] != MigracaoExistente {
+		t.Errorf("situações da especialização 5 = %v, want [movida existente]", situacoes)
+	}
+	if n := strings.Count(GeraScriptRollbackMigracaoVersao(migracao), "AND em.id_esp_tag = 5"); n != 2 {
+		t.Errorf("GeraScriptRollbackMigracaoVersao() referencia a especialização 5 em %d cláusulas, want 2 (uma remoção)", n)
+	}
+}
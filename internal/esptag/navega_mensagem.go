@@ -0,0 +1,32 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NavegaMensagemArgs define os argumentos de entrada para o MCP
+type NavegaMensagemArgs struct {
+	IDEveMensagem string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pacs.008.001.10)"`
+	NumSeqMsgTag  int    `json:"num_seq_msg_tag" jsonschema:"description=num_seq_msg_tag da tag cujos filhos serão listados. Se omitido junto com id_tag, lista as tags raiz"`
+	IDTag         string `json:"id_tag" jsonschema:"description=ID da tag cujos filhos serão listados, usado quando num_seq_msg_tag não é informado"`
+}
+
+// BuscarTagsNavegacao retorna as tags indicadas por num_seq_msg_tag ou, na sua falta, por id_tag
+func BuscarTagsNavegacao(arvore *ArvoreMensagem, args NavegaMensagemArgs) []MensagemTagInfo {
+	if args.NumSeqMsgTag > 0 {
+		if tag, ok := arvore.Tag(args.NumSeqMsgTag); ok {
+			return []MensagemTagInfo{tag}
+		}
+		return nil
+	}
+	return arvore.BuscarTag(args.IDTag)
+}
+
+// escreverTagsNavegacao lista as tags com a quantidade de filhos de cada uma
+func escreverTagsNavegacao(resultado *strings.Builder, arvore *ArvoreMensagem, tags []MensagemTagInfo) {
+	for _, t := range tags {
+		resultado.WriteString(fmt.Sprintf("- %s (num_seq_tag %d, num_seq_msg_tag %d) - %d filho(s)\n",
+			t.IDTag, t.NumSeqTag, t.NumSeqMsgTag, len(arvore.Filhos(t.NumSeqMsgTag))))
+	}
+}
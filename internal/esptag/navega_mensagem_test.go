@@ -0,0 +1,42 @@
+package esptag
+
+import "testing"
+
+func TestListarMensagensEFiltro(t *testing.T) {
+	cat := novoCatalogoTeste()
+
+	mensagens, err := cat.ListarMensagens()
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if len(mensagens) != 2 || mensagens[0].IDEveMensagem != "pacs.002.001.10" || mensagens[0].QtdTags != 23 || mensagens[1].QtdTags != 1 {
+		t.Fatalf("mensagens inesperadas: %+v", mensagens)
+	}
+
+	if filtradas := FiltrarMensagens(mensagens, "PACS.008"); len(filtradas) != 1 || filtradas[0].IDTipMensagem != "pacs.008" {
+		t.Errorf("filtro inesperado: %+v", filtradas)
+	}
+}
+
+func TestBuscarTagsNavegacao(t *testing.T) {
+	arvore, err := novoCatalogoTeste().ObterArvoreMensagem("pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+
+	if tags := BuscarTagsNavegacao(arvore, NavegaMensagemArgs{IDTag: "Id"}); len(tags) != 4 {
+		t.Errorf("esperadas 4 ocorrências de Id, obtidas %d", len(tags))
+	}
+
+	tags := BuscarTagsNavegacao(arvore, NavegaMensagemArgs{IDTag: "Id", NumSeqMsgTag: 115})
+	if len(tags) != 1 || tags[0].IDTag != "OrgnlTxRef" {
+		t.Fatalf("num_seq_msg_tag deveria ter prioridade sobre id_tag: %+v", tags)
+	}
+	if filhos := arvore.Filhos(115); len(filhos) != 2 || filhos[1].IDTag != "CdtrAcct" {
+		t.Errorf("filhos inesperados: %+v", filhos)
+	}
+
+	if tags := BuscarTagsNavegacao(arvore, NavegaMensagemArgs{NumSeqMsgTag: 999}); len(tags) != 0 {
+		t.Errorf("num_seq_msg_tag inexistente não deveria retornar tags: %+v", tags)
+	}
+}
@@ -0,0 +1,75 @@
+package esptag
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NumeracaoNumSeqTag atribui o num_seq_tag de novos registros de spi_mensagem_tag, com a mesma convenção na
+// importação e na validação de XSD. O registro recebe o num_seq_tag do registro no mesmo caminho e ocorrência
+// da versão de referência, a mais recente já cadastrada da família da mensagem; sem correspondente, recebe o
+// num_seq_tag do registro anterior na ordem do documento mais um. Sem versão de referência, a numeração é
+// portanto de 1 a N.
+type NumeracaoNumSeqTag struct {
+	Referencia string // id_eve_msg da versão de referência; vazio se a família não possui outra versão
+
+	numSeqTags map[string]int // caminho#ocorrência -> num_seq_tag na versão de referência
+	contagem   map[string]int
+	anterior   int
+}
+
+// NovaNumeracaoNumSeqTag carrega a versão de referência da família de idEveMensagem, sem considerar a própria mensagem
+func NovaNumeracaoNumSeqTag(cat Catalog, idEveMensagem string) (*NumeracaoNumSeqTag, error) {
+	numeracao := &NumeracaoNumSeqTag{numSeqTags: make(map[string]int), contagem: make(map[string]int)}
+
+	mensagens, err := cat.ListarMensagens()
+	if err != nil {
+		return nil, err
+	}
+	familia := familiaMensagem(idEveMensagem)
+	for _, m := range mensagens {
+		if m.IDEveMensagem != idEveMensagem && familiaMensagem(m.IDEveMensagem) == familia && m.IDEveMensagem > numeracao.Referencia {
+			numeracao.Referencia = m.IDEveMensagem
+		}
+	}
+	if numeracao.Referencia == "" {
+		return numeracao, nil
+	}
+
+	arvore, err := cat.ObterArvoreMensagem(numeracao.Referencia)
+	if err != nil {
+		return nil, err
+	}
+	contagem := make(map[string]int)
+	for _, tag := range arvore.Tags() {
+		chave := chaveCaminhoNumeracao(arvore.Caminho(tag.NumSeqMsgTag))
+		numeracao.numSeqTags[fmt.Sprintf("%s#%d", chave, contagem[chave])] = tag.NumSeqTag
+		contagem[chave]++
+	}
+	return numeracao, nil
+}
+
+// Registrar informa, na ordem do documento, um registro já cadastrado que mantém o seu num_seq_tag
+func (n *NumeracaoNumSeqTag) Registrar(caminho []string, numSeqTag int) {
+	n.contagem[chaveCaminhoNumeracao(caminho)]++
+	n.anterior = numSeqTag
+}
+
+// Numerar retorna, na ordem do documento, o num_seq_tag de um novo registro no caminho informado
+func (n *NumeracaoNumSeqTag) Numerar(caminho []string) int {
+	chave := chaveCaminhoNumeracao(caminho)
+	numSeqTag, ok := n.numSeqTags[fmt.Sprintf("%s#%d", chave, n.contagem[chave])]
+	if !ok {
+		numSeqTag = n.anterior + 1
+	}
+	n.Registrar(caminho, numSeqTag)
+	return numSeqTag
+}
+
+// chaveCaminhoNumeracao compara caminhos com e sem o elemento raiz (Document), como nas bases cadastradas sem ele
+func chaveCaminhoNumeracao(caminho []string) string {
+	if len(caminho) > 0 && caminho[0] == ElementoRaizPadrao {
+		caminho = caminho[1:]
+	}
+	return strings.Join(caminho, "/")
+}
@@ -0,0 +1,48 @@
+package esptag
+
+import "testing"
+
+// TestNumeracaoNumSeqTagImportacaoEValidacao confere que a importação e a validação de XSD numeram a mesma tag
+// ausente com o num_seq_tag da versão de referência da família
+func TestNumeracaoNumSeqTagImportacaoEValidacao(t *testing.T) {
+	tags := tagsPacs002()
+	for _, tag := range tagsPacs002() {
+		tag.IDEveMensagem = "pacs.002.001.09"
+		tag.NumSeqMsgTag += 900
+		tags = append(tags, tag)
+	}
+	// Na referência, Prtry compartilha o num_seq_tag da alternativa Cd
+	tags = append(tags, MensagemTagInfo{IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "Prtry", IDTagPai: "Rsn", NumSeqTag: 14, NumSeqMsgTag: 1024})
+	cat := NewMemoryCatalog(DadosCatalogo{MensagemTags: tags})
+
+	validacao, err := ValidarMensagemXSD(cat, "pacs.002.001.10", expandirXSDTeste(t))
+	if err != nil {
+		t.Fatalf("ValidarMensagemXSD() error = %v", err)
+	}
+	ausentes := validacao.Filtrar(DivergenciaAusente)
+	if len(ausentes) != 1 || ausentes[0].Tag.IDTag != "Prtry" || ausentes[0].Tag.NumSeqTag != 14 {
+		t.Errorf("ausentes = %+v, want Prtry com num_seq_tag 14", ausentes)
+	}
+
+	imp, err := MontarImportacaoXSD(cat, expandirXSDTeste(t), "pacs.002.001.12", "pacs.002")
+	if err != nil {
+		t.Fatalf("MontarImportacaoXSD() error = %v", err)
+	}
+	if imp.Referencia != "pacs.002.001.10" {
+		t.Errorf("Referencia = %q, want a versão mais recente pacs.002.001.10", imp.Referencia)
+	}
+	for _, tag := range imp.Tags {
+		if tag.IDTag == "Prtry" && tag.NumSeqTag != 15 {
+			t.Errorf("Prtry = %+v, want num_seq_tag 15 (sem correspondente na pacs.002.001.10, segue Cd)", tag)
+		}
+	}
+
+	// Sem versão da família, a numeração vai de 1 a N na ordem do documento
+	numeracao, err := NovaNumeracaoNumSeqTag(cat, "camt.056.001.08")
+	if err != nil || numeracao.Referencia != "" {
+		t.Fatalf("NovaNumeracaoNumSeqTag() = %+v, %v, want sem referência", numeracao, err)
+	}
+	if a, b := numeracao.Numerar([]string{"Document"}), numeracao.Numerar([]string{"Document", "X"}); a != 1 || b != 2 {
+		t.Errorf("Numerar() = %d, %d, want 1, 2", a, b)
+	}
+}
@@ -0,0 +1,184 @@
+package esptag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// PesosPontuacao define os pesos das regras usadas para pontuar candidatos de spi_mensagem_tag
+type PesosPontuacao struct {
+	Base            int `json:"base"`              // Tag encontrada na mensagem
+	TagPai          int `json:"tag_pai"`           // Tag pai no XML igual à tag pai do registro
+	CaminhoPorNivel int `json:"caminho_por_nivel"` // Por nível coincidente do caminho, multiplicado pela proximidade da tag
+	Filho           int `json:"filho"`             // Por filho da tag no XML que também é filho do registro
+	Irmao           int `json:"irmao"`             // Por irmão da tag no XML que também é irmão do registro
+	OrdemIrmao      int `json:"ordem_irmao"`       // Por irmão cuja posição (antes/depois) confere, ou não, com num_seq_tag
+}
+
+// PesosPadrao retorna os pesos usados quando nenhum arquivo de configuração é informado
+func PesosPadrao() PesosPontuacao {
+	return PesosPontuacao{
+		Base:            10,
+		TagPai:          15,
+		CaminhoPorNivel: 2,
+		Filho:           6,
+		Irmao:           6,
+		OrdemIrmao:      2,
+	}
+}
+
+// CarregarPesos lê os pesos de um arquivo JSON. Pesos ausentes no arquivo mantêm o valor padrão.
+func CarregarPesos(caminho string) (PesosPontuacao, error) {
+	pesos := PesosPadrao()
+
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return pesos, fmt.Errorf("erro ao ler arquivo de pesos: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(conteudo))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&pesos); err != nil {
+		return pesos, fmt.Errorf("erro ao interpretar arquivo de pesos: %v", err)
+	}
+
+	return pesos, nil
+}
+
+// RegraAplicada registra uma regra de pontuação que contribuiu para a pontuação de um candidato
+type RegraAplicada struct {
+	Regra   string `json:"regra"`
+	Pontos  int    `json:"pontos"`
+	Detalhe string `json:"detalhe"`
+}
+
+// Scorer pontua um candidato de spi_mensagem_tag em relação a uma ocorrência da tag no XML,
+// retornando as regras aplicadas
+type Scorer interface {
+	Pontuar(arvore *ArvoreMensagem, candidato MensagemTagInfo, ocorrencia util.TagOccurrence) []RegraAplicada
+}
+
+// SomarPontos retorna a pontuação total das regras aplicadas
+func SomarPontos(regras []RegraAplicada) int {
+	total := 0
+	for _, r := range regras {
+		total += r.Pontos
+	}
+	return total
+}
+
+// ScorerPadrao compara tag pai, caminho de ancestrais, filhos, irmãos e ordem dos irmãos
+type ScorerPadrao struct {
+	Pesos PesosPontuacao
+}
+
+// NovoScorer cria o ScorerPadrao com os pesos informados
+func NovoScorer(pesos PesosPontuacao) *ScorerPadrao {
+	return &ScorerPadrao{Pesos: pesos}
+}
+
+// Pontuar aplica as regras do ScorerPadrao ao candidato. Regras que não somam pontos são omitidas.
+func (s *ScorerPadrao) Pontuar(arvore *ArvoreMensagem, candidato MensagemTagInfo, ocorrencia util.TagOccurrence) []RegraAplicada {
+	regras := []RegraAplicada{{Regra: "base", Pontos: s.Pesos.Base, Detalhe: "tag encontrada na mensagem"}}
+	adicionar := func(regra string, pontos int, detalhe string) {
+		if pontos != 0 {
+			regras = append(regras, RegraAplicada{Regra: regra, Pontos: pontos, Detalhe: detalhe})
+		}
+	}
+
+	if ocorrencia.Parent != "" && candidato.IDTagPai == ocorrencia.Parent {
+		adicionar("tag_pai", s.Pesos.TagPai, ocorrencia.Parent)
+	}
+
+	if caminhoDB := arvore.Caminho(candidato.NumSeqMsgTag); caminhoDB != nil {
+		pontos, niveis := pontuacaoCaminho(ocorrencia.Path, caminhoDB, s.Pesos.CaminhoPorNivel)
+		adicionar("caminho", pontos, "níveis coincidentes: "+strings.Join(niveis, ", "))
+	}
+
+	filhosDB := make(map[string]bool)
+	for _, f := range arvore.Filhos(candidato.NumSeqMsgTag) {
+		filhosDB[f.IDTag] = true
+	}
+	for _, filho := range ocorrencia.Children {
+		if filhosDB[filho] {
+			adicionar("filho", s.Pesos.Filho, filho)
+		}
+	}
+
+	// Irmãos do candidato por nome, com o num_seq_tag de cada registro
+	var irmaos []MensagemTagInfo
+	if pai, ok := arvore.Pai(candidato.NumSeqMsgTag); ok {
+		irmaos = arvore.Filhos(pai.NumSeqMsgTag)
+	} else {
+		irmaos = arvore.Raizes()
+	}
+	irmaosDB := make(map[string][]int)
+	for _, irmao := range irmaos {
+		if irmao.NumSeqMsgTag != candidato.NumSeqMsgTag {
+			irmaosDB[irmao.IDTag] = append(irmaosDB[irmao.IDTag], irmao.NumSeqTag)
+		}
+	}
+
+	pontuarIrmaos := func(nomes []string, antes bool) {
+		posicao := "depois"
+		if antes {
+			posicao = "antes"
+		}
+
+		for _, nome := range nomes {
+			seqs, ok := irmaosDB[nome]
+			if !ok {
+				continue
+			}
+			adicionar("irmao", s.Pesos.Irmao, nome)
+
+			ordemConfere := false
+			for _, seq := range seqs {
+				if (antes && seq < candidato.NumSeqTag) || (!antes && seq > candidato.NumSeqTag) {
+					ordemConfere = true
+					break
+				}
+			}
+			if ordemConfere {
+				adicionar("ordem_irmao", s.Pesos.OrdemIrmao, fmt.Sprintf("%s %s da tag, conforme num_seq_tag", nome, posicao))
+			} else {
+				adicionar("ordem_irmao", -s.Pesos.OrdemIrmao, fmt.Sprintf("%s %s da tag no XML, mas não em num_seq_tag", nome, posicao))
+			}
+		}
+	}
+	pontuarIrmaos(ocorrencia.PrecedingSiblings, true)
+	pontuarIrmaos(ocorrencia.FollowingSiblings, false)
+
+	return regras
+}
+
+// pontuacaoCaminho compara os caminhos a partir da tag em direção à raiz. Cada nível coincidente soma
+// o peso multiplicado pela proximidade da tag, e os nomes coincidentes são retornados para explicação.
+func pontuacaoCaminho(caminhoXML []string, caminhoDB []string, pesoPorNivel int) (int, []string) {
+	pontuacao := 0
+	var niveis []string
+	if caminhoXML == nil || caminhoDB == nil {
+		return 0, nil
+	}
+	maxLen := len(caminhoXML)
+	if len(caminhoDB) < maxLen {
+		maxLen = len(caminhoDB)
+	}
+	for i := 1; i <= maxLen; i++ {
+		idxXML := len(caminhoXML) - i
+		idxDB := len(caminhoDB) - i
+		if idxXML < 0 || idxDB < 0 {
+			break
+		}
+		if caminhoXML[idxXML] == caminhoDB[idxDB] {
+			pontuacao += (maxLen - i + 1) * pesoPorNivel
+			niveis = append(niveis, caminhoXML[idxXML])
+		}
+	}
+	return pontuacao, niveis
+}
@@ -0,0 +1,61 @@
+package esptag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sq_pix/internal/esptag/util"
+)
+
+func TestCarregarPesos(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "pesos.json")
+	if err := os.WriteFile(caminho, []byte(`{"tag_pai": 30, "filho": 1}`), 0o644); err != nil {
+		t.Fatalf("erro ao gravar arquivo: %v", err)
+	}
+
+	pesos, err := CarregarPesos(caminho)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	esperado := PesosPadrao()
+	esperado.TagPai, esperado.Filho = 30, 1
+	if pesos != esperado {
+		t.Errorf("pesos = %+v, esperado %+v", pesos, esperado)
+	}
+
+	if err := os.WriteFile(caminho, []byte(`{"tag_mae": 30}`), 0o644); err != nil {
+		t.Fatalf("erro ao gravar arquivo: %v", err)
+	}
+	if _, err := CarregarPesos(caminho); err == nil {
+		t.Error("peso desconhecido deveria ser rejeitado")
+	}
+}
+
+func TestScorerPadraoRegras(t *testing.T) {
+	arvore, err := novoCatalogoTeste().ObterArvoreMensagem("pacs.002.001.10")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	ocorrencias, err := util.FindTagOccurrences(`<TxInfAndSts><OrgnlEndToEndId/><TxSts>RJCT</TxSts><StsRsnInf/></TxInfAndSts>`, "TxSts")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	candidato, _ := arvore.Tag(111)
+
+	regras := NovoScorer(PesosPadrao()).Pontuar(arvore, candidato, ocorrencias[0])
+
+	pontos := make(map[string]int)
+	for _, r := range regras {
+		pontos[r.Regra] += r.Pontos
+	}
+	esperado := map[string]int{"base": 10, "tag_pai": 15, "caminho": 6, "irmao": 12, "ordem_irmao": 4}
+	for regra, p := range esperado {
+		if pontos[regra] != p {
+			t.Errorf("regra %s = %d, esperado %d (regras: %+v)", regra, pontos[regra], p, regras)
+		}
+	}
+	if SomarPontos(regras) != 47 {
+		t.Errorf("total = %d, esperado 47", SomarPontos(regras))
+	}
+}
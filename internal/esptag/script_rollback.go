@@ -0,0 +1,22 @@
+package esptag
+
+import (
+	"strings"
+)
+
+// separadorRollback separa o script de implantação do script de rollback correspondente
+const separadorRollback = "-- ==================== ROLLBACK ====================\n"
+
+// escreverScriptRollback anexa o script de rollback após o script de implantação
+func escreverScriptRollback(resultado *strings.Builder, rollback string) {
+	if strings.HasSuffix(resultado.String(), "\n") {
+		resultado.WriteString("\n")
+	} else {
+		resultado.WriteString("\n\n")
+	}
+	resultado.WriteString(separadorRollback)
+	resultado.WriteString(rollback)
+	if !strings.HasSuffix(rollback, "\n") {
+		resultado.WriteString("\n")
+	}
+}
@@ -0,0 +1,269 @@
+package esptag
+
+import (
+	"fmt"
+	"sort"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// SimulaExtracaoArgs define os argumentos de entrada para o MCP
+type SimulaExtracaoArgs struct {
+	XMLMensagem      string `json:"xml_mensagem" jsonschema:"required,description=Mensagem PIX completa em XML (Document, opcionalmente dentro de um envelope com AppHdr)"`
+	IDEveMensagem    string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pacs.002.001.10)"`
+	IncluirChangeset bool   `json:"incluir_changeset" jsonschema:"description=Se verdadeiro, considera também as vinculações pendentes no changeset do servidor, ainda não aplicadas na base"`
+	ArquivoXSD       string `json:"arquivo_xsd" jsonschema:"description=Caminho local do XSD oficial da mensagem (ex: xsd/pacs.002.001.10.xsd); se informado, as tags ausentes são classificadas como obrigatórias ou opcionais"`
+	ElementoRaiz     string `json:"elemento_raiz" jsonschema:"description=Elemento global do XSD que é a raiz da mensagem (padrão: Document)"`
+}
+
+// ObrigatoriedadeAusencia classifica, pelo XSD, a ausência de uma tag vinculada no XML
+type ObrigatoriedadeAusencia string
+
+const (
+	AusenciaObrigatoria ObrigatoriedadeAusencia = "obrigatoria" // A tag deveria estar presente sob o ancestral presente
+	AusenciaOpcional    ObrigatoriedadeAusencia = "opcional"    // A tag ou um ancestral ausente é opcional ou alternativa de um xs:choice
+	AusenciaForaDoXSD   ObrigatoriedadeAusencia = "fora_do_xsd" // O caminho cadastrado da tag não existe no XSD
+)
+
+// ValorExtraido é o conteúdo de uma ocorrência da tag vinculada no XML
+type ValorExtraido struct {
+	Valor        string
+	Caminho      []string // Caminho do elemento no XML
+	PossuiFilhos bool     // O elemento é um grupo, sem conteúdo textual próprio
+}
+
+// ExtracaoEspecializacao reúne os valores extraídos do XML para um vínculo de spi_especializacao_msg_tag
+type ExtracaoEspecializacao struct {
+	Especializacao EspecializacaoTag
+	Vinculo        EspecializacaoMsgTag
+	Caminho        []string // Caminho cadastrado da tag vinculada
+	Pendente       bool     // Vínculo presente apenas no changeset
+	Valores        []ValorExtraido
+
+	// Incertos são as ocorrências resolvidas no registro vinculado sem correspondência clara, que não
+	// entram nos valores extraídos
+	Incertos []ValorExtraido
+
+	// AncestralPresente é o ancestral mais próximo da tag vinculada presente no XML, quando ela está ausente
+	AncestralPresente string
+
+	// Obrigatoriedade classifica a ausência pelo XSD; vazia se a tag está presente ou sem XSD informado
+	Obrigatoriedade ObrigatoriedadeAusencia
+}
+
+// Ausente indica que a tag vinculada não aparece no XML
+func (e ExtracaoEspecializacao) Ausente() bool {
+	return len(e.Valores) == 0 && len(e.Incertos) == 0
+}
+
+// Incerta indica que a tag vinculada aparece no XML apenas em ocorrências sem correspondência clara
+func (e ExtracaoEspecializacao) Incerta() bool {
+	return len(e.Valores) == 0 && len(e.Incertos) > 0
+}
+
+// Vazia indica que a tag vinculada aparece no XML, mas nenhuma ocorrência possui conteúdo
+func (e ExtracaoEspecializacao) Vazia() bool {
+	for _, v := range e.Valores {
+		if v.Valor != "" {
+			return false
+		}
+	}
+	return len(e.Valores) > 0
+}
+
+// SimulacaoExtracao é o resultado da simulação da extração dos valores de cada especialização de uma mensagem
+type SimulacaoExtracao struct {
+	IDEveMensagem string
+	Extracoes     []ExtracaoEspecializacao
+	Avisos        []string
+}
+
+// Contar retorna as quantidades de vínculos com valor, ausentes, vazios, apenas com ocorrências incertas e
+// com mais de uma ocorrência
+func (s *SimulacaoExtracao) Contar() (comValor, ausentes, vazias, incertas, repetidas int) {
+	for _, e := range s.Extracoes {
+		switch {
+		case e.Ausente():
+			ausentes++
+		case e.Incerta():
+			incertas++
+		case e.Vazia():
+			vazias++
+		default:
+			comValor++
+		}
+		if len(e.Valores) > 1 {
+			repetidas++
+		}
+	}
+	return comValor, ausentes, vazias, incertas, repetidas
+}
+
+// ContarAusencias retorna a quantidade de tags vinculadas ausentes com a obrigatoriedade informada
+func (s *SimulacaoExtracao) ContarAusencias(obrigatoriedade ObrigatoriedadeAusencia) int {
+	total := 0
+	for _, e := range s.Extracoes {
+		if e.Ausente() && e.Obrigatoriedade == obrigatoriedade {
+			total++
+		}
+	}
+	return total
+}
+
+// SimularExtracao resolve os elementos do XML com AnotarMensagem e, para cada vínculo da mensagem em
+// spi_especializacao_msg_tag, acrescido das vinculações pendentes informadas, retorna os valores das ocorrências
+// resolvidas no num_seq_msg_tag vinculado. Vinculações pendentes já existentes na base são desconsideradas, e as
+// que apontam para um num_seq_msg_tag inexistente na mensagem geram um aviso.
+//
+// Ocorrências com correspondência incerta são separadas dos valores. Com a árvore expandida do XSD, cada tag
+// ausente é classificada como obrigatória quando ela e os ancestrais ausentes abaixo do ancestral presente
+// têm minOccurs maior que zero e não são alternativas de um xs:choice.
+func SimularExtracao(cat Catalog, scorer Scorer, xmlMensagem string, idEveMensagem string,
+	vinculosPendentes []EspecializacaoMsgTag, especializacoesPendentes []EspecializacaoTag, raiz *util.XSDNode) (*SimulacaoExtracao, error) {
+
+	anotada, err := AnotarMensagem(cat, scorer, xmlMensagem, idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+	descricoes := make(map[int]string)
+	for _, esp := range esps {
+		descricoes[esp.ID] = esp.Descricao
+	}
+	for _, esp := range especializacoesPendentes {
+		descricoes[esp.ID] = esp.Descricao
+	}
+
+	simulacao := &SimulacaoExtracao{IDEveMensagem: idEveMensagem}
+
+	pendentes := make(map[string]bool)
+	existentes := make(map[string]bool)
+	for _, v := range vinculos {
+		existentes[fmt.Sprintf("%d:%d", v.IDEspecializacao, v.NumSeqMsgTag)] = true
+	}
+	for _, v := range vinculosPendentes {
+		chave := fmt.Sprintf("%d:%d", v.IDEspecializacao, v.NumSeqMsgTag)
+		if existentes[chave] || pendentes[chave] {
+			continue
+		}
+		if _, ok := arvore.Tag(v.NumSeqMsgTag); !ok {
+			simulacao.Avisos = append(simulacao.Avisos, fmt.Sprintf("a vinculação pendente da especialização %d aponta para num_seq_msg_tag %d, que não existe em %s",
+				v.IDEspecializacao, v.NumSeqMsgTag, idEveMensagem))
+			continue
+		}
+		pendentes[chave] = true
+		vinculos = append(vinculos, v)
+	}
+
+	// Elementos do XML por registro de spi_mensagem_tag, na ordem do documento
+	elementos := make(map[int][]ElementoAnotado)
+	for _, e := range anotada.Elementos {
+		if e.Situacao != ElementoNaoCadastrado {
+			elementos[e.Registro.NumSeqMsgTag] = append(elementos[e.Registro.NumSeqMsgTag], e)
+		}
+	}
+
+	for _, v := range vinculos {
+		descricao, ok := descricoes[v.IDEspecializacao]
+		if !ok {
+			descricao = "sem registro em spi_especializacao_tag"
+		}
+		extracao := ExtracaoEspecializacao{
+			Especializacao: EspecializacaoTag{ID: v.IDEspecializacao, Descricao: descricao},
+			Vinculo:        v,
+			Caminho:        arvore.Caminho(v.NumSeqMsgTag),
+			Pendente:       pendentes[fmt.Sprintf("%d:%d", v.IDEspecializacao, v.NumSeqMsgTag)],
+		}
+		for _, e := range elementos[v.NumSeqMsgTag] {
+			valor := ValorExtraido{
+				Valor:        e.Ocorrencia.Text,
+				Caminho:      e.Ocorrencia.Path,
+				PossuiFilhos: len(e.Ocorrencia.Children) > 0,
+			}
+			if e.Situacao == ElementoIncerto {
+				extracao.Incertos = append(extracao.Incertos, valor)
+			} else {
+				extracao.Valores = append(extracao.Valores, valor)
+			}
+		}
+		if extracao.Ausente() {
+			// Quantidade de níveis do caminho, a partir do fim, que estão ausentes do XML
+			ausentes := 1
+			for pai, ok := arvore.Pai(v.NumSeqMsgTag); ok; pai, ok = arvore.Pai(pai.NumSeqMsgTag) {
+				if len(elementos[pai.NumSeqMsgTag]) > 0 {
+					extracao.AncestralPresente = pai.IDTag
+					break
+				}
+				ausentes++
+			}
+			if raiz != nil {
+				extracao.Obrigatoriedade = obrigatoriedadeAusencia(raiz, extracao.Caminho, ausentes)
+			}
+		}
+		simulacao.Extracoes = append(simulacao.Extracoes, extracao)
+	}
+
+	sort.SliceStable(simulacao.Extracoes, func(i, j int) bool {
+		a, b := simulacao.Extracoes[i], simulacao.Extracoes[j]
+		if a.Especializacao.ID != b.Especializacao.ID {
+			return a.Especializacao.ID < b.Especializacao.ID
+		}
+		return a.Vinculo.NumSeqMsgTag < b.Vinculo.NumSeqMsgTag
+	})
+
+	return simulacao, nil
+}
+
+// obrigatoriedadeAusencia classifica a ausência dos últimos níveis do caminho cadastrado pelo XSD
+func obrigatoriedadeAusencia(raiz *util.XSDNode, caminho []string, ausentes int) ObrigatoriedadeAusencia {
+	nos := nosCaminhoXSD(raiz, caminho)
+	if nos == nil {
+		return AusenciaForaDoXSD
+	}
+	for _, no := range nos[max(len(nos)-ausentes, 0):] {
+		if no.MinOccurs == 0 || no.Choice {
+			return AusenciaOpcional
+		}
+	}
+	return AusenciaObrigatoria
+}
+
+// nosCaminhoXSD retorna os nós da árvore expandida do XSD correspondentes a cada nível do caminho, ou nil se
+// o caminho não existe no XSD. Caminhos cadastrados sem o elemento raiz começam pelos seus filhos.
+func nosCaminhoXSD(raiz *util.XSDNode, caminho []string) []*util.XSDNode {
+	if len(caminho) == 0 {
+		return nil
+	}
+
+	var nos []*util.XSDNode
+	candidatos := raiz.Children
+	if caminho[0] == raiz.Name {
+		candidatos = []*util.XSDNode{raiz}
+	}
+	for _, nome := range caminho {
+		var encontrado *util.XSDNode
+		for _, no := range candidatos {
+			if no.Name == nome {
+				encontrado = no
+				break
+			}
+		}
+		if encontrado == nil {
+			return nil
+		}
+		nos = append(nos, encontrado)
+		candidatos = encontrado.Children
+	}
+	return nos
+}
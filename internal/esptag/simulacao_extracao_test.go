@@ -0,0 +1,114 @@
+package esptag
+
+import "testing"
+
+const xmlSimulacaoTeste = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10">
+  <FIToFIPmtStsRpt>
+    <GrpHdr><MsgId>M1</MsgId></GrpHdr>
+    <TxInfAndSts><TxSts>RJCT</TxSts><StsRsnInf><Rsn></Rsn></StsRsnInf></TxInfAndSts>
+    <TxInfAndSts><TxSts>ACSC</TxSts></TxInfAndSts>
+  </FIToFIPmtStsRpt>
+</Document>`
+
+func TestSimularExtracao(t *testing.T) {
+	changeset := NovoChangeset()
+	changeset.AdicionarEspecializacao("Código do motivo", 8)
+	changeset.AdicionarVinculacao(GeraScriptVinculacaoArgs{IDEspecializacao: 8, IDEveMensagem: "pacs.002.001.10", IDTag: "Cd", IDTagPai: "Rsn", NumSeqTag: 14, NumSeqMsgTag: 114})
+	changeset.AdicionarVinculacao(GeraScriptVinculacaoArgs{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.10", IDTag: "TxSts", IDTagPai: "TxInfAndSts", NumSeqTag: 11, NumSeqMsgTag: 111})
+	changeset.AdicionarVinculacao(GeraScriptVinculacaoArgs{IDEspecializacao: 5, IDEveMensagem: "pacs.002.001.10", IDTag: "GrpSts", IDTagPai: "OrgnlGrpInfAndSts", NumSeqTag: 9, NumSeqMsgTag: 999})
+	changeset.AdicionarVinculacao(GeraScriptVinculacaoArgs{IDEspecializacao: 7, IDEveMensagem: "pacs.008.001.08", IDTag: "TxSts", IDTagPai: "TxInf", NumSeqTag: 4, NumSeqMsgTag: 204})
+
+	simulacao, err := SimularExtracao(novoCatalogoTeste(), NovoScorer(PesosPadrao()), xmlSimulacaoTeste, "pacs.002.001.10",
+		changeset.VinculacoesPendentes("pacs.002.001.10"), changeset.EspecializacoesPendentes(), nil)
+	if err != nil {
+		t.Fatalf("SimularExtracao() error = %v", err)
+	}
+
+	// A vinculação pendente já existente na base é desconsiderada, e a de num_seq_msg_tag inexistente gera aviso
+	if len(simulacao.Extracoes) != 2 || len(simulacao.Avisos) != 1 {
+		t.Fatalf("SimularExtracao() = %+v, want 2 extrações e 1 aviso", simulacao)
+	}
+
+	txSts := simulacao.Extracoes[0]
+	if txSts.Especializacao.ID != 3 || txSts.Pendente || len(txSts.Valores) != 2 ||
+		txSts.Valores[0].Valor != "RJCT" || txSts.Valores[1].Valor != "ACSC" {
+		t.Errorf("Extracoes[0] = %+v, want especialização 3 com RJCT e ACSC", txSts)
+	}
+
+	cd := simulacao.Extracoes[1]
+	if cd.Especializacao.ID != 8 || cd.Especializacao.Descricao != "Código do motivo" || !cd.Pendente ||
+		!cd.Ausente() || cd.AncestralPresente != "Rsn" || cd.Obrigatoriedade != "" {
+		t.Errorf("Extracoes[1] = %+v, want especialização 8 pendente, ausente sob Rsn", cd)
+	}
+
+	if comValor, ausentes, vazias, incertas, repetidas := simulacao.Contar(); comValor != 1 || ausentes != 1 || vazias != 0 || incertas != 0 || repetidas != 1 {
+		t.Errorf("Contar() = %d, %d, %d, %d, %d, want 1, 1, 0, 0, 1", comValor, ausentes, vazias, incertas, repetidas)
+	}
+}
+
+func TestSimularExtracaoSemPendentes(t *testing.T) {
+	simulacao, err := SimularExtracao(novoCatalogoTeste(), NovoScorer(PesosPadrao()), `<Document><FIToFIPmtStsRpt/></Document>`, "pacs.002.001.10", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("SimularExtracao() error = %v", err)
+	}
+	if len(simulacao.Extracoes) != 1 || !simulacao.Extracoes[0].Ausente() || simulacao.Extracoes[0].AncestralPresente != "FIToFIPmtStsRpt" {
+		t.Errorf("SimularExtracao() = %+v, want TxSts ausente sob FIToFIPmtStsRpt", simulacao.Extracoes)
+	}
+}
+
+func TestSimularExtracaoObrigatoriedadeXSD(t *testing.T) {
+	pendentes := []EspecializacaoMsgTag{
+		{IDEspecializacao: 8, IDEveMensagem: "pacs.002.001.10", NumSeqMsgTag: 105},  // CreDtTm, obrigatória no GrpHdr presente
+		{IDEspecializacao: 9, IDEveMensagem: "pacs.002.001.10", NumSeqMsgTag: 114},  // Cd, alternativa do xs:choice em Rsn
+		{IDEspecializacao: 10, IDEveMensagem: "pacs.002.001.10", NumSeqMsgTag: 108}, // OrgnlMsgNmId, sob o grupo opcional ausente
+	}
+	simulacao, err := SimularExtracao(novoCatalogoTeste(), NovoScorer(PesosPadrao()), xmlSimulacaoTeste, "pacs.002.001.10",
+		pendentes, nil, expandirXSDTeste(t))
+	if err != nil {
+		t.Fatalf("SimularExtracao() error = %v", err)
+	}
+
+	esperadas := map[int]ObrigatoriedadeAusencia{8: AusenciaObrigatoria, 9: AusenciaOpcional, 10: AusenciaOpcional}
+	for _, e := range simulacao.Extracoes {
+		if want, ok := esperadas[e.Especializacao.ID]; ok && (!e.Ausente() || e.Obrigatoriedade != want) {
+			t.Errorf("especialização %d = %+v, want ausente %s", e.Especializacao.ID, e, want)
+		}
+	}
+	if simulacao.ContarAusencias(AusenciaObrigatoria) != 1 || simulacao.ContarAusencias(AusenciaOpcional) != 2 {
+		t.Errorf("ContarAusencias() = %d obrigatórias, %d opcionais, want 1 e 2",
+			simulacao.ContarAusencias(AusenciaObrigatoria), simulacao.ContarAusencias(AusenciaOpcional))
+	}
+
+	// Caminho cadastrado que não existe no XSD
+	if got := obrigatoriedadeAusencia(expandirXSDTeste(t), []string{"Document", "FIToFIPmtStsRpt", "GrpHdr", "SttlmInf"}, 1); got != AusenciaForaDoXSD {
+		t.Errorf("obrigatoriedadeAusencia(SttlmInf) = %s, want %s", got, AusenciaForaDoXSD)
+	}
+}
+
+func TestSimularExtracaoIncertos(t *testing.T) {
+	// Um segundo registro de TxSts no mesmo caminho impede a correspondência clara das ocorrências
+	tags := append(tagsPacs002(), MensagemTagInfo{IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "TxSts", IDTagPai: "TxInfAndSts", NumSeqTag: 11, NumSeqMsgTag: 140})
+	cat := NewMemoryCatalog(DadosCatalogo{
+		MensagemTags: tags,
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.10", NumSeqMsgTag: 111},
+			{IDEspecializacao: 4, IDEveMensagem: "pacs.002.001.10", NumSeqMsgTag: 140},
+		},
+	})
+
+	simulacao, err := SimularExtracao(cat, NovoScorer(PesosPadrao()), xmlSimulacaoTeste, "pacs.002.001.10", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("SimularExtracao() error = %v", err)
+	}
+
+	incertos := 0
+	for _, e := range simulacao.Extracoes {
+		if len(e.Valores) > 0 {
+			t.Errorf("especialização %d = %+v, want ocorrências incertas fora dos valores", e.Especializacao.ID, e)
+		}
+		incertos += len(e.Incertos)
+	}
+	if _, ausentes, _, incertas, _ := simulacao.Contar(); incertos != 2 || incertas != 1 || ausentes != 1 {
+		t.Errorf("SimularExtracao() = %d ocorrências incertas, %d vínculos incertos, %d ausentes, want 2, 1 e 1", incertos, incertas, ausentes)
+	}
+}
@@ -0,0 +1,78 @@
+package esptag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// VersaoSnapshot é a versão do formato do arquivo de snapshot gerado por ExportarSnapshot
+const VersaoSnapshot = 1
+
+// Snapshot é a cópia das tabelas spi_* gravada em JSON para uso offline
+type Snapshot struct {
+	Versao   int       `json:"versao"`
+	GeradoEm time.Time `json:"gerado_em"`
+	Origem   string    `json:"origem"`
+	DadosCatalogo
+}
+
+// ExportarSnapshot lê todas as tabelas do catálogo e monta um snapshot
+func ExportarSnapshot(cat Catalog, origem string) (*Snapshot, error) {
+	var dados DadosCatalogo
+	var err error
+
+	if dados.MensagemTags, err = cat.ListarMensagemTags(); err != nil {
+		return nil, fmt.Errorf("erro ao exportar spi_mensagem_tag: %v", err)
+	}
+	if dados.Especializacoes, err = cat.ListarEspecializacoes(); err != nil {
+		return nil, fmt.Errorf("erro ao exportar spi_especializacao_tag: %v", err)
+	}
+	if dados.Vinculos, err = cat.ListarVinculos(); err != nil {
+		return nil, fmt.Errorf("erro ao exportar spi_especializacao_msg_tag: %v", err)
+	}
+	if dados.SitMsgEmiDes, err = cat.ListarSitMsgEmiDes(); err != nil {
+		return nil, fmt.Errorf("erro ao exportar spi_sit_msg_emi_des: %v", err)
+	}
+
+	return &Snapshot{
+		Versao:        VersaoSnapshot,
+		GeradoEm:      time.Now(),
+		Origem:        origem,
+		DadosCatalogo: dados,
+	}, nil
+}
+
+// SalvarSnapshot grava o snapshot em um arquivo JSON
+func SalvarSnapshot(snapshot *Snapshot, caminho string) error {
+	conteudo, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar snapshot: %v", err)
+	}
+
+	if err := os.WriteFile(caminho, conteudo, 0644); err != nil {
+		return fmt.Errorf("erro ao gravar snapshot em '%s': %v", caminho, err)
+	}
+
+	return nil
+}
+
+// CarregarSnapshot lê um arquivo de snapshot e valida a versão do formato
+func CarregarSnapshot(caminho string) (*Snapshot, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler snapshot '%s': %v", caminho, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(conteudo, &snapshot); err != nil {
+		return nil, fmt.Errorf("erro ao interpretar snapshot '%s': %v", caminho, err)
+	}
+
+	if snapshot.Versao != VersaoSnapshot {
+		return nil, fmt.Errorf("versão de snapshot %d não suportada (esperada %d)", snapshot.Versao, VersaoSnapshot)
+	}
+
+	return &snapshot, nil
+}
@@ -0,0 +1,180 @@
+package esptag
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// maxSugestoesExibidas limita as especializações sugeridas exibidas para cada registro
+const maxSugestoesExibidas = 5
+
+// ReferenciaSugestao é um vínculo de outra mensagem que fundamenta a sugestão de uma especialização
+type ReferenciaSugestao struct {
+	IDEveMensagem string `json:"id_eve_msg"`
+	NumSeqMsgTag  int    `json:"num_seq_msg_tag"`
+	Caminho       string `json:"caminho"`
+	Similaridade  int    `json:"similaridade"` // Similaridade (0 a 100) entre o caminho do vínculo e o do registro
+}
+
+// SugestaoEspecializacao é uma especialização já vinculada à mesma tag em outras mensagens,
+// candidata a ser vinculada ao registro encontrado
+type SugestaoEspecializacao struct {
+	EspecializacaoTag
+	Similaridade int                  `json:"similaridade"` // Maior similaridade entre as referências
+	JaVinculada  bool                 `json:"ja_vinculada"` // A especialização já está vinculada ao próprio registro
+	Confiavel    bool                 `json:"confiavel"`    // Alguma referência tem o mesmo caminho ou é da mesma família de mensagens
+	Referencias  []ReferenciaSugestao `json:"referencias"`  // Vínculos em outras mensagens, do mais similar para o menos similar
+}
+
+// BaseSugestoes reúne os vínculos de spi_especializacao_msg_tag, indexados por id_tag, e as descrições das
+// especializações, carregados uma única vez por consulta
+type BaseSugestoes struct {
+	vinculosPorTag map[string][]EspecializacaoMsgTag
+	descricoes     map[int]string
+}
+
+// CarregarBaseSugestoes lê os vínculos e as especializações usados nas sugestões
+func CarregarBaseSugestoes(cat Catalog) (*BaseSugestoes, error) {
+	vinculos, err := cat.ListarVinculos()
+	if err != nil {
+		return nil, err
+	}
+	esps, err := cat.ListarEspecializacoes()
+	if err != nil {
+		return nil, err
+	}
+
+	base := &BaseSugestoes{vinculosPorTag: make(map[string][]EspecializacaoMsgTag), descricoes: make(map[int]string)}
+	for _, v := range vinculos {
+		base.vinculosPorTag[v.IDTag] = append(base.vinculosPorTag[v.IDTag], v)
+	}
+	for _, esp := range esps {
+		base.descricoes[esp.ID] = esp.Descricao
+	}
+	return base, nil
+}
+
+// SugerirEspecializacoes ranqueia as especializações vinculadas à mesma tag em outras mensagens pela
+// similaridade entre o caminho de cada vínculo e o caminho do registro. Caminhos idênticos em versões
+// da mesma mensagem (pacs.002.001.09 e pacs.002.001.10) ficam no topo.
+func SugerirEspecializacoes(cat Catalog, base *BaseSugestoes, info MensagemTagInfo) ([]SugestaoEspecializacao, error) {
+	arvore, err := cat.ObterArvoreMensagem(info.IDEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	caminho := arvore.Caminho(info.NumSeqMsgTag)
+
+	indice := make(map[int]int)
+	var sugestoes []SugestaoEspecializacao
+	for _, v := range base.vinculosPorTag[info.IDTag] {
+		i, ok := indice[v.IDEspecializacao]
+		if !ok {
+			descricao, ok := base.descricoes[v.IDEspecializacao]
+			if !ok {
+				// Vínculo órfão, sem registro em spi_especializacao_tag
+				continue
+			}
+			i = len(sugestoes)
+			indice[v.IDEspecializacao] = i
+			sugestoes = append(sugestoes, SugestaoEspecializacao{EspecializacaoTag: EspecializacaoTag{ID: v.IDEspecializacao, Descricao: descricao}})
+		}
+
+		// O vínculo do próprio registro não é referência, mas indica que a sugestão já foi aplicada
+		if v.IDEveMensagem == info.IDEveMensagem && v.NumSeqMsgTag == info.NumSeqMsgTag {
+			sugestoes[i].JaVinculada = true
+			continue
+		}
+
+		arvoreVinculo, err := cat.ObterArvoreMensagem(v.IDEveMensagem)
+		if err != nil {
+			return nil, err
+		}
+		caminhoVinculo := arvoreVinculo.Caminho(v.NumSeqMsgTag)
+
+		referencia := ReferenciaSugestao{
+			IDEveMensagem: v.IDEveMensagem,
+			NumSeqMsgTag:  v.NumSeqMsgTag,
+			Caminho:       strings.Join(caminhoVinculo, " > "),
+			Similaridade:  similaridadeVinculo(info.IDEveMensagem, caminho, v.IDEveMensagem, caminhoVinculo),
+		}
+		sugestoes[i].Referencias = append(sugestoes[i].Referencias, referencia)
+		sugestoes[i].Similaridade = max(sugestoes[i].Similaridade, referencia.Similaridade)
+		if slices.Equal(caminho, caminhoVinculo) || familiaMensagem(info.IDEveMensagem) == familiaMensagem(v.IDEveMensagem) {
+			sugestoes[i].Confiavel = true
+		}
+	}
+
+	for _, s := range sugestoes {
+		sort.SliceStable(s.Referencias, func(i, j int) bool {
+			return s.Referencias[i].Similaridade > s.Referencias[j].Similaridade
+		})
+	}
+
+	sort.SliceStable(sugestoes, func(i, j int) bool {
+		if sugestoes[i].Similaridade != sugestoes[j].Similaridade {
+			return sugestoes[i].Similaridade > sugestoes[j].Similaridade
+		}
+		if len(sugestoes[i].Referencias) != len(sugestoes[j].Referencias) {
+			return len(sugestoes[i].Referencias) > len(sugestoes[j].Referencias)
+		}
+		return sugestoes[i].ID < sugestoes[j].ID
+	})
+
+	return sugestoes, nil
+}
+
+// PreencherSugestoes associa a cada registro ranqueado as especializações sugeridas para ele
+func PreencherSugestoes(cat Catalog, base *BaseSugestoes, resultados []MensagemTagInfo) error {
+	for i := range resultados {
+		sugestoes, err := SugerirEspecializacoes(cat, base, resultados[i])
+		if err != nil {
+			return err
+		}
+		resultados[i].Sugestoes = sugestoes
+	}
+	return nil
+}
+
+// IDEspecializacaoSugerido retorna o ID da especialização mais similar ainda não vinculada ao registro,
+// ou 0 se não houver sugestão confiável (com o mesmo caminho ou da mesma família de mensagens)
+func IDEspecializacaoSugerido(sugestoes []SugestaoEspecializacao) int {
+	for _, s := range sugestoes {
+		if !s.JaVinculada && s.Confiavel && len(s.Referencias) > 0 {
+			return s.ID
+		}
+	}
+	return 0
+}
+
+// similaridadeVinculo compara o caminho de um vínculo com o do registro, nível a nível a partir
+// da tag em direção à raiz. Os níveis coincidentes valem até 80 pontos e mensagens da mesma
+// família (mesmo prefixo, como pacs.002), 20.
+func similaridadeVinculo(idEveMensagem string, caminho []string, idEveVinculo string, caminhoVinculo []string) int {
+	maior := max(len(caminho), len(caminhoVinculo))
+	if maior == 0 {
+		return 0
+	}
+
+	comuns := 0
+	for comuns < len(caminho) && comuns < len(caminhoVinculo) &&
+		caminho[len(caminho)-1-comuns] == caminhoVinculo[len(caminhoVinculo)-1-comuns] {
+		comuns++
+	}
+
+	similaridade := 80 * float64(comuns) / float64(maior)
+	if familiaMensagem(idEveMensagem) == familiaMensagem(idEveVinculo) {
+		similaridade += 20
+	}
+	return int(math.Round(similaridade))
+}
+
+// familiaMensagem retorna a área e o número da mensagem, sem a variante e a versão (pacs.002.001.10 -> pacs.002)
+func familiaMensagem(idEveMensagem string) string {
+	partes := strings.SplitN(idEveMensagem, ".", 3)
+	if len(partes) < 2 {
+		return idEveMensagem
+	}
+	return partes[0] + "." + partes[1]
+}
@@ -0,0 +1,107 @@
+package esptag
+
+import (
+	"testing"
+)
+
+// novoCatalogoSugestao cria um catálogo com duas versões da pacs.002 e a pacs.008,
+// com a situação da transação vinculada nas duas outras mensagens. Como na tabela, num_seq_msg_tag
+// não se repete entre as mensagens.
+func novoCatalogoSugestao() *MemoryCatalog {
+	tags := tagsPacs002()
+	for _, t := range tagsPacs002() {
+		t.IDEveMensagem = "pacs.002.001.09"
+		t.NumSeqMsgTag += 900
+		tags = append(tags, t)
+	}
+	tags = append(tags,
+		MensagemTagInfo{IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxInf", NumSeqTag: 1, NumSeqMsgTag: 201},
+		MensagemTagInfo{IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxSts", IDTagPai: "TxInf", NumSeqTag: 2, NumSeqMsgTag: 202},
+	)
+
+	return NewMemoryCatalog(DadosCatalogo{
+		MensagemTags: tags,
+		Especializacoes: []EspecializacaoTag{
+			{ID: 3, Descricao: "Situação da Transação"},
+			{ID: 9, Descricao: "Situação do pagamento"},
+		},
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 9, IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxSts", NumSeqTag: 2, NumSeqMsgTag: 202},
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.002.001.09", IDTipMensagem: "pacs.002", IDTag: "TxSts", NumSeqTag: 11, NumSeqMsgTag: 1011},
+			{IDEspecializacao: 3, IDEveMensagem: "pacs.008.001.08", IDTipMensagem: "pacs.008", IDTag: "TxSts", NumSeqTag: 2, NumSeqMsgTag: 202},
+		},
+	})
+}
+
+func TestSugerirEspecializacoes(t *testing.T) {
+	cat := novoCatalogoSugestao()
+	arvore, _ := cat.ObterArvoreMensagem("pacs.002.001.10")
+	info, _ := arvore.Tag(111)
+	base, err := CarregarBaseSugestoes(cat)
+	if err != nil {
+		t.Fatalf("CarregarBaseSugestoes() error = %v", err)
+	}
+
+	sugestoes, err := SugerirEspecializacoes(cat, base, info)
+	if err != nil {
+		t.Fatalf("SugerirEspecializacoes() error = %v", err)
+	}
+	if len(sugestoes) != 2 || sugestoes[0].ID != 3 || sugestoes[1].ID != 9 {
+		t.Fatalf("SugerirEspecializacoes() = %+v, want IDs [3 9]", sugestoes)
+	}
+
+	// Mesmo caminho na versão anterior da mensagem: similaridade máxima, referência mais similar primeiro
+	if sugestoes[0].Similaridade != 100 || len(sugestoes[0].Referencias) != 2 || sugestoes[0].Referencias[0].IDEveMensagem != "pacs.002.001.09" ||
+		sugestoes[0].Referencias[0].NumSeqMsgTag != 1011 || !sugestoes[0].Confiavel {
+		t.Errorf("SugerirEspecializacoes()[0] = %+v, want similaridade 100 com pacs.002.001.09 primeiro", sugestoes[0])
+	}
+	// pacs.008: apenas TxSts coincide entre os 4 níveis e a família é outra
+	if sugestoes[1].Similaridade != 20 || sugestoes[1].Confiavel {
+		t.Errorf("SugerirEspecializacoes()[1] = %+v, want similaridade 20, não confiável", sugestoes[1])
+	}
+	if id := IDEspecializacaoSugerido(sugestoes); id != 3 {
+		t.Errorf("IDEspecializacaoSugerido() = %d, want 3", id)
+	}
+
+	// No registro já vinculado, a especialização é marcada e não é usada no comando sugerido; a restante,
+	// vinculada apenas em outra família e em outro caminho, não é confiável para o comando
+	arvore, _ = cat.ObterArvoreMensagem("pacs.002.001.09")
+	info, _ = arvore.Tag(1011)
+	sugestoes, _ = SugerirEspecializacoes(cat, base, info)
+	if len(sugestoes) != 2 || sugestoes[0].ID != 3 || !sugestoes[0].JaVinculada {
+		t.Fatalf("SugerirEspecializacoes() = %+v, want 3 já vinculada", sugestoes)
+	}
+	if id := IDEspecializacaoSugerido(sugestoes); id != 0 {
+		t.Errorf("IDEspecializacaoSugerido() = %d, want 0", id)
+	}
+}
+
+func TestSugerirEspecializacoesMesmoCaminhoOutraFamilia(t *testing.T) {
+	tags := tagsPacs002()
+	for _, t := range tagsPacs002() {
+		t.IDEveMensagem = "pacs.028.001.03"
+		t.NumSeqMsgTag += 300
+		tags = append(tags, t)
+	}
+	cat := NewMemoryCatalog(DadosCatalogo{
+		MensagemTags:    tags,
+		Especializacoes: []EspecializacaoTag{{ID: 9, Descricao: "Situação do pagamento"}},
+		Vinculos: []EspecializacaoMsgTag{
+			{IDEspecializacao: 9, IDEveMensagem: "pacs.028.001.03", IDTipMensagem: "pacs.002", IDTag: "TxSts", NumSeqTag: 11, NumSeqMsgTag: 411},
+		},
+	})
+
+	base, err := CarregarBaseSugestoes(cat)
+	if err != nil {
+		t.Fatalf("CarregarBaseSugestoes() error = %v", err)
+	}
+	arvore, _ := cat.ObterArvoreMensagem("pacs.002.001.10")
+	info, _ := arvore.Tag(111)
+	sugestoes, err := SugerirEspecializacoes(cat, base, info)
+	if err != nil || len(sugestoes) != 1 || !sugestoes[0].Confiavel {
+		t.Fatalf("SugerirEspecializacoes() = %+v, %v, want 9 confiável pelo mesmo caminho", sugestoes, err)
+	}
+	if id := IDEspecializacaoSugerido(sugestoes); id != 9 {
+		t.Errorf("IDEspecializacaoSugerido() = %d, want 9", id)
+	}
+}
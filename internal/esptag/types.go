@@ -10,11 +10,41 @@ type MensagemTagInfo struct {
 	NumSeqMsgTag  int    `json:"num_seq_msg_tag"`
 	Caminho       string `json:"caminho"` // Caminho completo para fins de verificação
 	Score         int    `json:"score"`   // Pontuação de correspondência
+
+	Regras    []RegraAplicada          `json:"regras,omitempty"`    // Regras que compõem a pontuação
+	Sugestoes []SugestaoEspecializacao `json:"sugestoes,omitempty"` // Especializações vinculadas à mesma tag em outras mensagens
+}
+
+// MensagemResumo representa uma mensagem cadastrada em spi_mensagem_tag com a quantidade de tags
+type MensagemResumo struct {
+	IDEveMensagem string `json:"id_eve_msg"`
+	IDTipMensagem string `json:"id_tip_msg"`
+	QtdTags       int    `json:"qtd_tags"`
+}
+
+// EspecializacaoMsgTag representa um registro da tabela spi_especializacao_msg_tag
+type EspecializacaoMsgTag struct {
+	IDEspecializacao int    `json:"id_esp_tag"`
+	IDEveMensagem    string `json:"id_eve_msg"`
+	IDTipMensagem    string `json:"id_tip_msg"`
+	IDTag            string `json:"id_tag"`
+	NumSeqTag        int    `json:"num_seq_tag"`
+	NumSeqMsgTag     int    `json:"num_seq_msg_tag"`
+}
+
+// SitMsgEmiDes representa um registro da tabela spi_sit_msg_emi_des
+type SitMsgEmiDes struct {
+	IDSitMsgEmiDes  string `json:"id_sit_msg_emi_des"`
+	IDTipEmiDes     int    `json:"id_tip_emi_des"`
+	IDSitMsg        int    `json:"id_sit_msg"`
+	DscSitMsgEmiDes string `json:"dsc_sit_msg_emi_des"`
+	CodUsuUltMnt    int    `json:"cod_usu_ult_mnt"`
 }
 
 // ConsultaDadosMensagemArgs define os argumentos de entrada para o MCP
 type ConsultaDadosMensagemArgs struct {
-	CaminhoXML    string `json:"caminho_xml" jsonschema:"required,description=Caminho ou trecho XML que contém a tag a ser especializada"`
+	CaminhoXML    string `json:"caminho_xml" jsonschema:"required,description=Trecho XML, caminho separado por / ou > (ex: FIToFIPmtStsRpt/TxInfAndSts/TxSts) ou XPath simples com predicados posicionais (ex: /Document/FIToFIPmtStsRpt/TxInfAndSts[2]/TxSts) que contém a tag a ser especializada"`
 	NomeTag       string `json:"nome_tag" jsonschema:"required,description=Nome da tag XML que será especializada (ex: TxSts)"`
-	IDEveMensagem string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem (ex: pacs.002) para filtrar a busca"`
+	IDEveMensagem string `json:"id_eve_msg" jsonschema:"description=ID do evento da mensagem (ex: pacs.002.001.10) para filtrar a busca. Se omitido, é identificado pelo namespace do Document ou pelo MsgDefIdr do AppHdr no XML"`
+	Ocorrencia    int    `json:"ocorrencia" jsonschema:"description=Índice (a partir de 1) da ocorrência da tag no XML a ser analisada. Se omitido, todas as ocorrências são analisadas"`
 }
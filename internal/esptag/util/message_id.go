@@ -0,0 +1,77 @@
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ISO20022NamespacePrefix é o prefixo dos namespaces das mensagens ISO 20022, seguido do identificador da mensagem
+const ISO20022NamespacePrefix = "urn:iso:std:iso:20022:tech:xsd:"
+
+// MessageIDHints reúne os identificadores da mensagem encontrados no XML
+type MessageIDHints struct {
+	Namespace string // Identificador extraído do namespace do Document (ex: pacs.002.001.10)
+	MsgDefIdr string // Identificador informado no MsgDefIdr do AppHdr (BAH)
+}
+
+// ID retorna o identificador da mensagem, priorizando o namespace do Document
+func (h MessageIDHints) ID() string {
+	if h.Namespace != "" {
+		return h.Namespace
+	}
+	return h.MsgDefIdr
+}
+
+// Conflicting indica se o namespace e o MsgDefIdr foram encontrados com valores diferentes
+func (h MessageIDHints) Conflicting() bool {
+	return h.Namespace != "" && h.MsgDefIdr != "" && !strings.EqualFold(h.Namespace, h.MsgDefIdr)
+}
+
+// DetectMessageID identifica a mensagem a partir do namespace ISO 20022 do Document e do MsgDefIdr do AppHdr.
+// O namespace do cabeçalho (head.*) é ignorado; na ausência do Document, vale o primeiro elemento
+// com namespace ISO 20022, o que cobre trechos sem o Document.
+func DetectMessageID(xmlInput string) (MessageIDHints, error) {
+	decoder := xml.NewDecoder(bytes.NewReader([]byte(xmlInput)))
+	var hints MessageIDHints
+	var stack []string
+	namespaceFromDocument := false
+
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			break
+		}
+		if tokenErr != nil {
+			return hints, fmt.Errorf("erro ao analisar token XML: %v", tokenErr)
+		}
+
+		switch se := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, se.Name.Local)
+
+			if id := strings.TrimPrefix(se.Name.Space, ISO20022NamespacePrefix); id != se.Name.Space && id != "" && !strings.HasPrefix(id, "head.") {
+				if se.Name.Local == "Document" && !namespaceFromDocument {
+					hints.Namespace = id
+					namespaceFromDocument = true
+				} else if hints.Namespace == "" {
+					hints.Namespace = id
+				}
+			}
+
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] == "MsgDefIdr" && hints.MsgDefIdr == "" {
+				hints.MsgDefIdr = strings.TrimSpace(string(se))
+			}
+
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	return hints, nil
+}
@@ -0,0 +1,61 @@
+package util
+
+import "testing"
+
+func TestDetectMessageID(t *testing.T) {
+	tests := []struct {
+		name            string
+		xmlInput        string
+		wantNamespace   string
+		wantMsgDefIdr   string
+		wantConflicting bool
+	}{
+		{
+			name: "Document com namespace padrão",
+			xmlInput: `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10">
+				<FIToFIPmtStsRpt><GrpHdr><MsgId>1</MsgId></GrpHdr></FIToFIPmtStsRpt>
+			</Document>`,
+			wantNamespace: "pacs.002.001.10",
+		},
+		{
+			name: "Envelope com AppHdr e Document prefixado",
+			xmlInput: `<Envelope>
+				<h:AppHdr xmlns:h="urn:iso:std:iso:20022:tech:xsd:head.001.001.01">
+					<h:MsgDefIdr> pacs.008.001.08 </h:MsgDefIdr>
+				</h:AppHdr>
+				<d:Document xmlns:d="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08"><d:FIToFICstmrCdtTrf/></d:Document>
+			</Envelope>`,
+			wantNamespace: "pacs.008.001.08",
+			wantMsgDefIdr: "pacs.008.001.08",
+		},
+		{
+			name: "AppHdr contraditório",
+			xmlInput: `<Envelope>
+				<AppHdr><MsgDefIdr>pacs.004.001.09</MsgDefIdr></AppHdr>
+				<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08"/>
+			</Envelope>`,
+			wantNamespace:   "pacs.008.001.08",
+			wantMsgDefIdr:   "pacs.004.001.09",
+			wantConflicting: true,
+		},
+		{
+			name:     "Trecho sem identificação",
+			xmlInput: `<GrpHdr><MsgId>1</MsgId></GrpHdr>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hints, err := DetectMessageID(tt.xmlInput)
+			if err != nil {
+				t.Fatalf("DetectMessageID() error = %v", err)
+			}
+			if hints.Namespace != tt.wantNamespace || hints.MsgDefIdr != tt.wantMsgDefIdr {
+				t.Errorf("DetectMessageID() = %+v, want namespace %q e MsgDefIdr %q", hints, tt.wantNamespace, tt.wantMsgDefIdr)
+			}
+			if hints.Conflicting() != tt.wantConflicting {
+				t.Errorf("DetectMessageID().Conflicting() = %v, want %v", hints.Conflicting(), tt.wantConflicting)
+			}
+		})
+	}
+}
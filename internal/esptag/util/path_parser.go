@@ -0,0 +1,139 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathStep representa um passo de um caminho simples ou de uma expressão XPath
+type PathStep struct {
+	Name     string // Nome local da tag, sem prefixo de namespace
+	Position int    // Predicado posicional ([n]); 0 quando não informado
+}
+
+// IsXMLInput indica se a entrada é um trecho XML, e não um caminho
+func IsXMLInput(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), "<")
+}
+
+// ParsePath interpreta caminhos separados por '/' ou '>' e um subconjunto simples de XPath:
+// passos com prefixo de namespace, predicados posicionais ([n]), '/' ou '//' iniciais e text() final.
+// Como o caminho é comparado pelo sufixo, '//' no meio do caminho é tratado como um separador comum.
+func ParsePath(path string) ([]PathStep, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("caminho vazio")
+	}
+
+	separador := "/"
+	if strings.Contains(path, ">") {
+		separador = ">"
+	}
+
+	var steps []PathStep
+	partes := strings.Split(path, separador)
+	for i, parte := range partes {
+		parte = strings.TrimSpace(parte)
+		if parte == "" || parte == "." {
+			continue
+		}
+		if parte == "text()" && i == len(partes)-1 {
+			continue
+		}
+
+		step, err := parsePathStep(parte)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("caminho '%s' não contém nenhuma tag", path)
+	}
+
+	return steps, nil
+}
+
+// parsePathStep interpreta um único passo do caminho, como 'ns:TxInfAndSts[2]'
+func parsePathStep(parte string) (PathStep, error) {
+	nome := parte
+	posicao := 0
+
+	if i := strings.Index(parte, "["); i >= 0 {
+		if !strings.HasSuffix(parte, "]") {
+			return PathStep{}, fmt.Errorf("predicado mal formado no passo '%s'", parte)
+		}
+		predicado := strings.TrimSpace(parte[i+1 : len(parte)-1])
+		n, err := strconv.Atoi(predicado)
+		if err != nil || n < 1 {
+			return PathStep{}, fmt.Errorf("predicado '[%s]' não suportado no passo '%s': apenas posições numéricas ([1], [2], ...) são aceitas", predicado, parte)
+		}
+		nome = strings.TrimSpace(parte[:i])
+		posicao = n
+	}
+
+	// Remove o prefixo de namespace, como é feito na análise do XML
+	if i := strings.LastIndex(nome, ":"); i >= 0 {
+		nome = nome[i+1:]
+	}
+
+	if nome == "" || strings.ContainsAny(nome, "@*()[]=' \"") || nome == ".." {
+		return PathStep{}, fmt.Errorf("passo '%s' não suportado: informe apenas nomes de tags", parte)
+	}
+
+	return PathStep{Name: nome, Position: posicao}, nil
+}
+
+// FindTagOccurrenceInPath localiza a tag alvo em um caminho, retornando o pai e o caminho até ela, inclusive.
+// Se a tag aparecer mais de uma vez, considera a última ocorrência; sem tag alvo, considera o último passo.
+// A posição entre os irmãos vem do predicado posicional do passo da tag alvo, se houver; predicados nos demais
+// passos são aceitos e ignorados, pois spi_mensagem_tag não distingue repetições.
+func FindTagOccurrenceInPath(path string, targetTag string) (TagOccurrence, error) {
+	steps, err := ParsePath(path)
+	if err != nil {
+		return TagOccurrence{}, err
+	}
+
+	alvo := len(steps) - 1
+	if targetTag != "" {
+		alvo = -1
+		for i := len(steps) - 1; i >= 0; i-- {
+			if steps[i].Name == targetTag {
+				alvo = i
+				break
+			}
+		}
+		if alvo == -1 {
+			return TagOccurrence{}, fmt.Errorf("tag alvo '%s' não encontrada no caminho fornecido", targetTag)
+		}
+	}
+
+	occurrence := TagOccurrence{Path: make([]string, alvo+1), SiblingIndex: 1}
+	for i := 0; i <= alvo; i++ {
+		occurrence.Path[i] = steps[i].Name
+	}
+	if alvo > 0 {
+		occurrence.Parent = occurrence.Path[alvo-1]
+	}
+	if steps[alvo].Position > 0 {
+		occurrence.SiblingIndex = steps[alvo].Position
+	}
+
+	return occurrence, nil
+}
+
+// ResolveTagOccurrences aceita tanto um trecho XML quanto um caminho ou XPath simples.
+// Para XML retorna todas as ocorrências da tag alvo; para caminhos, a única ocorrência indicada.
+func ResolveTagOccurrences(input string, targetTag string) ([]TagOccurrence, error) {
+	if IsXMLInput(input) {
+		return FindTagOccurrences(input, targetTag)
+	}
+
+	occurrence, err := FindTagOccurrenceInPath(input, targetTag)
+	if err != nil {
+		return nil, err
+	}
+	return []TagOccurrence{occurrence}, nil
+}
@@ -0,0 +1,108 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveTagOccurrences(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		targetTag   string
+		wantParent  string
+		wantSubPath []string
+		wantErr     bool
+	}{
+		{
+			name:        "Caminho separado por barra",
+			input:       "FIToFIPmtStsRpt/TxInfAndSts/TxSts",
+			targetTag:   "TxSts",
+			wantParent:  "TxInfAndSts",
+			wantSubPath: []string{"FIToFIPmtStsRpt", "TxInfAndSts", "TxSts"},
+		},
+		{
+			name:        "Caminho separado por maior que",
+			input:       "GrpHdr > MsgId",
+			targetTag:   "MsgId",
+			wantParent:  "GrpHdr",
+			wantSubPath: []string{"GrpHdr", "MsgId"},
+		},
+		{
+			name:        "XPath absoluto com predicado posicional na tag alvo",
+			input:       "/Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf/Rsn/Cd[1]",
+			targetTag:   "Cd",
+			wantParent:  "Rsn",
+			wantSubPath: []string{"Document", "FIToFIPmtStsRpt", "TxInfAndSts", "StsRsnInf", "Rsn", "Cd"},
+		},
+		{
+			name:        "XPath com namespace, descendente e text()",
+			input:       "//ns:OrgnlTxRef//ns:Id/ns:Othr/ns:Id/text()",
+			targetTag:   "Id",
+			wantParent:  "Othr",
+			wantSubPath: []string{"OrgnlTxRef", "Id", "Othr", "Id"},
+		},
+		{
+			name:        "Tag alvo no meio do caminho",
+			input:       "GrpHdr/MsgId/Extra",
+			targetTag:   "MsgId",
+			wantParent:  "GrpHdr",
+			wantSubPath: []string{"GrpHdr", "MsgId"},
+		},
+		{
+			name:        "Trecho XML",
+			input:       "<GrpHdr><MsgId>1</MsgId></GrpHdr>",
+			targetTag:   "MsgId",
+			wantParent:  "GrpHdr",
+			wantSubPath: []string{"GrpHdr", "MsgId"},
+		},
+		{
+			name:        "Predicado posicional em passo intermediário",
+			input:       "/Document/FIToFIPmtStsRpt/TxInfAndSts[2]/StsRsnInf/Rsn/Cd",
+			targetTag:   "Cd",
+			wantParent:  "Rsn",
+			wantSubPath: []string{"Document", "FIToFIPmtStsRpt", "TxInfAndSts", "StsRsnInf", "Rsn", "Cd"},
+		},
+		{
+			name:      "Predicado não suportado",
+			input:     "/Document/Rsn[@tipo='x']/Cd",
+			targetTag: "Cd",
+			wantErr:   true,
+		},
+		{
+			name:      "Tag não encontrada no caminho",
+			input:     "GrpHdr/MsgId",
+			targetTag: "TxSts",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrences, err := ResolveTagOccurrences(tt.input, tt.targetTag)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTagOccurrences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if occurrences[0].Parent != tt.wantParent {
+				t.Errorf("ResolveTagOccurrences() parent = %v, want %v", occurrences[0].Parent, tt.wantParent)
+			}
+			if !reflect.DeepEqual(occurrences[0].Path, tt.wantSubPath) {
+				t.Errorf("ResolveTagOccurrences() path = %v, want %v", occurrences[0].Path, tt.wantSubPath)
+			}
+		})
+	}
+}
+
+func TestParsePathPosicao(t *testing.T) {
+	steps, err := ParsePath("/Document/FIToFIPmtStsRpt/TxInfAndSts[2]/TxSts")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if len(steps) != 4 || steps[2] != (PathStep{Name: "TxInfAndSts", Position: 2}) || steps[3].Position != 0 {
+		t.Errorf("passos inesperados: %+v", steps)
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// accentFolding mapeia as letras acentuadas usadas em português para a letra sem acento
+var accentFolding = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n',
+}
+
+// NormalizeText converte o texto para minúsculas e remove os acentos
+func NormalizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if folded, ok := accentFolding[r]; ok {
+			return folded
+		}
+		return r
+	}, s)
+}
+
+// Tokenize separa o texto em palavras normalizadas. Além de espaços e pontuação, separa
+// as palavras de nomes em CamelCase, como os das tags ISO 20022 (TxSts -> tx, sts).
+func Tokenize(s string) []string {
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, NormalizeText(string(current)))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		// Início de palavra em CamelCase: maiúscula após minúscula, ou maiúscula seguida de minúscula
+		// após outra maiúscula (XMLData -> xml, data)
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := current[len(current)-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	return tokens
+}
+
+// EditDistance calcula a distância de Levenshtein entre dois textos, em runas
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
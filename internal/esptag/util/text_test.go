@@ -0,0 +1,42 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"Situação da Transação", []string{"situacao", "da", "transacao"}},
+		{"TxInfAndSts", []string{"tx", "inf", "and", "sts"}},
+		{"XMLData/OrgnlMsgId", []string{"xml", "data", "orgnl", "msg", "id"}},
+		{"  CÓDIGO-ISPB  ", []string{"codigo", "ispb"}},
+	}
+
+	for _, tt := range tests {
+		if got := Tokenize(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"situacao", "situacao", 0},
+		{"situacao", "sitaucao", 2},
+		{"transacao", "transacoes", 3},
+		{"", "conta", 5},
+	}
+
+	for _, tt := range tests {
+		if got := EditDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("EditDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -5,53 +5,167 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strings"
 )
 
-// Helper function to find the target tag, its parent, and a sub-path from XML using proper parsing
-func FindTagParentAndPath(xmlInput string, targetTag string) (parentTag string, subPath []string, err error) {
+// TagOccurrence representa uma ocorrência da tag alvo no XML
+type TagOccurrence struct {
+	Parent       string            // Tag pai direta (vazio na raiz)
+	Path         []string          // Caminho completo desde a raiz até a tag, inclusive
+	SiblingIndex int               // Posição da tag entre os irmãos de mesmo nome (1 = primeira)
+	Attributes   map[string]string // Atributos da tag pelo nome local, sem declarações de namespace
+	Text         string            // Conteúdo textual direto da tag, sem espaços nas extremidades
+	Offset       int64             // Posição, em bytes, do início da tag no XML
+
+	Children          []string // Nomes distintos dos filhos diretos, na ordem do documento
+	PrecedingSiblings []string // Nomes distintos dos irmãos que aparecem antes da tag, exceto o da própria tag
+	FollowingSiblings []string // Nomes distintos dos irmãos que aparecem depois da tag, exceto o da própria tag
+}
+
+// xmlFrame guarda uma tag aberta, a contagem dos filhos já encontrados por nome e a ordem desses filhos
+type xmlFrame struct {
+	name       string
+	children   map[string]int
+	childOrder []string
+	occurrence int    // Índice da ocorrência correspondente a esta tag, ou -1
+	text       string // Conteúdo textual direto da tag
+	// Ocorrências entre os filhos diretos, com a posição de cada uma em childOrder
+	childOccurrences [][2]int
+}
+
+// FindTagOccurrences percorre o XML e retorna todas as ocorrências da tag alvo, na ordem do documento
+func FindTagOccurrences(xmlInput string, targetTag string) ([]TagOccurrence, error) {
+	occurrences, err := findOccurrences(xmlInput, func(tagName string) bool { return tagName == targetTag })
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		return nil, fmt.Errorf("tag alvo '%s' não encontrada no XML fornecido", targetTag)
+	}
+	return occurrences, nil
+}
+
+// FindAllOccurrences percorre o XML e retorna as ocorrências de todas as tags, na ordem do documento
+func FindAllOccurrences(xmlInput string) ([]TagOccurrence, error) {
+	occurrences, err := findOccurrences(xmlInput, func(string) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	if len(occurrences) == 0 {
+		return nil, fmt.Errorf("nenhuma tag encontrada no XML fornecido")
+	}
+	return occurrences, nil
+}
+
+// findOccurrences percorre o XML e retorna as ocorrências das tags aceitas por match, na ordem do documento
+func findOccurrences(xmlInput string, match func(tagName string) bool) ([]TagOccurrence, error) {
 	decoder := xml.NewDecoder(bytes.NewReader([]byte(xmlInput)))
-	var stack []string // Stack para rastrear o caminho atual
-	found := false
+	stack := []xmlFrame{{children: map[string]int{}, occurrence: -1}} // Frame raiz virtual, para contar os elementos de topo
+	var occurrences []TagOccurrence
 
 	for {
+		offset := decoder.InputOffset()
 		token, tokenErr := decoder.Token()
 		if tokenErr == io.EOF {
 			break
 		}
 		if tokenErr != nil {
-			return "", nil, fmt.Errorf("erro ao analisar token XML: %v", tokenErr)
+			return nil, fmt.Errorf("erro ao analisar token XML: %v", tokenErr)
 		}
 
 		switch se := token.(type) {
 		case xml.StartElement:
 			// Usa o nome Local para ignorar prefixos de namespace
 			tagName := se.Name.Local
-			stack = append(stack, tagName) // Adiciona tag ao stack
+			parent := &stack[len(stack)-1]
+			parent.children[tagName]++
+			parent.childOrder = append(parent.childOrder, tagName)
+			siblingIndex := parent.children[tagName]
 
-			if tagName == targetTag && !found {
-				found = true
+			frame := xmlFrame{name: tagName, children: map[string]int{}, occurrence: -1}
 
-				// Define a tag pai (se existir)
-				if len(stack) > 1 {
-					parentTag = stack[len(stack)-2]
-				}
+			if match(tagName) {
+				frame.occurrence = len(occurrences)
+				parent.childOccurrences = append(parent.childOccurrences, [2]int{len(occurrences), len(parent.childOrder) - 1})
 
-				// Para o subPath, queremos o caminho completo desde a raiz até a tag alvo
-				// inclusive a própria tag alvo
-				subPath = make([]string, len(stack))
-				copy(subPath, stack)
+				occurrence := TagOccurrence{
+					Path:         make([]string, 0, len(stack)),
+					SiblingIndex: siblingIndex,
+					Offset:       offset,
+				}
+				for _, f := range stack[1:] {
+					occurrence.Path = append(occurrence.Path, f.name)
+				}
+				occurrence.Path = append(occurrence.Path, tagName)
+				if len(occurrence.Path) > 1 {
+					occurrence.Parent = occurrence.Path[len(occurrence.Path)-2]
+				}
+				for _, attr := range se.Attr {
+					if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+						continue
+					}
+					if occurrence.Attributes == nil {
+						occurrence.Attributes = make(map[string]string)
+					}
+					occurrence.Attributes[attr.Name.Local] = attr.Value
+				}
+				occurrences = append(occurrences, occurrence)
 			}
 
+			stack = append(stack, frame)
+
+		case xml.CharData:
+			stack[len(stack)-1].text += string(se)
+
 		case xml.EndElement:
-			if len(stack) > 0 {
+			if len(stack) > 1 {
+				closeFrame(stack[len(stack)-1], occurrences)
 				stack = stack[:len(stack)-1] // Remove tag do stack
 			}
 		}
 	}
 
-	if !found {
-		return "", nil, fmt.Errorf("tag alvo '%s' não encontrada no XML fornecido", targetTag)
+	// Fecha o frame raiz virtual, completando os irmãos dos elementos de topo
+	closeFrame(stack[0], occurrences)
+
+	return occurrences, nil
+}
+
+// closeFrame completa, ao fim de uma tag, os filhos da ocorrência correspondente e os irmãos das ocorrências filhas
+func closeFrame(frame xmlFrame, occurrences []TagOccurrence) {
+	if frame.occurrence >= 0 {
+		occurrences[frame.occurrence].Children = distinctNames(frame.childOrder, "")
+		occurrences[frame.occurrence].Text = strings.TrimSpace(frame.text)
+	}
+	for _, child := range frame.childOccurrences {
+		occurrence := &occurrences[child[0]]
+		name := frame.childOrder[child[1]]
+		occurrence.PrecedingSiblings = distinctNames(frame.childOrder[:child[1]], name)
+		occurrence.FollowingSiblings = distinctNames(frame.childOrder[child[1]+1:], name)
+	}
+}
+
+// distinctNames retorna os nomes sem repetição, na ordem em que aparecem, ignorando o nome informado
+func distinctNames(names []string, ignore string) []string {
+	seen := make(map[string]bool)
+	var distinct []string
+	for _, name := range names {
+		if name == ignore || seen[name] {
+			continue
+		}
+		seen[name] = true
+		distinct = append(distinct, name)
+	}
+	return distinct
+}
+
+// Helper function to find the target tag, its parent, and a sub-path from XML using proper parsing.
+// Considera apenas a primeira ocorrência da tag; use FindTagOccurrences para obter todas.
+func FindTagParentAndPath(xmlInput string, targetTag string) (parentTag string, subPath []string, err error) {
+	occurrences, err := FindTagOccurrences(xmlInput, targetTag)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return parentTag, subPath, nil
+	return occurrences[0].Parent, occurrences[0].Path, nil
 }
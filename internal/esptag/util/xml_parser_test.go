@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -86,3 +87,88 @@ func TestFindTagParentAndPath(t *testing.T) {
 		})
 	}
 }
+
+func TestFindTagOccurrences(t *testing.T) {
+	xmlInput := `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10">
+		<FIToFIPmtStsRpt>
+			<GrpHdr><MsgId>M1</MsgId></GrpHdr>
+			<TxInfAndSts>
+				<StsRsnInf><Rsn><Cd>AB03</Cd></Rsn></StsRsnInf>
+			</TxInfAndSts>
+			<TxInfAndSts>
+				<StsRsnInf><Rsn><Cd>AM04</Cd></Rsn></StsRsnInf>
+				<StsRsnInf><Rsn><Cd Prtry="x">RR04</Cd></Rsn></StsRsnInf>
+			</TxInfAndSts>
+		</FIToFIPmtStsRpt>
+	</Document>`
+
+	occurrences, err := FindTagOccurrences(xmlInput, "StsRsnInf")
+	if err != nil {
+		t.Fatalf("FindTagOccurrences() error = %v", err)
+	}
+	if len(occurrences) != 3 {
+		t.Fatalf("FindTagOccurrences() = %d ocorrências, want 3", len(occurrences))
+	}
+	if occurrences[0].SiblingIndex != 1 || occurrences[1].SiblingIndex != 1 || occurrences[2].SiblingIndex != 2 {
+		t.Errorf("FindTagOccurrences() sibling indexes = %d, %d, %d, want 1, 1, 2",
+			occurrences[0].SiblingIndex, occurrences[1].SiblingIndex, occurrences[2].SiblingIndex)
+	}
+	if occurrences[0].Parent != "TxInfAndSts" || len(occurrences[2].Path) != 4 {
+		t.Errorf("FindTagOccurrences() ocorrência inesperada: %+v", occurrences[2])
+	}
+
+	if got := occurrences[2].PrecedingSiblings; len(got) != 0 {
+		t.Errorf("FindTagOccurrences() preceding siblings = %v, want vazio (mesmo nome)", got)
+	}
+	if got := occurrences[0].Children; len(got) != 1 || got[0] != "Rsn" {
+		t.Errorf("FindTagOccurrences() children = %v, want [Rsn]", got)
+	}
+
+	msgIds, err := FindTagOccurrences(xmlInput, "GrpHdr")
+	if err != nil {
+		t.Fatalf("FindTagOccurrences() error = %v", err)
+	}
+	if got := msgIds[0].FollowingSiblings; len(got) != 1 || got[0] != "TxInfAndSts" {
+		t.Errorf("FindTagOccurrences() following siblings = %v, want [TxInfAndSts]", got)
+	}
+
+	cds, err := FindTagOccurrences(xmlInput, "Cd")
+	if err != nil {
+		t.Fatalf("FindTagOccurrences() error = %v", err)
+	}
+	if len(cds) != 3 || cds[2].Attributes["Prtry"] != "x" || cds[0].Attributes != nil {
+		t.Errorf("FindTagOccurrences() atributos inesperados: %+v", cds)
+	}
+
+	documents, _ := FindTagOccurrences(xmlInput, "Document")
+	if len(documents) != 1 || documents[0].Attributes != nil {
+		t.Errorf("FindTagOccurrences() não deveria retornar declarações de namespace: %+v", documents)
+	}
+}
+
+func TestFindAllOccurrences(t *testing.T) {
+	xmlInput := "<Document>\n  <GrpHdr><MsgId> M1 </MsgId></GrpHdr>\n  <TxSts>ACSC</TxSts>\n</Document>"
+
+	occurrences, err := FindAllOccurrences(xmlInput)
+	if err != nil {
+		t.Fatalf("FindAllOccurrences() error = %v", err)
+	}
+
+	var names []string
+	for _, o := range occurrences {
+		names = append(names, o.Path[len(o.Path)-1])
+		if !strings.HasPrefix(xmlInput[o.Offset:], "<"+o.Path[len(o.Path)-1]+">") {
+			t.Errorf("FindAllOccurrences() offset %d de %s não aponta para a abertura da tag", o.Offset, o.Path[len(o.Path)-1])
+		}
+	}
+	if got := strings.Join(names, ","); got != "Document,GrpHdr,MsgId,TxSts" {
+		t.Errorf("FindAllOccurrences() = %s, want Document,GrpHdr,MsgId,TxSts", got)
+	}
+	if occurrences[2].Text != "M1" || occurrences[3].Text != "ACSC" || occurrences[1].Text != "" {
+		t.Errorf("FindAllOccurrences() textos = %q, %q, %q", occurrences[1].Text, occurrences[2].Text, occurrences[3].Text)
+	}
+
+	if _, err := FindAllOccurrences("texto sem tags"); err == nil {
+		t.Error("FindAllOccurrences() sem tags deveria retornar erro")
+	}
+}
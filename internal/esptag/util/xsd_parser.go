@@ -0,0 +1,324 @@
+package util
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// XSDNode é um elemento da árvore de uma mensagem, obtida pela expansão dos tipos complexos do XSD
+type XSDNode struct {
+	Name      string
+	Type      string
+	MinOccurs int
+	MaxOccurs int  // -1 para unbounded
+	Choice    bool // O elemento é uma das alternativas de um xs:choice
+	Children  []*XSDNode
+}
+
+// Walk percorre a árvore em pré-ordem (ordem do documento), informando o pai e a profundidade de cada nó
+func (n *XSDNode) Walk(fn func(node, parent *XSDNode, depth int)) {
+	var walk func(node, parent *XSDNode, depth int)
+	walk = func(node, parent *XSDNode, depth int) {
+		fn(node, parent, depth)
+		for _, child := range node.Children {
+			walk(child, node, depth+1)
+		}
+	}
+	walk(n, nil, 0)
+}
+
+// XSDSchema guarda os elementos globais e os tipos complexos de um XSD
+type XSDSchema struct {
+	TargetNamespace string
+	elements        map[string]*xsdElement
+	complexTypes    map[string]*xsdComplexType
+}
+
+// MessageID retorna o identificador da mensagem contido no targetNamespace ISO 20022, se houver
+func (s *XSDSchema) MessageID() string {
+	if strings.HasPrefix(s.TargetNamespace, ISO20022NamespacePrefix) {
+		return strings.TrimPrefix(s.TargetNamespace, ISO20022NamespacePrefix)
+	}
+	return ""
+}
+
+// xsdSchema, xsdElement, xsdComplexType e xsdGroup espelham a parte do XML Schema usada nas mensagens ISO 20022
+type xsdSchema struct {
+	TargetNamespace string           `xml:"targetNamespace,attr"`
+	Elements        []xsdElement     `xml:"element"`
+	ComplexTypes    []xsdComplexType `xml:"complexType"`
+}
+
+type xsdElement struct {
+	Name        string          `xml:"name,attr"`
+	Type        string          `xml:"type,attr"`
+	Ref         string          `xml:"ref,attr"`
+	MinOccurs   string          `xml:"minOccurs,attr"`
+	MaxOccurs   string          `xml:"maxOccurs,attr"`
+	ComplexType *xsdComplexType `xml:"complexType"`
+}
+
+type xsdComplexType struct {
+	Name           string `xml:"name,attr"`
+	Sequence       *xsdGroup
+	Choice         *xsdGroup
+	All            *xsdGroup
+	ComplexContent *xsdExtension // Base e conteúdo de complexContent/extension
+}
+
+type xsdExtension struct {
+	Base  string
+	Group *xsdGroup
+}
+
+// xsdParticle é um item de um grupo: um elemento ou um grupo aninhado
+type xsdParticle struct {
+	Element *xsdElement
+	Group   *xsdGroup
+}
+
+type xsdGroup struct {
+	Choice bool
+	Items  []xsdParticle
+}
+
+// UnmarshalXML lê o tipo complexo preservando o tipo de grupo (sequence, choice, all) e as extensões
+func (t *xsdComplexType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "name" {
+			t.Name = attr.Value
+		}
+	}
+
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "sequence", "choice", "all":
+				group, err := decodeGroup(d, tok)
+				if err != nil {
+					return err
+				}
+				switch tok.Name.Local {
+				case "sequence":
+					t.Sequence = group
+				case "choice":
+					t.Choice = group
+				default:
+					t.All = group
+				}
+			case "complexContent":
+				// O conteúdo é lido na extensão interna; a restrição é tratada como o tipo base
+				continue
+			case "extension", "restriction":
+				extension := &xsdExtension{}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "base" {
+						extension.Base = attr.Value
+					}
+				}
+				inner := &xsdComplexType{}
+				if err := inner.UnmarshalXML(d, tok); err != nil {
+					return err
+				}
+				extension.Group = inner.group()
+				t.ComplexContent = extension
+			default:
+				// simpleContent, attribute, annotation: não geram elementos filhos
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			if tok.Name.Local == start.Name.Local {
+				return nil
+			}
+		}
+	}
+}
+
+// group retorna o grupo de conteúdo do tipo, qualquer que seja a sua espécie
+func (t *xsdComplexType) group() *xsdGroup {
+	switch {
+	case t.Sequence != nil:
+		return t.Sequence
+	case t.Choice != nil:
+		return t.Choice
+	default:
+		return t.All
+	}
+}
+
+// decodeGroup lê um xs:sequence, xs:choice ou xs:all mantendo a ordem entre elementos e grupos aninhados
+func decodeGroup(d *xml.Decoder, start xml.StartElement) (*xsdGroup, error) {
+	group := &xsdGroup{Choice: start.Name.Local == "choice"}
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "element":
+				var element xsdElement
+				if err := d.DecodeElement(&element, &tok); err != nil {
+					return nil, err
+				}
+				group.Items = append(group.Items, xsdParticle{Element: &element})
+			case "sequence", "choice", "all":
+				nested, err := decodeGroup(d, tok)
+				if err != nil {
+					return nil, err
+				}
+				group.Items = append(group.Items, xsdParticle{Group: nested})
+			default:
+				// xs:any e anotações não geram elementos nomeados
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			return group, nil
+		}
+	}
+}
+
+// ParseXSD lê um XSD e indexa os seus elementos globais e tipos complexos nomeados
+func ParseXSD(data []byte) (*XSDSchema, error) {
+	var raw xsdSchema
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("erro ao analisar XSD: %v", err)
+	}
+
+	schema := &XSDSchema{
+		TargetNamespace: raw.TargetNamespace,
+		elements:        make(map[string]*xsdElement),
+		complexTypes:    make(map[string]*xsdComplexType),
+	}
+	for i := range raw.Elements {
+		schema.elements[raw.Elements[i].Name] = &raw.Elements[i]
+	}
+	for i := range raw.ComplexTypes {
+		schema.complexTypes[raw.ComplexTypes[i].Name] = &raw.ComplexTypes[i]
+	}
+
+	if len(schema.elements) == 0 {
+		return nil, fmt.Errorf("o XSD não possui elementos globais")
+	}
+	return schema, nil
+}
+
+// Expand expande o elemento global informado na árvore de elementos da mensagem, resolvendo tipos
+// complexos, extensões e grupos aninhados. Tipos recursivos são expandidos uma única vez por ramo;
+// os pontos em que a expansão foi interrompida são retornados como avisos.
+func (s *XSDSchema) Expand(root string) (*XSDNode, []string, error) {
+	element, ok := s.elements[root]
+	if !ok {
+		return nil, nil, fmt.Errorf("elemento global '%s' não encontrado no XSD", root)
+	}
+
+	var warnings []string
+	var expandType func(node *XSDNode, typeName string, inline *xsdComplexType, stack []string)
+	var expandGroup func(node *XSDNode, group *xsdGroup, stack []string)
+
+	newNode := func(e *xsdElement, choice bool) *XSDNode {
+		name := e.Name
+		if name == "" {
+			name = localName(e.Ref)
+		}
+		return &XSDNode{
+			Name:      name,
+			Type:      localName(e.Type),
+			MinOccurs: parseOccurs(e.MinOccurs),
+			MaxOccurs: parseOccurs(e.MaxOccurs),
+			Choice:    choice,
+		}
+	}
+
+	expandElement := func(parent *XSDNode, e *xsdElement, choice bool, stack []string) {
+		if e.Ref != "" {
+			if global, ok := s.elements[localName(e.Ref)]; ok {
+				referenced := *global
+				referenced.MinOccurs, referenced.MaxOccurs = e.MinOccurs, e.MaxOccurs
+				e = &referenced
+			}
+		}
+		child := newNode(e, choice)
+		parent.Children = append(parent.Children, child)
+		expandType(child, child.Type, e.ComplexType, stack)
+	}
+
+	expandGroup = func(node *XSDNode, group *xsdGroup, stack []string) {
+		if group == nil {
+			return
+		}
+		for _, item := range group.Items {
+			if item.Element != nil {
+				expandElement(node, item.Element, group.Choice, stack)
+			} else {
+				expandGroup(node, item.Group, stack)
+			}
+		}
+	}
+
+	expandType = func(node *XSDNode, typeName string, inline *xsdComplexType, stack []string) {
+		complexType := inline
+		if complexType == nil {
+			complexType = s.complexTypes[typeName]
+		}
+		if complexType == nil {
+			// Tipo simples ou embutido do XML Schema: elemento folha
+			return
+		}
+		if typeName != "" {
+			for _, t := range stack {
+				if t == typeName {
+					warnings = append(warnings, fmt.Sprintf("tipo recursivo '%s' não expandido novamente em '%s'", typeName, node.Name))
+					return
+				}
+			}
+			stack = append(stack, typeName)
+		}
+
+		if complexType.ComplexContent != nil {
+			expandType(node, localName(complexType.ComplexContent.Base), nil, stack)
+			expandGroup(node, complexType.ComplexContent.Group, stack)
+		}
+		expandGroup(node, complexType.group(), stack)
+	}
+
+	rootNode := newNode(element, false)
+	expandType(rootNode, rootNode.Type, element.ComplexType, nil)
+
+	return rootNode, warnings, nil
+}
+
+// localName remove o prefixo de namespace de um nome qualificado (xs:string -> string)
+func localName(qualified string) string {
+	if i := strings.LastIndex(qualified, ":"); i >= 0 {
+		return qualified[i+1:]
+	}
+	return qualified
+}
+
+// parseOccurs converte minOccurs/maxOccurs, cujo padrão é 1; unbounded é representado por -1
+func parseOccurs(value string) int {
+	if value == "" {
+		return 1
+	}
+	if value == "unbounded" {
+		return -1
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+// xsdTeste é um pacs.002 reduzido com sequence, choice, extensão, simpleContent, xs:any e um tipo recursivo
+const xsdTeste = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.12"
+	targetNamespace="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.12" elementFormDefault="qualified">
+	<xs:element name="Document" type="Document"/>
+	<xs:complexType name="Document">
+		<xs:sequence>
+			<xs:element name="FIToFIPmtStsRpt" type="FIToFIPaymentStatusReportV12"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="FIToFIPaymentStatusReportV12">
+		<xs:sequence>
+			<xs:element name="GrpHdr" type="GroupHeader"/>
+			<xs:element maxOccurs="unbounded" minOccurs="0" name="TxInfAndSts" type="PaymentTransaction"/>
+			<xs:element maxOccurs="unbounded" minOccurs="0" name="SplmtryData" type="SupplementaryData"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="GroupHeader">
+		<xs:sequence>
+			<xs:element name="MsgId" type="Max35Text"/>
+			<xs:element name="CreDtTm" type="ISODateTime"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="PaymentTransaction">
+		<xs:sequence>
+			<xs:element minOccurs="0" name="TxSts" type="ExternalPaymentTransactionStatus1Code"/>
+			<xs:element minOccurs="0" name="StsRsnInf" type="StatusReasonInformation"/>
+			<xs:element minOccurs="0" name="IntrBkSttlmAmt" type="ActiveCurrencyAndAmount"/>
+			<xs:element minOccurs="0" name="Prty" type="Party"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="StatusReasonInformation">
+		<xs:sequence>
+			<xs:element minOccurs="0" name="Rsn" type="StatusReason6Choice"/>
+			<xs:element maxOccurs="unbounded" minOccurs="0" name="AddtlInf" type="Max105Text"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="StatusReason6Choice">
+		<xs:choice>
+			<xs:element name="Cd" type="ExternalStatusReason1Code"/>
+			<xs:element name="Prtry" type="Max35Text"/>
+		</xs:choice>
+	</xs:complexType>
+	<xs:complexType name="ActiveCurrencyAndAmount">
+		<xs:simpleContent>
+			<xs:extension base="ActiveCurrencyAndAmount_SimpleType">
+				<xs:attribute name="Ccy" type="ActiveCurrencyCode" use="required"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:complexType name="BaseParty">
+		<xs:sequence>
+			<xs:element minOccurs="0" name="Nm" type="Max140Text"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Party">
+		<xs:complexContent>
+			<xs:extension base="BaseParty">
+				<xs:sequence>
+					<xs:element minOccurs="0" name="Ultmt" type="Party"/>
+				</xs:sequence>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="SupplementaryData">
+		<xs:sequence>
+			<xs:element name="Envlp">
+				<xs:complexType>
+					<xs:sequence>
+						<xs:any namespace="##any" processContents="lax"/>
+					</xs:sequence>
+				</xs:complexType>
+			</xs:element>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:simpleType name="Max35Text">
+		<xs:restriction base="xs:string"/>
+	</xs:simpleType>
+</xs:schema>`
+
+func TestExpandXSD(t *testing.T) {
+	schema, err := ParseXSD([]byte(xsdTeste))
+	if err != nil {
+		t.Fatalf("ParseXSD() error = %v", err)
+	}
+	if schema.MessageID() != "pacs.002.001.12" {
+		t.Errorf("MessageID() = %q, want pacs.002.001.12", schema.MessageID())
+	}
+
+	root, warnings, err := schema.Expand("Document")
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	var paths []string
+	var stack []string
+	root.Walk(func(node, parent *XSDNode, depth int) {
+		stack = append(stack[:depth], node.Name)
+		paths = append(paths, strings.Join(stack, "/"))
+	})
+
+	want := []string{
+		"Document",
+		"Document/FIToFIPmtStsRpt",
+		"Document/FIToFIPmtStsRpt/GrpHdr",
+		"Document/FIToFIPmtStsRpt/GrpHdr/MsgId",
+		"Document/FIToFIPmtStsRpt/GrpHdr/CreDtTm",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/TxSts",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf/Rsn",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf/Rsn/Cd",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf/Rsn/Prtry",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/StsRsnInf/AddtlInf",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/IntrBkSttlmAmt",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/Prty",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/Prty/Nm",
+		"Document/FIToFIPmtStsRpt/TxInfAndSts/Prty/Ultmt",
+		"Document/FIToFIPmtStsRpt/SplmtryData",
+		"Document/FIToFIPmtStsRpt/SplmtryData/Envlp",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expand() paths =\n%s\nwant\n%s", strings.Join(paths, "\n"), strings.Join(want, "\n"))
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "'Party'") {
+		t.Errorf("Expand() warnings = %v, want um aviso do tipo recursivo Party", warnings)
+	}
+
+	txInf := root.Children[0].Children[1]
+	if txInf.MinOccurs != 0 || txInf.MaxOccurs != -1 {
+		t.Errorf("TxInfAndSts occurs = %d..%d, want 0..-1", txInf.MinOccurs, txInf.MaxOccurs)
+	}
+	cd := txInf.Children[1].Children[0].Children[0]
+	if !cd.Choice || cd.Type != "ExternalStatusReason1Code" {
+		t.Errorf("Cd = %+v, want alternativa de choice do tipo ExternalStatusReason1Code", cd)
+	}
+}
+
+func TestExpandXSDErrors(t *testing.T) {
+	if _, err := ParseXSD([]byte(`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"/>`)); err == nil {
+		t.Error("ParseXSD() sem elementos globais deveria falhar")
+	}
+	if _, err := ParseXSD([]byte(`<xs:schema`)); err == nil {
+		t.Error("ParseXSD() com XML inválido deveria falhar")
+	}
+
+	schema, err := ParseXSD([]byte(xsdTeste))
+	if err != nil {
+		t.Fatalf("ParseXSD() error = %v", err)
+	}
+	if _, _, err := schema.Expand("AppHdr"); err == nil {
+		t.Error("Expand() de elemento inexistente deveria falhar")
+	}
+}
@@ -0,0 +1,405 @@
+package esptag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// ValidaMensagemXSDArgs define os argumentos de entrada para o MCP
+type ValidaMensagemXSDArgs struct {
+	IDEveMensagem     string `json:"id_eve_msg" jsonschema:"required,description=ID do evento da mensagem cadastrada em spi_mensagem_tag (ex: pacs.002.001.10)"`
+	ArquivoXSD        string `json:"arquivo_xsd" jsonschema:"required,description=Caminho local do XSD oficial da mensagem (ex: xsd/pacs.002.001.10.xsd)"`
+	ElementoRaiz      string `json:"elemento_raiz" jsonschema:"description=Elemento global do XSD que é a raiz da mensagem (padrão: Document)"`
+	GerarScript       bool   `json:"gerar_script" jsonschema:"description=Se verdadeiro, gera o script que corrige os pais incorretos e inclui as tags ausentes"`
+	RemoverExcedentes bool   `json:"remover_excedentes" jsonschema:"description=Se verdadeiro, o script também remove as tags excedentes que não possuem vínculos"`
+}
+
+// TipoDivergenciaXSD classifica a diferença entre um registro de spi_mensagem_tag e o XSD
+type TipoDivergenciaXSD string
+
+const (
+	DivergenciaAusente      TipoDivergenciaXSD = "ausente"       // Elemento do XSD sem registro na mensagem
+	DivergenciaExcedente    TipoDivergenciaXSD = "excedente"     // Registro sem elemento correspondente no XSD
+	DivergenciaPaiIncorreto TipoDivergenciaXSD = "pai_incorreto" // Registro com id_tag_pai diferente do pai no XSD
+	DivergenciaOrdem        TipoDivergenciaXSD = "ordem"         // Registro fora da ordem dos elementos no XSD
+)
+
+// DivergenciaXSD é uma diferença entre a estrutura cadastrada de uma mensagem e o XSD
+type DivergenciaXSD struct {
+	Tipo             TipoDivergenciaXSD
+	Tag              MensagemTagInfo // Registro cadastrado; para tags ausentes, o registro proposto
+	Caminho          []string        // Caminho do registro cadastrado (ou do elemento no XSD, para tags ausentes)
+	CaminhoEsperado  []string        // Caminho do elemento correspondente no XSD
+	IDTagPaiEsperado string          // id_tag_pai do elemento no XSD (pai incorreto)
+	Vinculada        bool            // O registro possui vínculos em spi_especializacao_msg_tag (excedentes)
+}
+
+// ValidacaoXSD é o resultado da comparação da estrutura cadastrada de uma mensagem com o XSD
+type ValidacaoXSD struct {
+	IDEveMensagem string
+	Conferidas    int // Registros no mesmo caminho do XSD
+	Divergencias  []DivergenciaXSD
+}
+
+// Filtrar retorna as divergências do tipo informado
+func (v *ValidacaoXSD) Filtrar(tipo TipoDivergenciaXSD) []DivergenciaXSD {
+	var filtradas []DivergenciaXSD
+	for _, d := range v.Divergencias {
+		if d.Tipo == tipo {
+			filtradas = append(filtradas, d)
+		}
+	}
+	return filtradas
+}
+
+// RemoviveisExcedentes retorna as tags excedentes sem vínculos, que podem ser removidas pelo script
+func (v *ValidacaoXSD) RemoviveisExcedentes() []DivergenciaXSD {
+	var removiveis []DivergenciaXSD
+	for _, d := range v.Filtrar(DivergenciaExcedente) {
+		if !d.Vinculada {
+			removiveis = append(removiveis, d)
+		}
+	}
+	return removiveis
+}
+
+// elementoEsperadoXSD é um elemento da árvore expandida do XSD com o seu caminho
+type elementoEsperadoXSD struct {
+	tag     MensagemTagInfo
+	caminho []string
+	casado  bool
+}
+
+// ValidarMensagemXSD compara os registros de spi_mensagem_tag de uma mensagem com a árvore expandida do XSD.
+//
+// Os registros são casados pelo caminho, na ordem de ocorrência entre caminhos repetidos, como na migração
+// de versões. Um registro sem correspondente é tratado como pai incorreto quando há um elemento de mesmo
+// nome ainda não casado sob outro pai; os seus descendentes são então comparados pelo caminho corrigido.
+// Os demais registros são excedentes, e os elementos do XSD que sobram, ausentes. Entre os registros
+// casados, as diferenças de ordem são as do menor conjunto de registros fora da sequência do XSD.
+//
+// As tags ausentes recebem num_seq_msg_tag a partir do próximo disponível e num_seq_tag pela mesma convenção
+// da importação (NumeracaoNumSeqTag); os num_seq_tag já cadastrados são mantidos, pois são referenciados
+// pelos vínculos.
+func ValidarMensagemXSD(cat Catalog, idEveMensagem string, raiz *util.XSDNode) (*ValidacaoXSD, error) {
+	arvore, err := cat.ObterArvoreMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	cadastradas := arvore.Tags()
+
+	vinculos, err := cat.ListarVinculosMensagem(idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	vinculadas := make(map[int]bool)
+	for _, v := range vinculos {
+		vinculadas[v.NumSeqMsgTag] = true
+	}
+
+	idTipMensagem := ""
+	if len(cadastradas) > 0 {
+		idTipMensagem = cadastradas[0].IDTipMensagem
+	}
+
+	// Bases cadastradas sem o elemento raiz (Document) são comparadas a partir dos seus filhos
+	comRaiz := len(arvore.BuscarTag(raiz.Name)) > 0
+
+	var esperados []*elementoEsperadoXSD
+	indiceEsperados := make(map[string]int)
+	porNome := make(map[string][]int)
+	contagem := make(map[string]int)
+	var pilha []string
+	raiz.Walk(func(node, parent *util.XSDNode, depth int) {
+		pilha = append(pilha[:depth], node.Name)
+		caminho := pilha
+		if !comRaiz {
+			if depth == 0 {
+				return
+			}
+			caminho = pilha[1:]
+		}
+
+		e := &elementoEsperadoXSD{
+			tag: MensagemTagInfo{
+				IDEveMensagem: idEveMensagem,
+				IDTipMensagem: idTipMensagem,
+				IDTag:         node.Name,
+			},
+			caminho: append([]string(nil), caminho...),
+		}
+		if len(caminho) > 1 {
+			e.tag.IDTagPai = parent.Name
+		}
+
+		chave := strings.Join(e.caminho, "/")
+		indiceEsperados[fmt.Sprintf("%s#%d", chave, contagem[chave])] = len(esperados)
+		contagem[chave]++
+		porNome[node.Name] = append(porNome[node.Name], len(esperados))
+		esperados = append(esperados, e)
+	})
+
+	validacao := &ValidacaoXSD{IDEveMensagem: idEveMensagem}
+
+	// Casamento dos registros cadastrados, em ordem de num_seq_tag, pelo caminho corrigido do pai
+	corrigidos := make(map[int][]string)
+	casamentos := make(map[int]int) // posição do elemento no XSD -> posição do registro cadastrado
+	var ordemCasados []int          // posições no XSD dos registros casados, na ordem cadastrada
+	contagemCadastrada := make(map[string]int)
+	posicaoEsperada := 0 // posição no XSD seguinte à do último registro casado
+	for i, tag := range cadastradas {
+		var base []string
+		if pai, ok := arvore.Pai(tag.NumSeqMsgTag); ok {
+			base, ok = corrigidos[pai.NumSeqMsgTag]
+			if !ok {
+				base = arvore.Caminho(pai.NumSeqMsgTag)
+			}
+		} else if tag.IDTagPai != "" {
+			base = []string{tag.IDTagPai}
+		}
+		caminho := append(append([]string(nil), base...), tag.IDTag)
+
+		chave := strings.Join(caminho, "/")
+		ordem := contagemCadastrada[chave]
+		contagemCadastrada[chave]++
+
+		if j, ok := indiceEsperados[fmt.Sprintf("%s#%d", chave, ordem)]; ok && !esperados[j].casado {
+			esperados[j].casado = true
+			casamentos[j] = i
+			ordemCasados = append(ordemCasados, j)
+			posicaoEsperada = j + 1
+			corrigidos[tag.NumSeqMsgTag] = caminho
+			validacao.Conferidas++
+			continue
+		}
+
+		if j := candidatoPaiIncorreto(esperados, porNome[tag.IDTag], tag, caminho, posicaoEsperada); j != -1 {
+			esperados[j].casado = true
+			casamentos[j] = i
+			ordemCasados = append(ordemCasados, j)
+			posicaoEsperada = j + 1
+			corrigidos[tag.NumSeqMsgTag] = esperados[j].caminho
+			validacao.Divergencias = append(validacao.Divergencias, DivergenciaXSD{
+				Tipo:             DivergenciaPaiIncorreto,
+				Tag:              tag,
+				Caminho:          arvore.Caminho(tag.NumSeqMsgTag),
+				CaminhoEsperado:  esperados[j].caminho,
+				IDTagPaiEsperado: esperados[j].tag.IDTagPai,
+			})
+			continue
+		}
+
+		corrigidos[tag.NumSeqMsgTag] = caminho
+		validacao.Divergencias = append(validacao.Divergencias, DivergenciaXSD{
+			Tipo:      DivergenciaExcedente,
+			Tag:       tag,
+			Caminho:   arvore.Caminho(tag.NumSeqMsgTag),
+			Vinculada: vinculadas[tag.NumSeqMsgTag],
+		})
+	}
+
+	// Diferenças de ordem: registros casados fora da maior subsequência crescente de posições do XSD
+	naSequencia := subsequenciaCrescente(ordemCasados)
+	for k, j := range ordemCasados {
+		if naSequencia[k] {
+			continue
+		}
+		tag := cadastradas[casamentos[j]]
+		validacao.Divergencias = append(validacao.Divergencias, DivergenciaXSD{
+			Tipo:            DivergenciaOrdem,
+			Tag:             tag,
+			Caminho:         arvore.Caminho(tag.NumSeqMsgTag),
+			CaminhoEsperado: esperados[j].caminho,
+		})
+	}
+
+	// Tags ausentes, numeradas na ordem do XSD com a mesma convenção da importação
+	proximo, err := cat.ObterProximoNumSeqMsgTag()
+	if err != nil {
+		return nil, err
+	}
+	numeracao, err := NovaNumeracaoNumSeqTag(cat, idEveMensagem)
+	if err != nil {
+		return nil, err
+	}
+	for j, e := range esperados {
+		if i, ok := casamentos[j]; ok {
+			numeracao.Registrar(e.caminho, cadastradas[i].NumSeqTag)
+			continue
+		}
+		tag := e.tag
+		tag.NumSeqTag = numeracao.Numerar(e.caminho)
+		tag.NumSeqMsgTag = proximo
+		proximo++
+		validacao.Divergencias = append(validacao.Divergencias, DivergenciaXSD{
+			Tipo:    DivergenciaAusente,
+			Tag:     tag,
+			Caminho: e.caminho,
+		})
+	}
+
+	return validacao, nil
+}
+
+// candidatoPaiIncorreto escolhe, entre os elementos do XSD com o nome da tag ainda não casados e sob outro pai,
+// o de maior prefixo de caminho em comum com o registro e, no empate, o mais próximo da posição atual
+func candidatoPaiIncorreto(esperados []*elementoEsperadoXSD, candidatos []int, tag MensagemTagInfo, caminho []string, posicao int) int {
+	melhor, melhorPrefixo, melhorDistancia := -1, -1, 0
+	for _, j := range candidatos {
+		e := esperados[j]
+		if e.casado || e.tag.IDTagPai == tag.IDTagPai {
+			continue
+		}
+
+		prefixo := 0
+		for prefixo < len(e.caminho) && prefixo < len(caminho) && e.caminho[prefixo] == caminho[prefixo] {
+			prefixo++
+		}
+		distancia := j - posicao
+		if distancia < 0 {
+			distancia = -distancia
+		}
+
+		if prefixo > melhorPrefixo || (prefixo == melhorPrefixo && distancia < melhorDistancia) {
+			melhor, melhorPrefixo, melhorDistancia = j, prefixo, distancia
+		}
+	}
+	return melhor
+}
+
+// subsequenciaCrescente marca os itens de uma das maiores subsequências estritamente crescentes
+func subsequenciaCrescente(valores []int) []bool {
+	var finais []int // finais[k] é a posição do menor final de uma subsequência de tamanho k+1
+	anteriores := make([]int, len(valores))
+	for i, v := range valores {
+		k := sort.Search(len(finais), func(k int) bool { return valores[finais[k]] >= v })
+		anteriores[i] = -1
+		if k > 0 {
+			anteriores[i] = finais[k-1]
+		}
+		if k == len(finais) {
+			finais = append(finais, i)
+		} else {
+			finais[k] = i
+		}
+	}
+
+	marcados := make([]bool, len(valores))
+	if len(finais) > 0 {
+		for i := finais[len(finais)-1]; i != -1; i = anteriores[i] {
+			marcados[i] = true
+		}
+	}
+	return marcados
+}
+
+// GeraScriptCorrecaoXSD gera, em uma única transação, a correção dos pais incorretos e a inclusão das
+// tags ausentes e, se removerExcedentes for informado, a remoção das tags excedentes sem vínculos.
+// As diferenças de ordem não são corrigidas, pois num_seq_tag é referenciado pelos vínculos.
+func GeraScriptCorrecaoXSD(v *ValidacaoXSD, removerExcedentes bool) string {
+	script := strings.Builder{}
+	idEveMensagem := strings.Replace(v.IDEveMensagem, "'", "''", -1)
+	pais := v.Filtrar(DivergenciaPaiIncorreto)
+	ausentes := v.Filtrar(DivergenciaAusente)
+	var removidas []DivergenciaXSD
+	if removerExcedentes {
+		removidas = v.RemoviveisExcedentes()
+	}
+
+	script.WriteString("-- Script para corrigir a estrutura da mensagem conforme o XSD\n")
+	script.WriteString(fmt.Sprintf("-- ID Evento Mensagem: %s\n", v.IDEveMensagem))
+	script.WriteString(fmt.Sprintf("-- Pais corrigidos: %d | tags incluídas: %d | tags removidas: %d\n", len(pais), len(ausentes), len(removidas)))
+	for _, d := range v.Filtrar(DivergenciaExcedente) {
+		if !removerExcedentes || d.Vinculada {
+			script.WriteString(fmt.Sprintf("-- Excedente mantida: %s (num_seq_msg_tag %d)\n", strings.Join(d.Caminho, " > "), d.Tag.NumSeqMsgTag))
+		}
+	}
+	if len(v.Filtrar(DivergenciaOrdem)) > 0 {
+		script.WriteString("-- Diferenças de ordem não são corrigidas: num_seq_tag é referenciado pelos vínculos\n")
+	}
+	script.WriteString("\n")
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	for _, d := range pais {
+		script.WriteString(fmt.Sprintf("-- Pai incorreto: %s -> %s\n", strings.Join(d.Caminho, " > "), strings.Join(d.CaminhoEsperado, " > ")))
+		escreverUpdateIDTagPai(&script, idEveMensagem, d.Tag.NumSeqMsgTag, d.Tag.IDTagPai, d.IDTagPaiEsperado)
+		script.WriteString("\n")
+	}
+
+	for _, d := range ausentes {
+		script.WriteString(fmt.Sprintf("-- Tag ausente: %s\n", strings.Join(d.Caminho, " > ")))
+		script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE num_seq_msg_tag = %d)\n", d.Tag.NumSeqMsgTag))
+		script.WriteString("BEGIN\n")
+		escreverInsertMensagemTags(&script, []MensagemTagInfo{d.Tag})
+		script.WriteString("END\n\n")
+	}
+
+	for _, d := range removidas {
+		script.WriteString(fmt.Sprintf("-- Tag excedente: %s\n", strings.Join(d.Caminho, " > ")))
+		escreverDeleteMensagemTag(&script, idEveMensagem, d.Tag.NumSeqMsgTag)
+		script.WriteString("\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// GeraScriptRollbackCorrecaoXSD gera o script SQL que desfaz GeraScriptCorrecaoXSD: restaura as tags
+// removidas, remove as tags incluídas e devolve o id_tag_pai original aos registros corrigidos
+func GeraScriptRollbackCorrecaoXSD(v *ValidacaoXSD, removerExcedentes bool) string {
+	script := strings.Builder{}
+	idEveMensagem := strings.Replace(v.IDEveMensagem, "'", "''", -1)
+
+	script.WriteString("SET XACT_ABORT ON\n")
+	script.WriteString("BEGIN TRANSACTION\n\n")
+
+	if removerExcedentes {
+		for _, d := range v.RemoviveisExcedentes() {
+			script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE num_seq_msg_tag = %d)\n", d.Tag.NumSeqMsgTag))
+			script.WriteString("BEGIN\n")
+			escreverInsertMensagemTags(&script, []MensagemTagInfo{d.Tag})
+			script.WriteString("END\n\n")
+		}
+	}
+
+	for _, d := range v.Filtrar(DivergenciaAusente) {
+		escreverDeleteMensagemTag(&script, idEveMensagem, d.Tag.NumSeqMsgTag)
+		script.WriteString("\n")
+	}
+
+	for _, d := range v.Filtrar(DivergenciaPaiIncorreto) {
+		escreverUpdateIDTagPai(&script, idEveMensagem, d.Tag.NumSeqMsgTag, d.IDTagPaiEsperado, d.Tag.IDTagPai)
+		script.WriteString("\n")
+	}
+
+	script.WriteString("COMMIT TRANSACTION\n")
+
+	return script.String()
+}
+
+// escreverUpdateIDTagPai troca o id_tag_pai de um registro, apenas se ainda tiver o valor atual informado
+func escreverUpdateIDTagPai(script *strings.Builder, idEveMensagem string, numSeqMsgTag int, atual, novo string) {
+	condicao := fmt.Sprintf("id_tag_pai = %s", idTagPaiSQL(atual))
+	if atual == "" {
+		condicao = "id_tag_pai IS NULL"
+	}
+	script.WriteString("UPDATE spi_mensagem_tag\n")
+	script.WriteString(fmt.Sprintf("SET id_tag_pai = %s\n", idTagPaiSQL(novo)))
+	script.WriteString(fmt.Sprintf("WHERE id_eve_msg = '%s'\n", idEveMensagem))
+	script.WriteString(fmt.Sprintf("  AND num_seq_msg_tag = %d\n", numSeqMsgTag))
+	script.WriteString(fmt.Sprintf("  AND %s\n", condicao))
+}
+
+// escreverDeleteMensagemTag remove um registro de spi_mensagem_tag, protegido pela ausência de vínculos
+func escreverDeleteMensagemTag(script *strings.Builder, idEveMensagem string, numSeqMsgTag int) {
+	script.WriteString(fmt.Sprintf("IF NOT EXISTS (SELECT 1 FROM spi_especializacao_msg_tag WHERE id_eve_msg = '%s' AND num_seq_msg_tag = %d)\n", idEveMensagem, numSeqMsgTag))
+	script.WriteString("BEGIN\n")
+	script.WriteString(fmt.Sprintf("  DELETE FROM spi_mensagem_tag WHERE id_eve_msg = '%s' AND num_seq_msg_tag = %d\n", idEveMensagem, numSeqMsgTag))
+	script.WriteString("END\n")
+}
@@ -0,0 +1,213 @@
+package esptag
+
+import (
+	"strings"
+	"testing"
+
+	"sq_pix/internal/esptag/util"
+)
+
+// xsdTestePacs002 descreve a mesma estrutura de tagsPacs002, com a alternativa Prtry no motivo da situação
+const xsdTestePacs002 = `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:iso:std:iso:20022:tech:xsd:pacs.002.001.10">
+	<xs:element name="Document" type="Document"/>
+	<xs:complexType name="Document">
+		<xs:sequence><xs:element name="FIToFIPmtStsRpt" type="Rpt"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Rpt">
+		<xs:sequence>
+			<xs:element name="GrpHdr" type="GrpHdr"/>
+			<xs:element name="OrgnlGrpInfAndSts" type="OrgnlGrp" minOccurs="0"/>
+			<xs:element name="TxInfAndSts" type="TxInf" minOccurs="0" maxOccurs="unbounded"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="GrpHdr">
+		<xs:sequence>
+			<xs:element name="MsgId" type="Max35Text"/>
+			<xs:element name="CreDtTm" type="ISODateTime"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="OrgnlGrp">
+		<xs:sequence>
+			<xs:element name="OrgnlMsgId" type="Max35Text"/>
+			<xs:element name="OrgnlMsgNmId" type="Max35Text"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="TxInf">
+		<xs:sequence>
+			<xs:element name="OrgnlEndToEndId" type="Max35Text" minOccurs="0"/>
+			<xs:element name="TxSts" type="Max4Text" minOccurs="0"/>
+			<xs:element name="StsRsnInf" type="StsRsnInf" minOccurs="0"/>
+			<xs:element name="OrgnlTxRef" type="TxRef" minOccurs="0"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="StsRsnInf">
+		<xs:sequence><xs:element name="Rsn" type="Rsn" minOccurs="0"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Rsn">
+		<xs:choice>
+			<xs:element name="Cd" type="Max4Text"/>
+			<xs:element name="Prtry" type="Max35Text"/>
+		</xs:choice>
+	</xs:complexType>
+	<xs:complexType name="TxRef">
+		<xs:sequence>
+			<xs:element name="DbtrAcct" type="Acct" minOccurs="0"/>
+			<xs:element name="CdtrAcct" type="Acct" minOccurs="0"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="Acct">
+		<xs:sequence><xs:element name="Id" type="AcctId"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="AcctId">
+		<xs:choice><xs:element name="Othr" type="Othr"/></xs:choice>
+	</xs:complexType>
+	<xs:complexType name="Othr">
+		<xs:sequence><xs:element name="Id" type="Max34Text"/></xs:sequence>
+	</xs:complexType>
+</xs:schema>`
+
+func expandirXSDTeste(t *testing.T) *util.XSDNode {
+	t.Helper()
+	schema, err := util.ParseXSD([]byte(xsdTestePacs002))
+	if err != nil {
+		t.Fatalf("ParseXSD() error = %v", err)
+	}
+	raiz, _, err := schema.Expand(ElementoRaizPadrao)
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	return raiz
+}
+
+// novoCatalogoValidacao cria a pacs.002.001.10 com OrgnlMsgNmId sob o GrpHdr, MsgId e CreDtTm invertidas
+// e uma tag SttlmInf que não existe no XSD
+func novoCatalogoValidacao() *MemoryCatalog {
+	var tags []MensagemTagInfo
+	for _, t := range tagsPacs002() {
+		switch t.IDTag {
+		case "OrgnlMsgNmId":
+			t.IDTagPai = "GrpHdr"
+		case "MsgId":
+			t.NumSeqTag = 5
+		case "CreDtTm":
+			t.NumSeqTag = 4
+		}
+		tags = append(tags, t)
+	}
+	tags = append(tags, MensagemTagInfo{IDEveMensagem: "pacs.002.001.10", IDTipMensagem: "pacs.002", IDTag: "SttlmInf", IDTagPai: "GrpHdr", NumSeqTag: 5, NumSeqMsgTag: 150})
+
+	return NewMemoryCatalog(DadosCatalogo{MensagemTags: tags})
+}
+
+func TestValidarMensagemXSDConforme(t *testing.T) {
+	validacao, err := ValidarMensagemXSD(novoCatalogoTeste(), "pacs.002.001.10", expandirXSDTeste(t))
+	if err != nil {
+		t.Fatalf("ValidarMensagemXSD() error = %v", err)
+	}
+
+	if validacao.Conferidas != len(tagsPacs002()) {
+		t.Errorf("Conferidas = %d, want %d", validacao.Conferidas, len(tagsPacs002()))
+	}
+	if len(validacao.Divergencias) != 1 {
+		t.Fatalf("Divergencias = %+v, want apenas Prtry ausente", validacao.Divergencias)
+	}
+
+	// Sem outra versão da família, Prtry segue Cd (num_seq_tag 14), com o próximo num_seq_msg_tag do catálogo
+	d := validacao.Divergencias[0]
+	if d.Tipo != DivergenciaAusente || d.Tag.IDTag != "Prtry" || d.Tag.IDTagPai != "Rsn" || d.Tag.NumSeqTag != 15 || d.Tag.NumSeqMsgTag != 205 {
+		t.Errorf("Divergencias[0] = %+v, want Prtry ausente sob Rsn com num_seq_tag 15 e num_seq_msg_tag 205", d)
+	}
+}
+
+func TestValidarMensagemXSDSemRaiz(t *testing.T) {
+	var tags []MensagemTagInfo
+	for _, tag := range tagsPacs002()[1:] {
+		if tag.IDTag == "FIToFIPmtStsRpt" {
+			tag.IDTagPai = ""
+		}
+		tags = append(tags, tag)
+	}
+
+	validacao, err := ValidarMensagemXSD(NewMemoryCatalog(DadosCatalogo{MensagemTags: tags}), "pacs.002.001.10", expandirXSDTeste(t))
+	if err != nil {
+		t.Fatalf("ValidarMensagemXSD() error = %v", err)
+	}
+	if len(validacao.Divergencias) != 1 || validacao.Divergencias[0].Tag.IDTag != "Prtry" {
+		t.Errorf("Divergencias = %+v, want apenas Prtry ausente", validacao.Divergencias)
+	}
+}
+
+func TestValidarMensagemXSDDivergencias(t *testing.T) {
+	validacao, err := ValidarMensagemXSD(novoCatalogoValidacao(), "pacs.002.001.10", expandirXSDTeste(t))
+	if err != nil {
+		t.Fatalf("ValidarMensagemXSD() error = %v", err)
+	}
+
+	pais := validacao.Filtrar(DivergenciaPaiIncorreto)
+	if len(pais) != 1 || pais[0].Tag.IDTag != "OrgnlMsgNmId" || pais[0].IDTagPaiEsperado != "OrgnlGrpInfAndSts" {
+		t.Errorf("pais incorretos = %+v, want OrgnlMsgNmId com pai esperado OrgnlGrpInfAndSts", pais)
+	}
+
+	excedentes := validacao.Filtrar(DivergenciaExcedente)
+	if len(excedentes) != 1 || excedentes[0].Tag.NumSeqMsgTag != 150 {
+		t.Errorf("excedentes = %+v, want SttlmInf (150)", excedentes)
+	}
+
+	ordem := validacao.Filtrar(DivergenciaOrdem)
+	if len(ordem) != 1 || ordem[0].Tag.IDTag != "CreDtTm" {
+		t.Errorf("fora de ordem = %+v, want apenas CreDtTm", ordem)
+	}
+
+	ausentes := validacao.Filtrar(DivergenciaAusente)
+	if len(ausentes) != 1 || ausentes[0].Tag.IDTag != "Prtry" || ausentes[0].Tag.NumSeqMsgTag != 151 {
+		t.Errorf("ausentes = %+v, want Prtry com num_seq_msg_tag 151", ausentes)
+	}
+
+	script := GeraScriptCorrecaoXSD(validacao, false)
+	for _, trecho := range []string{
+		"SET id_tag_pai = 'OrgnlGrpInfAndSts'\nWHERE id_eve_msg = 'pacs.002.001.10'\n  AND num_seq_msg_tag = 108\n  AND id_tag_pai = 'GrpHdr'\n",
+		"IF NOT EXISTS (SELECT 1 FROM spi_mensagem_tag WHERE num_seq_msg_tag = 151)",
+		"('pacs.002.001.10', 'pacs.002', 'Prtry', 'Rsn', 15, 151)",
+		"-- Excedente mantida: Document > FIToFIPmtStsRpt > GrpHdr > SttlmInf (num_seq_msg_tag 150)",
+		"-- Diferenças de ordem não são corrigidas",
+	} {
+		if !strings.Contains(script, trecho) {
+			t.Errorf("GeraScriptCorrecaoXSD() não contém %q:\n%s", trecho, script)
+		}
+	}
+	if strings.Contains(script, "DELETE") {
+		t.Errorf("GeraScriptCorrecaoXSD() sem remover_excedentes não deveria remover registros:\n%s", script)
+	}
+
+	script = GeraScriptCorrecaoXSD(validacao, true)
+	if !strings.Contains(script, "DELETE FROM spi_mensagem_tag WHERE id_eve_msg = 'pacs.002.001.10' AND num_seq_msg_tag = 150") {
+		t.Errorf("GeraScriptCorrecaoXSD() com remover_excedentes deveria remover SttlmInf:\n%s", script)
+	}
+
+	rollback := GeraScriptRollbackCorrecaoXSD(validacao, true)
+	for _, trecho := range []string{
+		"('pacs.002.001.10', 'pacs.002', 'SttlmInf', 'GrpHdr', 5, 150)",
+		"DELETE FROM spi_mensagem_tag WHERE id_eve_msg = 'pacs.002.001.10' AND num_seq_msg_tag = 151",
+		"SET id_tag_pai = 'GrpHdr'\nWHERE id_eve_msg = 'pacs.002.001.10'\n  AND num_seq_msg_tag = 108\n  AND id_tag_pai = 'OrgnlGrpInfAndSts'\n",
+	} {
+		if !strings.Contains(rollback, trecho) {
+			t.Errorf("GeraScriptRollbackCorrecaoXSD() não contém %q:\n%s", trecho, rollback)
+		}
+	}
+}
+
+func TestSubsequenciaCrescente(t *testing.T) {
+	marcados := subsequenciaCrescente([]int{0, 1, 2, 4, 3, 5, 6})
+	fora := 0
+	for i, m := range marcados {
+		if !m {
+			fora++
+			if i != 3 {
+				t.Errorf("posição fora da sequência = %d, want 3", i)
+			}
+		}
+	}
+	if fora != 1 {
+		t.Errorf("posições fora da sequência = %d, want 1", fora)
+	}
+}